@@ -1,23 +1,107 @@
 package ast
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/honeylogo/logo/turtle"
 )
 
 // Context represents the execution environment
 type Context struct {
-	Turtle *turtle.Turtle
-}
-
-// NewContext creates a new execution context
+	Turtle     *turtle.Turtle // The turtle commands are directed at
+	Turtles    *turtle.Manager
+	Palette    []color.Color
+	Variables  map[string]float32
+	Procedures map[string]*ProcedureDefinition
+	Output     io.Writer // Where PRINT writes
+	PictureDir string    // Where SAVEPICT and LOADPICT find files
+	SkipWaits  bool
+	Done       <-chan struct{}                 // Closed to stop the program
+	Observer   func(cmd Command, ctx *Context) // Given each command Run completes
+	MaxSteps   int                             // Most steps Run may take, or 0 for no limit
+	Steps      int                             // Commands without blocks and loop passes Run has started
+	callDepth  int
+	test       *bool // Result of the last TEST in this scope, if any
+}
+
+// NewContext creates a new execution context, with t as the current turtle
 func NewContext(t *turtle.Turtle) *Context {
 	return &Context{
-		Turtle: t,
+		Turtle:     t,
+		Turtles:    turtle.NewManager(t),
+		Palette:    DefaultPalette(),
+		Variables:  map[string]float32{},
+		Procedures: map[string]*ProcedureDefinition{},
+		Output:     os.Stdout,
+		PictureDir: ".",
+	}
+}
+
+// ErrStopped is returned by a program stopped through its context's Done
+// channel before it finished
+var ErrStopped = errors.New("program stopped")
+
+// ErrStepLimit is returned by a program that runs more commands than its
+// context's MaxSteps allows
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// stopped returns ErrStopped once the context's Done channel is closed
+func (ctx *Context) stopped() error {
+	select {
+	case <-ctx.Done:
+		return ErrStopped
+	default:
+		return nil
+	}
+}
+
+// step counts a step towards MaxSteps, returning ErrStepLimit once there
+// have been too many
+func (ctx *Context) step() error {
+	ctx.Steps++
+	if ctx.MaxSteps > 0 && ctx.Steps > ctx.MaxSteps {
+		return fmt.Errorf("%w: ran more than %d commands", ErrStepLimit, ctx.MaxSteps)
+	}
+	return nil
+}
+
+// pass is called before each pass of a loop. It returns ErrStopped once the
+// program is stopped, and counts the pass as a step so that a loop with
+// nothing in it still runs out of steps.
+func (ctx *Context) pass() error {
+	if err := ctx.stopped(); err != nil {
+		return err
+	}
+	return ctx.step()
+}
+
+// Run executes a command and then passes it to the context's observer, if
+// there is one. Commands run the commands nested inside them through Run,
+// so the observer sees every command at any depth, each after the commands
+// nested inside it. Commands without blocks of their own, including
+// procedure calls, count as steps towards MaxSteps, as does each pass of a
+// loop.
+func (ctx *Context) Run(cmd Command) error {
+	if !HasBlock(cmd) {
+		if err := ctx.step(); err != nil {
+			return err
+		}
 	}
+	if err := cmd.Execute(ctx); err != nil {
+		return err
+	}
+	if ctx.Observer != nil {
+		ctx.Observer(cmd, ctx)
+	}
+	return nil
 }
 
 // Command is the interface for all Logo commands
@@ -38,12 +122,11 @@ func NewForwardCommand(distance float32) *ForwardCommand {
 
 // Execute moves the turtle forward and updates the drawing
 func (fc *ForwardCommand) Execute(ctx *Context) error {
-	ctx.Turtle.Forward(fc.Distance)
-	return nil
+	return ctx.Turtle.Forward(fc.Distance)
 }
 
 func (fc *ForwardCommand) String() string {
-	return fmt.Sprintf("FORWARD %.2f", fc.Distance)
+	return fmt.Sprintf("FORWARD %s", formatValue(fc.Distance))
 }
 
 // BackwardCommand moves the turtle backward
@@ -58,12 +141,11 @@ func NewBackwardCommand(distance float32) *BackwardCommand {
 
 // Execute moves the turtle backward and updates the drawing
 func (bc *BackwardCommand) Execute(ctx *Context) error {
-	ctx.Turtle.Backward(bc.Distance)
-	return nil
+	return ctx.Turtle.Backward(bc.Distance)
 }
 
 func (bc *BackwardCommand) String() string {
-	return fmt.Sprintf("BACKWARD %.2f", bc.Distance)
+	return fmt.Sprintf("BACKWARD %s", formatValue(bc.Distance))
 }
 
 // LeftCommand turns the turtle left
@@ -83,7 +165,7 @@ func (lc *LeftCommand) Execute(ctx *Context) error {
 }
 
 func (lc *LeftCommand) String() string {
-	return fmt.Sprintf("LEFT %.2f", lc.Angle)
+	return fmt.Sprintf("LEFT %s", formatValue(lc.Angle))
 }
 
 // RightCommand turns the turtle right
@@ -103,7 +185,7 @@ func (rc *RightCommand) Execute(ctx *Context) error {
 }
 
 func (rc *RightCommand) String() string {
-	return fmt.Sprintf("RIGHT %.2f", rc.Angle)
+	return fmt.Sprintf("RIGHT %s", formatValue(rc.Angle))
 }
 
 // PenUpCommand lifts the pen
@@ -163,6 +245,51 @@ func (scc *SetColorCommand) String() string {
 	return fmt.Sprintf("SETCOLOR (R:%d, G:%d, B:%d)", scc.R, scc.G, scc.B)
 }
 
+// SetPenGradientCommand makes the turtle's pen change smoothly from one
+// color to another over a number of segments
+type SetPenGradientCommand struct {
+	From, To [3]uint8
+	Steps    int
+}
+
+// NewSetPenGradientCommand creates a new SetPenGradientCommand
+func NewSetPenGradientCommand(from, to [3]uint8, steps int) *SetPenGradientCommand {
+	return &SetPenGradientCommand{From: from, To: to, Steps: steps}
+}
+
+// Execute starts the gradient on the turtle's pen
+func (spgc *SetPenGradientCommand) Execute(ctx *Context) error {
+	from := color.RGBA{R: spgc.From[0], G: spgc.From[1], B: spgc.From[2], A: 255}
+	to := color.RGBA{R: spgc.To[0], G: spgc.To[1], B: spgc.To[2], A: 255}
+	return ctx.Turtle.SetPenGradient(from, to, spgc.Steps)
+}
+
+func (spgc *SetPenGradientCommand) String() string {
+	return fmt.Sprintf("SETPENGRADIENT (R:%d, G:%d, B:%d) (R:%d, G:%d, B:%d) %d",
+		spgc.From[0], spgc.From[1], spgc.From[2], spgc.To[0], spgc.To[1], spgc.To[2], spgc.Steps)
+}
+
+// RampPenSizeCommand makes the turtle's pen size change evenly from one
+// size to another over a number of segments
+type RampPenSizeCommand struct {
+	From, To float32
+	Steps    int
+}
+
+// NewRampPenSizeCommand creates a new RampPenSizeCommand
+func NewRampPenSizeCommand(from, to float32, steps int) *RampPenSizeCommand {
+	return &RampPenSizeCommand{From: from, To: to, Steps: steps}
+}
+
+// Execute starts the size ramp on the turtle's pen
+func (rpsc *RampPenSizeCommand) Execute(ctx *Context) error {
+	return ctx.Turtle.RampPenSize(rpsc.From, rpsc.To, rpsc.Steps)
+}
+
+func (rpsc *RampPenSizeCommand) String() string {
+	return fmt.Sprintf("RAMPENSIZE %s %s %d", formatValue(rpsc.From), formatValue(rpsc.To), rpsc.Steps)
+}
+
 // SetPenSizeCommand sets the turtle's pen size
 type SetPenSizeCommand struct {
 	Size float32
@@ -180,7 +307,101 @@ func (spsc *SetPenSizeCommand) Execute(ctx *Context) error {
 }
 
 func (spsc *SetPenSizeCommand) String() string {
-	return fmt.Sprintf("SETPENSIZE %.2f", spsc.Size)
+	return fmt.Sprintf("SETPENSIZE %s", formatValue(spsc.Size))
+}
+
+// SetShapeCommand sets the shape the turtle is drawn as
+type SetShapeCommand struct {
+	Shape string
+}
+
+// NewSetShapeCommand creates a new SetShapeCommand
+func NewSetShapeCommand(shape string) *SetShapeCommand {
+	return &SetShapeCommand{Shape: shape}
+}
+
+// Execute sets the turtle's shape
+func (ssc *SetShapeCommand) Execute(ctx *Context) error {
+	return ctx.Turtle.SetShape(ssc.Shape)
+}
+
+func (ssc *SetShapeCommand) String() string {
+	return fmt.Sprintf("SETSHAPE %s", ssc.Shape)
+}
+
+// SetPenPatternCommand sets the style of line the turtle draws
+type SetPenPatternCommand struct {
+	Pattern string
+}
+
+// NewSetPenPatternCommand creates a new SetPenPatternCommand
+func NewSetPenPatternCommand(pattern string) *SetPenPatternCommand {
+	return &SetPenPatternCommand{Pattern: pattern}
+}
+
+// Execute sets the turtle's pen pattern
+func (sppc *SetPenPatternCommand) Execute(ctx *Context) error {
+	return ctx.Turtle.SetPenPattern(sppc.Pattern)
+}
+
+func (sppc *SetPenPatternCommand) String() string {
+	return fmt.Sprintf("SETPENPATTERN %s", sppc.Pattern)
+}
+
+// SetHeadingConventionCommand sets how the turtle measures headings
+type SetHeadingConventionCommand struct {
+	Convention string
+}
+
+// NewSetHeadingConventionCommand creates a new SetHeadingConventionCommand
+func NewSetHeadingConventionCommand(convention string) *SetHeadingConventionCommand {
+	return &SetHeadingConventionCommand{Convention: convention}
+}
+
+// Execute sets the turtle's heading convention
+func (shcc *SetHeadingConventionCommand) Execute(ctx *Context) error {
+	return ctx.Turtle.SetHeadingConvention(shcc.Convention)
+}
+
+func (shcc *SetHeadingConventionCommand) String() string {
+	return fmt.Sprintf("SETHEADINGS %s", shcc.Convention)
+}
+
+// MirrorCommand toggles whether the turtle swaps left and right turns, so
+// the same commands draw a mirror image
+type MirrorCommand struct{}
+
+// NewMirrorCommand creates a new MirrorCommand
+func NewMirrorCommand() *MirrorCommand {
+	return &MirrorCommand{}
+}
+
+// Execute flips the turtle's mirroring
+func (mc *MirrorCommand) Execute(ctx *Context) error {
+	ctx.Turtle.SetMirror(!ctx.Turtle.Mirrored())
+	return nil
+}
+
+func (mc *MirrorCommand) String() string {
+	return "MIRROR"
+}
+
+// CompassCommand prints the turtle's heading as a compass direction
+type CompassCommand struct{}
+
+// NewCompassCommand creates a new CompassCommand
+func NewCompassCommand() *CompassCommand {
+	return &CompassCommand{}
+}
+
+// Execute writes the compass direction to the context's output
+func (cc *CompassCommand) Execute(ctx *Context) error {
+	_, err := fmt.Fprintln(ctx.Output, ctx.Turtle.Compass())
+	return err
+}
+
+func (cc *CompassCommand) String() string {
+	return "COMPASS"
 }
 
 // SetXCommand sets the x-coordinate of the turtle
@@ -195,13 +416,12 @@ func NewSetXCommand(x float32) *SetXCommand {
 
 // Execute sets the x-coordinate and updates the drawing
 func (sxc *SetXCommand) Execute(ctx *Context) error {
-	_, currentY := ctx.Turtle.Position()
-	ctx.Turtle.Goto(sxc.X, currentY)
-	return nil
+	_, currentY := ctx.Turtle.GetPosition()
+	return ctx.Turtle.Goto(sxc.X, currentY)
 }
 
 func (sxc *SetXCommand) String() string {
-	return fmt.Sprintf("SETX %.2f", sxc.X)
+	return fmt.Sprintf("SETX %s", formatValue(sxc.X))
 }
 
 // SetYCommand sets the y-coordinate of the turtle
@@ -216,13 +436,12 @@ func NewSetYCommand(y float32) *SetYCommand {
 
 // Execute sets the y-coordinate and updates the drawing
 func (syc *SetYCommand) Execute(ctx *Context) error {
-	currentX, _ := ctx.Turtle.Position()
-	ctx.Turtle.Goto(currentX, syc.Y)
-	return nil
+	currentX, _ := ctx.Turtle.GetPosition()
+	return ctx.Turtle.Goto(currentX, syc.Y)
 }
 
 func (syc *SetYCommand) String() string {
-	return fmt.Sprintf("SETY %.2f", syc.Y)
+	return fmt.Sprintf("SETY %s", formatValue(syc.Y))
 }
 
 // SetPositionCommand moves the turtle to a specific position
@@ -237,12 +456,190 @@ func NewSetPositionCommand(x, y float32) *SetPositionCommand {
 
 // Execute moves the turtle to a specific position and updates the drawing
 func (spc *SetPositionCommand) Execute(ctx *Context) error {
-	ctx.Turtle.Goto(spc.X, spc.Y)
-	return nil
+	return ctx.Turtle.Goto(spc.X, spc.Y)
 }
 
 func (spc *SetPositionCommand) String() string {
-	return fmt.Sprintf("SETPOSITION (%.2f, %.2f)", spc.X, spc.Y)
+	return fmt.Sprintf("SETPOSITION (%s, %s)", formatValue(spc.X), formatValue(spc.Y))
+}
+
+// SlideCommand moves the turtle in its own frame, DX along its heading and
+// DY to its right, without turning it
+type SlideCommand struct {
+	DX, DY float32
+}
+
+// NewSlideCommand creates a new SlideCommand
+func NewSlideCommand(dx, dy float32) *SlideCommand {
+	return &SlideCommand{DX: dx, DY: dy}
+}
+
+// Execute rotates the move by the turtle's heading and goes there
+func (sc *SlideCommand) Execute(ctx *Context) error {
+	angle := float64(ctx.Turtle.GetAngle()) * math.Pi / 180
+	sin, cos := math.Sincos(angle)
+	dx, dy := float64(sc.DX), float64(sc.DY)
+	x, y := ctx.Turtle.GetPosition()
+	return ctx.Turtle.Goto(x+float32(dx*cos+dy*sin), y+float32(dx*sin-dy*cos))
+}
+
+func (sc *SlideCommand) String() string {
+	return fmt.Sprintf("SLIDE %s %s", formatValue(sc.DX), formatValue(sc.DY))
+}
+
+// SpiralCommand draws a spiral of straight segments, each longer than the
+// last by a fixed amount, turning right by the same angle after each one
+type SpiralCommand struct {
+	Start     float32 // Length of the first segment
+	Increment float32 // Amount each segment is longer than the one before
+	Angle     float32
+	Steps     int
+}
+
+// NewSpiralCommand creates a new SpiralCommand
+func NewSpiralCommand(start, increment, angle float32, steps int) *SpiralCommand {
+	return &SpiralCommand{Start: start, Increment: increment, Angle: angle, Steps: steps}
+}
+
+// Execute draws each segment of the spiral in turn
+func (sc *SpiralCommand) Execute(ctx *Context) error {
+	for i := 0; i < sc.Steps; i++ {
+		if err := ctx.Turtle.Forward(sc.Start + float32(i)*sc.Increment); err != nil {
+			return err
+		}
+		ctx.Turtle.Right(sc.Angle)
+	}
+	return nil
+}
+
+func (sc *SpiralCommand) String() string {
+	return fmt.Sprintf("SPIRAL %s %s %s %d", formatValue(sc.Start), formatValue(sc.Increment), formatValue(sc.Angle), sc.Steps)
+}
+
+// PolygonCommand draws a regular polygon, turning right at each corner and
+// finishing where it started, facing the same way
+type PolygonCommand struct {
+	Sides int
+	Size  float32 // Length of each edge
+}
+
+// NewPolygonCommand creates a new PolygonCommand
+func NewPolygonCommand(sides int, size float32) *PolygonCommand {
+	return &PolygonCommand{Sides: sides, Size: size}
+}
+
+// Execute draws each edge of the polygon in turn
+func (pc *PolygonCommand) Execute(ctx *Context) error {
+	if pc.Sides < 3 {
+		return fmt.Errorf("a polygon needs at least 3 sides, got %d", pc.Sides)
+	}
+	angle := 360 / float32(pc.Sides)
+	for i := 0; i < pc.Sides; i++ {
+		if err := ctx.Turtle.Forward(pc.Size); err != nil {
+			return err
+		}
+		ctx.Turtle.Right(angle)
+	}
+	return nil
+}
+
+func (pc *PolygonCommand) String() string {
+	return fmt.Sprintf("POLYGON %d %s", pc.Sides, formatValue(pc.Size))
+}
+
+// ellipseSegments is the number of straight segments an ellipse is drawn
+// with
+const ellipseSegments = 72
+
+// EllipseCommand draws an ellipse through the turtle's position, curving
+// right from its heading like a full ARCRIGHT. RY is the semi-axis along
+// the heading and RX the one across it, so the centre is RX to the
+// turtle's right, or its left when it's mirrored. The turtle finishes where
+// it started, facing the same way.
+type EllipseCommand struct {
+	RX, RY float32
+}
+
+// NewEllipseCommand creates a new EllipseCommand
+func NewEllipseCommand(rx, ry float32) *EllipseCommand {
+	return &EllipseCommand{RX: rx, RY: ry}
+}
+
+// Execute goes to each point around the ellipse in turn
+func (ec *EllipseCommand) Execute(ctx *Context) error {
+	angle := float64(ctx.Turtle.GetAngle()) * math.Pi / 180
+	sin, cos := math.Sincos(angle)
+	x, y := ctx.Turtle.GetPosition()
+	rx, ry := float64(ec.RX), float64(ec.RY)
+	if ctx.Turtle.Mirrored() {
+		// Curve left instead, like a mirrored ARCRIGHT
+		rx = -rx
+	}
+	for i := 1; i <= ellipseSegments; i++ {
+		if i == ellipseSegments {
+			// Close the loop exactly rather than one rounding error away
+			return ctx.Turtle.Goto(x, y)
+		}
+		t := 2 * math.Pi * float64(i) / ellipseSegments
+		dx, dy := ry*math.Sin(t), rx*(1-math.Cos(t))
+		if err := ctx.Turtle.Goto(x+float32(dx*cos+dy*sin), y+float32(dx*sin-dy*cos)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ec *EllipseCommand) String() string {
+	return fmt.Sprintf("ELLIPSE %s %s", formatValue(ec.RX), formatValue(ec.RY))
+}
+
+// RectCommand draws a rectangle, Width along the turtle's heading and
+// Height to its right, finishing where it started, facing the same way
+type RectCommand struct {
+	Width, Height float32
+}
+
+// NewRectCommand creates a new RectCommand
+func NewRectCommand(width, height float32) *RectCommand {
+	return &RectCommand{Width: width, Height: height}
+}
+
+// Execute draws each edge of the rectangle in turn
+func (rc *RectCommand) Execute(ctx *Context) error {
+	for _, edge := range []float32{rc.Width, rc.Height, rc.Width, rc.Height} {
+		if err := ctx.Turtle.Forward(edge); err != nil {
+			return err
+		}
+		ctx.Turtle.Right(90)
+	}
+	return nil
+}
+
+func (rc *RectCommand) String() string {
+	return fmt.Sprintf("RECT %s %s", formatValue(rc.Width), formatValue(rc.Height))
+}
+
+// DiscCommand stamps a filled disc in the pen color, centred on the turtle
+type DiscCommand struct {
+	Radius float32
+}
+
+// NewDiscCommand creates a new DiscCommand
+func NewDiscCommand(radius float32) *DiscCommand {
+	return &DiscCommand{Radius: radius}
+}
+
+// Execute stamps the disc into the drawing
+func (dc *DiscCommand) Execute(ctx *Context) error {
+	if dc.Radius < 0 {
+		return fmt.Errorf("a disc needs a radius of at least 0, got %s", formatValue(dc.Radius))
+	}
+	ctx.Turtle.Disc(dc.Radius)
+	return nil
+}
+
+func (dc *DiscCommand) String() string {
+	return fmt.Sprintf("DISC %s", formatValue(dc.Radius))
 }
 
 // SetHeadingCommand sets the turtle's heading
@@ -262,7 +659,7 @@ func (shc *SetHeadingCommand) Execute(ctx *Context) error {
 }
 
 func (shc *SetHeadingCommand) String() string {
-	return fmt.Sprintf("SETHEADING %.2f", shc.Angle)
+	return fmt.Sprintf("SETHEADING %s", formatValue(shc.Angle))
 }
 
 // HomeCommand moves the turtle to the center of the canvas
@@ -284,6 +681,351 @@ func (hc *HomeCommand) String() string {
 	return "HOME"
 }
 
+// ResetCommand starts the program's picture again: it clears the drawing,
+// removes every turtle but the default one and puts that back at home with
+// the default pen, and forgets all variables. Procedures are kept.
+type ResetCommand struct{}
+
+// NewResetCommand creates a new ResetCommand
+func NewResetCommand() *ResetCommand {
+	return &ResetCommand{}
+}
+
+// Execute resets the turtles, drawing and variables
+func (rc *ResetCommand) Execute(ctx *Context) error {
+	ctx.Turtles.Reset()
+	ctx.Turtle = ctx.Turtles.Default()
+	ctx.Variables = map[string]float32{}
+	ctx.test = nil
+	return nil
+}
+
+func (rc *ResetCommand) String() string {
+	return "RESET"
+}
+
+// ArcRightCommand moves the turtle along an arc curving to the right
+type ArcRightCommand struct {
+	Radius float32
+	Angle  float32
+}
+
+// NewArcRightCommand creates a new ArcRightCommand
+func NewArcRightCommand(radius, angle float32) *ArcRightCommand {
+	return &ArcRightCommand{Radius: radius, Angle: angle}
+}
+
+// Execute moves the turtle along the arc and updates the drawing
+func (arc *ArcRightCommand) Execute(ctx *Context) error {
+	return ctx.Turtle.ArcRight(arc.Radius, arc.Angle)
+}
+
+func (arc *ArcRightCommand) String() string {
+	return fmt.Sprintf("ARCRIGHT %s %s", formatValue(arc.Radius), formatValue(arc.Angle))
+}
+
+// ArcLeftCommand moves the turtle along an arc curving to the left
+type ArcLeftCommand struct {
+	Radius float32
+	Angle  float32
+}
+
+// NewArcLeftCommand creates a new ArcLeftCommand
+func NewArcLeftCommand(radius, angle float32) *ArcLeftCommand {
+	return &ArcLeftCommand{Radius: radius, Angle: angle}
+}
+
+// Execute moves the turtle along the arc and updates the drawing
+func (alc *ArcLeftCommand) Execute(ctx *Context) error {
+	return ctx.Turtle.ArcLeft(alc.Radius, alc.Angle)
+}
+
+func (alc *ArcLeftCommand) String() string {
+	return fmt.Sprintf("ARCLEFT %s %s", formatValue(alc.Radius), formatValue(alc.Angle))
+}
+
+// WaitCommand pauses execution for a number of ticks, each 1/60th of a second
+type WaitCommand struct {
+	Ticks float32
+}
+
+// NewWaitCommand creates a new WaitCommand
+func NewWaitCommand(ticks float32) *WaitCommand {
+	return &WaitCommand{Ticks: ticks}
+}
+
+// Execute sleeps for the requested time, unless waits are being skipped,
+// returning ErrStopped if the program is stopped while it waits
+func (wc *WaitCommand) Execute(ctx *Context) error {
+	if wc.Ticks < 0 {
+		return fmt.Errorf("wait requires a non-negative number of ticks, got %s", formatValue(wc.Ticks))
+	}
+	if ctx.SkipWaits {
+		return nil
+	}
+	select {
+	case <-ctx.Done:
+		return ErrStopped
+	case <-time.After(time.Duration(float64(wc.Ticks) / 60 * float64(time.Second))):
+		return nil
+	}
+}
+
+func (wc *WaitCommand) String() string {
+	return fmt.Sprintf("WAIT %s", formatValue(wc.Ticks))
+}
+
+// MakeCommand assigns the value of an expression to a variable
+type MakeCommand struct {
+	Name  string
+	Value Expression
+}
+
+// NewMakeCommand creates a new MakeCommand
+func NewMakeCommand(name string, value Expression) *MakeCommand {
+	return &MakeCommand{
+		Name:  name,
+		Value: value,
+	}
+}
+
+// Execute evaluates the expression and stores the result
+func (mc *MakeCommand) Execute(ctx *Context) error {
+	value, err := mc.Value.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Variables[mc.Name] = value
+	return nil
+}
+
+func (mc *MakeCommand) String() string {
+	return fmt.Sprintf("MAKE %s %s", mc.Name, mc.Value.String())
+}
+
+// PrintCommand writes a word, or the value of an expression, to the
+// context's output on a line of its own
+type PrintCommand struct {
+	Text     string
+	Value    Expression // Printed instead of Text when set
+	Position bool       // Prints the turtle's position as [x y] instead
+}
+
+// NewPrintCommand creates a PrintCommand that prints a word
+func NewPrintCommand(text string) *PrintCommand {
+	return &PrintCommand{Text: text}
+}
+
+// NewPrintValueCommand creates a PrintCommand that prints an expression
+func NewPrintValueCommand(value Expression) *PrintCommand {
+	return &PrintCommand{Value: value}
+}
+
+// NewPrintPositionCommand creates a PrintCommand that prints the turtle's
+// position as a list
+func NewPrintPositionCommand() *PrintCommand {
+	return &PrintCommand{Position: true}
+}
+
+// Execute writes the text, value or position to the output
+func (pc *PrintCommand) Execute(ctx *Context) error {
+	text := pc.Text
+	switch {
+	case pc.Position:
+		x, err := NewTurtleExpression("xcor").Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		y, err := NewTurtleExpression("ycor").Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		text = fmt.Sprintf("[%s %s]", formatValue(x), formatValue(y))
+	case pc.Value != nil:
+		value, err := pc.Value.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		text = formatValue(value)
+	}
+	_, err := fmt.Fprintln(ctx.Output, text)
+	return err
+}
+
+func (pc *PrintCommand) String() string {
+	if pc.Position {
+		return "PRINT POS"
+	}
+	if pc.Value != nil {
+		return fmt.Sprintf("PRINT %s", pc.Value.String())
+	}
+	return fmt.Sprintf("PRINT %q", pc.Text)
+}
+
+// formatValue writes a number in its shortest form, so 50 rather than 50.00
+func formatValue(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
+
+// DeferredCommand is a command whose arguments depend on variables, so it
+// can only be created once they have values
+type DeferredCommand struct {
+	Name   string
+	Args   []Expression
+	Create func(args []float32) (Command, error)
+}
+
+// NewDeferredCommand creates a new DeferredCommand
+func NewDeferredCommand(name string, args []Expression, create func(args []float32) (Command, error)) *DeferredCommand {
+	return &DeferredCommand{
+		Name:   name,
+		Args:   args,
+		Create: create,
+	}
+}
+
+// Resolve evaluates the arguments in ctx and creates the command they give,
+// without running it
+func (dc *DeferredCommand) Resolve(ctx *Context) (Command, error) {
+	values := make([]float32, len(dc.Args))
+	for i, arg := range dc.Args {
+		value, err := arg.Evaluate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s command: %w", dc.Name, err)
+		}
+		values[i] = value
+	}
+	return dc.Create(values)
+}
+
+// Execute evaluates the arguments, then creates and runs the command
+func (dc *DeferredCommand) Execute(ctx *Context) error {
+	cmd, err := dc.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return cmd.Execute(ctx)
+}
+
+func (dc *DeferredCommand) String() string {
+	args := make([]string, len(dc.Args))
+	for i, arg := range dc.Args {
+		args[i] = arg.String()
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", strings.ToUpper(dc.Name), strings.Join(args, " ")))
+}
+
+// DoTimesCommand runs a block a number of times, with a variable counting
+// from 0 on the first pass
+type DoTimesCommand struct {
+	Variable string
+	Count    Expression
+	Commands []Command
+}
+
+// NewDoTimesCommand creates a new DoTimesCommand
+func NewDoTimesCommand(variable string, count Expression, commands []Command) *DoTimesCommand {
+	return &DoTimesCommand{
+		Variable: variable,
+		Count:    count,
+		Commands: commands,
+	}
+}
+
+// Execute runs the commands with the loop variable set, restoring any
+// previous value of the variable afterwards
+func (dtc *DoTimesCommand) Execute(ctx *Context) error {
+	count, err := dtc.Count.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, existed := ctx.Variables[dtc.Variable]
+	defer func() {
+		if existed {
+			ctx.Variables[dtc.Variable] = previous
+		} else {
+			delete(ctx.Variables, dtc.Variable)
+		}
+	}()
+
+	for i := 0; float32(i) < count; i++ {
+		if err := ctx.pass(); err != nil {
+			return err
+		}
+		ctx.Variables[dtc.Variable] = float32(i)
+		for _, cmd := range dtc.Commands {
+			if err := ctx.Run(cmd); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (dtc *DoTimesCommand) String() string {
+	cmds := make([]string, len(dtc.Commands))
+	for i, cmd := range dtc.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("DOTIMES %s %s {\n%s\n}", dtc.Variable, dtc.Count.String(), strings.Join(cmds, "\n"))
+}
+
+// ForEachCommand runs a block once for each number in a list, with a
+// variable holding the current number. The variable is ? unless named.
+type ForEachCommand struct {
+	Variable string
+	Values   []float32
+	Commands []Command
+}
+
+// NewForEachCommand creates a new ForEachCommand
+func NewForEachCommand(variable string, values []float32, commands []Command) *ForEachCommand {
+	return &ForEachCommand{
+		Variable: variable,
+		Values:   values,
+		Commands: commands,
+	}
+}
+
+// Execute runs the commands for each value, restoring any previous value of
+// the variable afterwards
+func (fec *ForEachCommand) Execute(ctx *Context) error {
+	previous, existed := ctx.Variables[fec.Variable]
+	defer func() {
+		if existed {
+			ctx.Variables[fec.Variable] = previous
+		} else {
+			delete(ctx.Variables, fec.Variable)
+		}
+	}()
+
+	for _, value := range fec.Values {
+		if err := ctx.pass(); err != nil {
+			return err
+		}
+		ctx.Variables[fec.Variable] = value
+		for _, cmd := range fec.Commands {
+			if err := ctx.Run(cmd); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fec *ForEachCommand) String() string {
+	values := make([]string, len(fec.Values))
+	for i, value := range fec.Values {
+		values[i] = formatValue(value)
+	}
+	cmds := make([]string, len(fec.Commands))
+	for i, cmd := range fec.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("FOREACH %s [%s] {\n%s\n}", fec.Variable, strings.Join(values, " "), strings.Join(cmds, "\n"))
+}
+
 // RepeatCommand represents a repeat block
 type RepeatCommand struct {
 	Times    int
@@ -298,11 +1040,32 @@ func NewRepeatCommand(times int, commands []Command) *RepeatCommand {
 	}
 }
 
-// Execute runs the commands multiple times
+// RepCount is the variable REPEAT counts its passes in, from 1, read in
+// programs as repcount or :repcount. Variables are scoped dynamically, so
+// procedures called from a REPEAT block see the count of the innermost
+// REPEAT running, unless they have a parameter of the same name or run a
+// REPEAT of their own.
+const RepCount = "repcount"
+
+// Execute runs the commands multiple times with RepCount set to the number
+// of the pass, restoring any previous value of it afterwards
 func (rc *RepeatCommand) Execute(ctx *Context) error {
+	previous, existed := ctx.Variables[RepCount]
+	defer func() {
+		if existed {
+			ctx.Variables[RepCount] = previous
+		} else {
+			delete(ctx.Variables, RepCount)
+		}
+	}()
+
 	for i := 0; i < rc.Times; i++ {
+		if err := ctx.pass(); err != nil {
+			return err
+		}
+		ctx.Variables[RepCount] = float32(i + 1)
 		for _, cmd := range rc.Commands {
-			if err := cmd.Execute(ctx); err != nil {
+			if err := ctx.Run(cmd); err != nil {
 				return err
 			}
 		}
@@ -318,6 +1081,178 @@ func (rc *RepeatCommand) String() string {
 	return fmt.Sprintf("REPEAT %d {\n%s\n}", rc.Times, strings.Join(cmds, "\n"))
 }
 
+// IfCommand runs a block of commands when a condition holds
+type IfCommand struct {
+	Condition Expression
+	Commands  []Command
+}
+
+// NewIfCommand creates a new IfCommand
+func NewIfCommand(condition Expression, commands []Command) *IfCommand {
+	return &IfCommand{
+		Condition: condition,
+		Commands:  commands,
+	}
+}
+
+// Execute evaluates the condition and runs the commands if it is true
+func (ic *IfCommand) Execute(ctx *Context) error {
+	value, err := ic.Condition.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	if !IsTrue(value) {
+		return nil
+	}
+	for _, cmd := range ic.Commands {
+		if err := ctx.Run(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ic *IfCommand) String() string {
+	cmds := make([]string, len(ic.Commands))
+	for i, cmd := range ic.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("IF %s {\n%s\n}", ic.Condition.String(), strings.Join(cmds, "\n"))
+}
+
+// TestCommand evaluates a condition and remembers the result for IFTRUE and
+// IFFALSE. Each procedure call has its own result, so a TEST inside a
+// procedure doesn't affect its caller.
+type TestCommand struct {
+	Condition Expression
+}
+
+// NewTestCommand creates a new TestCommand
+func NewTestCommand(condition Expression) *TestCommand {
+	return &TestCommand{Condition: condition}
+}
+
+// Execute evaluates the condition and records whether it is true
+func (tc *TestCommand) Execute(ctx *Context) error {
+	value, err := tc.Condition.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	result := IsTrue(value)
+	ctx.test = &result
+	return nil
+}
+
+func (tc *TestCommand) String() string {
+	return fmt.Sprintf("TEST %s", tc.Condition.String())
+}
+
+// IfTrueCommand runs a block of commands when the last TEST was true
+type IfTrueCommand struct {
+	Commands []Command
+}
+
+// NewIfTrueCommand creates a new IfTrueCommand
+func NewIfTrueCommand(commands []Command) *IfTrueCommand {
+	return &IfTrueCommand{Commands: commands}
+}
+
+// Execute runs the commands if the last TEST was true
+func (itc *IfTrueCommand) Execute(ctx *Context) error {
+	return runIfTested(ctx, "iftrue", true, itc.Commands)
+}
+
+func (itc *IfTrueCommand) String() string {
+	cmds := make([]string, len(itc.Commands))
+	for i, cmd := range itc.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("IFTRUE {\n%s\n}", strings.Join(cmds, "\n"))
+}
+
+// IfFalseCommand runs a block of commands when the last TEST was false
+type IfFalseCommand struct {
+	Commands []Command
+}
+
+// NewIfFalseCommand creates a new IfFalseCommand
+func NewIfFalseCommand(commands []Command) *IfFalseCommand {
+	return &IfFalseCommand{Commands: commands}
+}
+
+// Execute runs the commands if the last TEST was false
+func (ifc *IfFalseCommand) Execute(ctx *Context) error {
+	return runIfTested(ctx, "iffalse", false, ifc.Commands)
+}
+
+func (ifc *IfFalseCommand) String() string {
+	cmds := make([]string, len(ifc.Commands))
+	for i, cmd := range ifc.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("IFFALSE {\n%s\n}", strings.Join(cmds, "\n"))
+}
+
+// runIfTested runs commands if the last TEST in scope had the wanted result
+func runIfTested(ctx *Context, name string, want bool, commands []Command) error {
+	if ctx.test == nil {
+		return fmt.Errorf("%s without a TEST", name)
+	}
+	if *ctx.test != want {
+		return nil
+	}
+	for _, cmd := range commands {
+		if err := ctx.Run(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WhileCommand runs a block of commands for as long as a condition holds
+type WhileCommand struct {
+	Condition Expression
+	Commands  []Command
+}
+
+// NewWhileCommand creates a new WhileCommand
+func NewWhileCommand(condition Expression, commands []Command) *WhileCommand {
+	return &WhileCommand{
+		Condition: condition,
+		Commands:  commands,
+	}
+}
+
+// Execute runs the commands until the condition is false, checking it
+// before each pass
+func (wc *WhileCommand) Execute(ctx *Context) error {
+	for {
+		if err := ctx.pass(); err != nil {
+			return err
+		}
+		value, err := wc.Condition.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		if !IsTrue(value) {
+			return nil
+		}
+		for _, cmd := range wc.Commands {
+			if err := ctx.Run(cmd); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (wc *WhileCommand) String() string {
+	cmds := make([]string, len(wc.Commands))
+	for i, cmd := range wc.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("WHILE %s {\n%s\n}", wc.Condition.String(), strings.Join(cmds, "\n"))
+}
+
 // ProcedureDefinition represents a user-defined procedure
 type ProcedureDefinition struct {
 	Name   string
@@ -334,9 +1269,10 @@ func NewProcedureDefinition(name string, params []string, body []Command) *Proce
 	}
 }
 
-// Execute stores the procedure definition for later use
+// Execute stores the procedure definition for later use, replacing any
+// earlier definition with the same name
 func (pd *ProcedureDefinition) Execute(ctx *Context) error {
-	// This is a no-op as procedure definitions are handled separately
+	ctx.Procedures[pd.Name] = pd
 	return nil
 }
 
@@ -349,6 +1285,127 @@ func (pd *ProcedureDefinition) String() string {
 		pd.Name, strings.Join(pd.Params, ", "), strings.Join(cmds, "\n"))
 }
 
+// EraseCommand removes a user-defined procedure, freeing its name
+type EraseCommand struct {
+	Name string
+}
+
+// NewEraseCommand creates a new EraseCommand
+func NewEraseCommand(name string) *EraseCommand {
+	return &EraseCommand{Name: name}
+}
+
+// Execute removes the procedure from the context
+func (ec *EraseCommand) Execute(ctx *Context) error {
+	if _, exists := ctx.Procedures[ec.Name]; !exists {
+		return fmt.Errorf("%s is not a procedure", ec.Name)
+	}
+	delete(ctx.Procedures, ec.Name)
+	return nil
+}
+
+func (ec *EraseCommand) String() string {
+	return fmt.Sprintf("ERASE %s", ec.Name)
+}
+
+// MaxCallDepth is how deeply procedure calls may nest, which stops runaway
+// recursion before it exhausts the stack
+const MaxCallDepth = 1000
+
+// ProcedureCallCommand runs a user-defined procedure
+type ProcedureCallCommand struct {
+	Name string
+	Args []Expression
+}
+
+// NewProcedureCallCommand creates a new ProcedureCallCommand
+func NewProcedureCallCommand(name string, args []Expression) *ProcedureCallCommand {
+	return &ProcedureCallCommand{
+		Name: name,
+		Args: args,
+	}
+}
+
+// Execute binds the procedure's inputs to the argument values and runs its
+// body, restoring the variables the inputs hid afterwards
+func (pcc *ProcedureCallCommand) Execute(ctx *Context) error {
+	body, exit, err := pcc.Enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer exit()
+
+	for _, cmd := range body {
+		if err := ctx.Run(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enter starts a call without running it: it binds the procedure's inputs
+// to the argument values and returns the body to run, along with a function
+// that ends the call by restoring the variables the inputs hid. Execute uses
+// it to run the whole call, and a debugger can use it to step through one.
+func (pcc *ProcedureCallCommand) Enter(ctx *Context) ([]Command, func(), error) {
+	pd, exists := ctx.Procedures[pcc.Name]
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown procedure: %s", pcc.Name)
+	}
+	if len(pcc.Args) != len(pd.Params) {
+		return nil, nil, fmt.Errorf("%s expects %d inputs, got %d", pcc.Name, len(pd.Params), len(pcc.Args))
+	}
+	if err := ctx.stopped(); err != nil {
+		return nil, nil, err
+	}
+	if ctx.callDepth >= MaxCallDepth {
+		return nil, nil, fmt.Errorf("%s: procedure calls nested more than %d deep", pcc.Name, MaxCallDepth)
+	}
+
+	values := make([]float32, len(pcc.Args))
+	for i, arg := range pcc.Args {
+		value, err := arg.Evaluate(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = value
+	}
+
+	type saved struct {
+		value   float32
+		existed bool
+	}
+	hidden := make([]saved, len(pd.Params))
+	for i, param := range pd.Params {
+		value, existed := ctx.Variables[param]
+		hidden[i] = saved{value, existed}
+		ctx.Variables[param] = values[i]
+	}
+	test := ctx.test
+	ctx.test = nil
+	ctx.callDepth++
+	exit := func() {
+		ctx.callDepth--
+		ctx.test = test
+		for i, param := range pd.Params {
+			if hidden[i].existed {
+				ctx.Variables[param] = hidden[i].value
+			} else {
+				delete(ctx.Variables, param)
+			}
+		}
+	}
+	return pd.Body, exit, nil
+}
+
+func (pcc *ProcedureCallCommand) String() string {
+	args := make([]string, len(pcc.Args))
+	for i, arg := range pcc.Args {
+		args[i] = arg.String()
+	}
+	return strings.TrimSpace(fmt.Sprintf("CALL %s %s", pcc.Name, strings.Join(args, " ")))
+}
+
 // Program represents a complete Logo program
 type Program struct {
 	Commands []Command
@@ -364,7 +1421,7 @@ func NewProgram(commands []Command) *Program {
 // Execute runs the entire program and returns the resulting Drawing
 func (p *Program) Execute(ctx *Context) error {
 	for _, cmd := range p.Commands {
-		if err := cmd.Execute(ctx); err != nil {
+		if err := ctx.Run(cmd); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,41 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/turtle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandStringNumbers(t *testing.T) {
+	tests := []struct {
+		cmd      Command
+		expected string
+	}{
+		{NewForwardCommand(100), "FORWARD 100"},
+		{NewForwardCommand(12.5), "FORWARD 12.5"},
+		{NewBackwardCommand(0.125), "BACKWARD 0.125"},
+		{NewRightCommand(-90), "RIGHT -90"},
+		{NewSetPositionCommand(10, -2.5), "SETPOSITION (10, -2.5)"},
+		{NewArcLeftCommand(50, 180), "ARCLEFT 50 180"},
+		{NewRepeatCommand(2, []Command{NewLeftCommand(72)}), "REPEAT 2 {\nLEFT 72\n}"},
+		{NewMakeCommand("x", NewNumberExpression(3)), "MAKE x 3"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.cmd.String())
+	}
+}
+
+func TestDoneStopsLoops(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+
+	ctx := NewContext(turtle.NewHeadless())
+	ctx.Done = done
+	loop := NewWhileCommand(NewNumberExpression(1), []Command{NewForwardCommand(1)})
+	assert.ErrorIs(t, loop.Execute(ctx), ErrStopped)
+	assert.ErrorIs(t, NewRepeatCommand(3, []Command{NewForwardCommand(1)}).Execute(ctx), ErrStopped)
+	x, y := ctx.Turtle.GetPosition()
+	assert.Equal(t, [2]float32{0, 0}, [2]float32{x, y})
+}
@@ -0,0 +1,392 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Expression is the interface for all values computed by Logo programs
+type Expression interface {
+	Evaluate(ctx *Context) (float32, error)
+	String() string
+}
+
+// NumberExpression represents a literal number
+type NumberExpression struct {
+	Value float32
+}
+
+// NewNumberExpression creates a new NumberExpression
+func NewNumberExpression(value float32) *NumberExpression {
+	return &NumberExpression{Value: value}
+}
+
+// Evaluate returns the literal value
+func (ne *NumberExpression) Evaluate(ctx *Context) (float32, error) {
+	return ne.Value, nil
+}
+
+func (ne *NumberExpression) String() string {
+	return formatValue(ne.Value)
+}
+
+// VariableExpression represents the value of a variable, such as :size
+type VariableExpression struct {
+	Name string
+}
+
+// NewVariableExpression creates a new VariableExpression
+func NewVariableExpression(name string) *VariableExpression {
+	return &VariableExpression{Name: name}
+}
+
+// Evaluate looks up the variable in the context
+func (ve *VariableExpression) Evaluate(ctx *Context) (float32, error) {
+	if ctx != nil {
+		if value, exists := ctx.Variables[ve.Name]; exists {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("%s has no value", ve.Name)
+}
+
+func (ve *VariableExpression) String() string {
+	return ":" + ve.Name
+}
+
+// BinaryExpression applies an infix operator to two operands. Comparisons
+// give 1 when they hold and 0 otherwise.
+type BinaryExpression struct {
+	Operator string
+	Left     Expression
+	Right    Expression
+}
+
+// NewBinaryExpression creates a new BinaryExpression
+func NewBinaryExpression(operator string, left, right Expression) *BinaryExpression {
+	return &BinaryExpression{
+		Operator: operator,
+		Left:     left,
+		Right:    right,
+	}
+}
+
+// Evaluate computes the result of the infix operation
+func (be *BinaryExpression) Evaluate(ctx *Context) (float32, error) {
+	left, err := be.Left.Evaluate(ctx)
+	if err != nil {
+		return 0, err
+	}
+	right, err := be.Right.Evaluate(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	switch be.Operator {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/":
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	case "%":
+		return modulo(left, right)
+	case "^":
+		return power(left, right)
+	case "<":
+		return boolValue(left < right), nil
+	case ">":
+		return boolValue(left > right), nil
+	case "=":
+		return boolValue(left == right), nil
+	}
+	return 0, fmt.Errorf("unknown operator: %s", be.Operator)
+}
+
+func (be *BinaryExpression) String() string {
+	return fmt.Sprintf("%s %s %s", be.Left.String(), be.Operator, be.Right.String())
+}
+
+// modulo returns the remainder of dividing a by b once both are truncated to
+// whole numbers. As in Logo, the remainder takes the sign of b, so modulo -7
+// 3 is 2.
+func modulo(a, b float32) (float32, error) {
+	dividend, divisor := int64(a), int64(b)
+	if divisor == 0 {
+		return 0, fmt.Errorf("modulo by zero")
+	}
+	remainder := dividend % divisor
+	if remainder != 0 && (remainder < 0) != (divisor < 0) {
+		remainder += divisor
+	}
+	return float32(remainder), nil
+}
+
+// power raises base to the power exp, which may be fractional
+func power(base, exp float32) (float32, error) {
+	result := math.Pow(float64(base), float64(exp))
+	if math.IsNaN(result) {
+		return 0, fmt.Errorf("power: %s to the power %s is not a real number", formatValue(base), formatValue(exp))
+	}
+	return float32(result), nil
+}
+
+// FunctionDefinition describes a prefix function
+type FunctionDefinition struct {
+	Arity int
+	Apply func(args []float32) (float32, error)
+}
+
+// Prefix function definitions mapping
+var functionDefinitions = map[string]FunctionDefinition{
+	"sum": {
+		Arity: 2,
+		Apply: func(args []float32) (float32, error) { return args[0] + args[1], nil },
+	},
+	"difference": {
+		Arity: 2,
+		Apply: func(args []float32) (float32, error) { return args[0] - args[1], nil },
+	},
+	"product": {
+		Arity: 2,
+		Apply: func(args []float32) (float32, error) { return args[0] * args[1], nil },
+	},
+	"quotient": {
+		Arity: 2,
+		Apply: func(args []float32) (float32, error) {
+			if args[1] == 0 {
+				return 0, fmt.Errorf("quotient: division by zero")
+			}
+			return args[0] / args[1], nil
+		},
+	},
+	"modulo": {
+		Arity: 2,
+		Apply: func(args []float32) (float32, error) { return modulo(args[0], args[1]) },
+	},
+	"power": {
+		Arity: 2,
+		Apply: func(args []float32) (float32, error) { return power(args[0], args[1]) },
+	},
+	"minus": {
+		Arity: 1,
+		Apply: func(args []float32) (float32, error) { return -args[0], nil },
+	},
+	"not": {
+		Arity: 1,
+		Apply: func(args []float32) (float32, error) { return boolValue(!IsTrue(args[0])), nil },
+	},
+	"abs":   mathFunction(math.Abs),
+	"sin":   degreesFunction(math.Sin),
+	"cos":   degreesFunction(math.Cos),
+	"tan":   degreesFunction(math.Tan),
+	"int":   mathFunction(math.Trunc),
+	"round": mathFunction(math.Round),
+	"sqrt": {
+		Arity: 1,
+		Apply: func(args []float32) (float32, error) {
+			if args[0] < 0 {
+				return 0, fmt.Errorf("sqrt: %s is negative", formatValue(args[0]))
+			}
+			return float32(math.Sqrt(float64(args[0]))), nil
+		},
+	},
+}
+
+// mathFunction makes a one-argument function from a function in the math
+// package
+func mathFunction(f func(float64) float64) FunctionDefinition {
+	return FunctionDefinition{
+		Arity: 1,
+		Apply: func(args []float32) (float32, error) { return float32(f(float64(args[0]))), nil },
+	}
+}
+
+// degreesFunction makes a one-argument trigonometric function taking an
+// angle in degrees, as Logo measures angles, from one in the math package
+// taking radians
+func degreesFunction(f func(float64) float64) FunctionDefinition {
+	return mathFunction(func(degrees float64) float64 {
+		return f(degrees * math.Pi / 180)
+	})
+}
+
+// LookupFunction finds a prefix function definition by name
+func LookupFunction(name string) (FunctionDefinition, bool) {
+	def, exists := functionDefinitions[name]
+	return def, exists
+}
+
+// FunctionExpression applies a prefix function to its operands
+type FunctionExpression struct {
+	Name string
+	Args []Expression
+}
+
+// NewFunctionExpression creates a new FunctionExpression
+func NewFunctionExpression(name string, args []Expression) *FunctionExpression {
+	return &FunctionExpression{
+		Name: name,
+		Args: args,
+	}
+}
+
+// Evaluate computes the operands and applies the function to them
+func (fe *FunctionExpression) Evaluate(ctx *Context) (float32, error) {
+	def, exists := LookupFunction(fe.Name)
+	if !exists {
+		return 0, fmt.Errorf("unknown function: %s", fe.Name)
+	}
+	if len(fe.Args) != def.Arity {
+		return 0, fmt.Errorf("%s expects %d arguments, got %d", fe.Name, def.Arity, len(fe.Args))
+	}
+
+	values := make([]float32, len(fe.Args))
+	for i, arg := range fe.Args {
+		value, err := arg.Evaluate(ctx)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = value
+	}
+	return def.Apply(values)
+}
+
+func (fe *FunctionExpression) String() string {
+	args := make([]string, len(fe.Args))
+	for i, arg := range fe.Args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s %s", strings.ToUpper(fe.Name), strings.Join(args, " "))
+}
+
+// TurtleExpression reads a property of the current turtle: its xcor or ycor
+// relative to home, or its heading in the units SETHEADING takes
+type TurtleExpression struct {
+	Name string
+}
+
+// NewTurtleExpression creates a new TurtleExpression
+func NewTurtleExpression(name string) *TurtleExpression {
+	return &TurtleExpression{Name: name}
+}
+
+// IsTurtleReader reports whether name is a property TurtleExpression reads
+func IsTurtleReader(name string) bool {
+	return name == "xcor" || name == "ycor" || name == "heading"
+}
+
+// Evaluate reads the property from the context's turtle. The value is
+// rounded to four decimal places so the trigonometry behind turtle moves
+// doesn't leave noise such as 49.999996 in printed results.
+func (te *TurtleExpression) Evaluate(ctx *Context) (float32, error) {
+	if ctx == nil || ctx.Turtle == nil {
+		return 0, fmt.Errorf("%s needs a turtle", te.Name)
+	}
+
+	var value float32
+	switch te.Name {
+	case "xcor":
+		value = ctx.Turtle.GetX()
+	case "ycor":
+		value = ctx.Turtle.GetY()
+	case "heading":
+		value = ctx.Turtle.Heading()
+	default:
+		return 0, fmt.Errorf("unknown turtle property: %s", te.Name)
+	}
+	return float32(math.Round(float64(value)*10000) / 10000), nil
+}
+
+func (te *TurtleExpression) String() string {
+	return strings.ToUpper(te.Name)
+}
+
+// LogicalExpression combines two conditions with AND or OR, evaluating the
+// second only when the first doesn't settle the result
+type LogicalExpression struct {
+	Operator string
+	Left     Expression
+	Right    Expression
+}
+
+// NewLogicalExpression creates a new LogicalExpression
+func NewLogicalExpression(operator string, left, right Expression) *LogicalExpression {
+	return &LogicalExpression{
+		Operator: operator,
+		Left:     left,
+		Right:    right,
+	}
+}
+
+// Evaluate computes the result, short-circuiting where possible
+func (le *LogicalExpression) Evaluate(ctx *Context) (float32, error) {
+	left, err := le.Left.Evaluate(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	switch le.Operator {
+	case "and":
+		if !IsTrue(left) {
+			return 0, nil
+		}
+	case "or":
+		if IsTrue(left) {
+			return 1, nil
+		}
+	default:
+		return 0, fmt.Errorf("unknown logical operator: %s", le.Operator)
+	}
+
+	right, err := le.Right.Evaluate(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return boolValue(IsTrue(right)), nil
+}
+
+func (le *LogicalExpression) String() string {
+	return fmt.Sprintf("%s %s %s", strings.ToUpper(le.Operator), le.Left.String(), le.Right.String())
+}
+
+// IsTrue reports whether a value counts as true in a condition, which any
+// value other than 0 does
+func IsTrue(value float32) bool {
+	return value != 0
+}
+
+// boolValue converts a truth value to 1 or 0
+func boolValue(b bool) float32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// IsConstant reports whether an expression can be evaluated without a
+// context, because it doesn't refer to any variables
+func IsConstant(expr Expression) bool {
+	switch e := expr.(type) {
+	case *NumberExpression:
+		return true
+	case *BinaryExpression:
+		return IsConstant(e.Left) && IsConstant(e.Right)
+	case *LogicalExpression:
+		return IsConstant(e.Left) && IsConstant(e.Right)
+	case *FunctionExpression:
+		for _, arg := range e.Args {
+			if !IsConstant(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingExpression records how many times it is evaluated
+type countingExpression struct {
+	value float32
+	count int
+}
+
+func (ce *countingExpression) Evaluate(ctx *Context) (float32, error) {
+	ce.count++
+	return ce.value, nil
+}
+
+func (ce *countingExpression) String() string {
+	return "COUNT"
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		operator  string
+		left      float32
+		expected  float32
+		evaluated int
+	}{
+		{"and", 0, 0, 0},
+		{"and", 1, 1, 1},
+		{"or", 1, 1, 0},
+		{"or", 0, 1, 1},
+	}
+
+	for _, tt := range tests {
+		right := &countingExpression{value: 5}
+		expr := NewLogicalExpression(tt.operator, NewNumberExpression(tt.left), right)
+		value, err := expr.Evaluate(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, value, "%s %v", tt.operator, tt.left)
+		assert.Equal(t, tt.evaluated, right.count, "%s %v", tt.operator, tt.left)
+	}
+}
+
+func TestComparisonsAndNot(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected float32
+	}{
+		{NewBinaryExpression("<", NewNumberExpression(1), NewNumberExpression(2)), 1},
+		{NewBinaryExpression(">", NewNumberExpression(1), NewNumberExpression(2)), 0},
+		{NewBinaryExpression("=", NewNumberExpression(2), NewNumberExpression(2)), 1},
+		{NewFunctionExpression("not", []Expression{NewNumberExpression(0)}), 1},
+		{NewFunctionExpression("not", []Expression{NewNumberExpression(3)}), 0},
+	}
+
+	for _, tt := range tests {
+		value, err := tt.expr.Evaluate(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, value, tt.expr.String())
+	}
+}
+
+func TestMathFunctions(t *testing.T) {
+	apply := func(name string, arg float32) (float32, error) {
+		return NewFunctionExpression(name, []Expression{NewNumberExpression(arg)}).Evaluate(nil)
+	}
+	tests := []struct {
+		name     string
+		arg      float32
+		expected float32
+	}{
+		{"abs", -2.5, 2.5},
+		{"sin", 90, 1},
+		{"sin", 30, 0.5},
+		{"cos", 0, 1},
+		{"cos", 60, 0.5},
+		{"tan", 45, 1},
+		{"sqrt", 9, 3},
+		{"int", 2.6, 2},
+		{"int", -2.6, -2},
+		{"round", 2.6, 3},
+		{"round", 2.5, 3},
+		{"round", -2.6, -3},
+	}
+
+	for _, tt := range tests {
+		value, err := apply(tt.name, tt.arg)
+		assert.NoError(t, err)
+		assert.InDelta(t, tt.expected, value, 1e-6, "%s %v", tt.name, tt.arg)
+	}
+
+	_, err := apply("sqrt", -4)
+	assert.EqualError(t, err, "sqrt: -4 is negative")
+
+	_, err = NewFunctionExpression("sin", []Expression{NewNumberExpression(1), NewNumberExpression(2)}).Evaluate(nil)
+	assert.EqualError(t, err, "sin expects 1 arguments, got 2")
+}
@@ -0,0 +1,139 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Glyphs are drawn on a grid GlyphWidth units wide and GlyphHeight units
+// tall, with the origin at the bottom left. A unit is GlyphUnit pixels at
+// pen size 1 and grows with the pen.
+const (
+	GlyphWidth   = 4
+	GlyphHeight  = 6
+	GlyphSpacing = 2 // Units left between one glyph and the next
+	GlyphUnit    = 2
+)
+
+// stroke is a line drawn through a series of grid points without lifting
+// the pen
+type stroke [][2]float32
+
+// strokeFont holds the strokes of each character WRITEVECTOR can draw. A
+// space has no strokes and only moves the turtle on.
+var strokeFont = map[rune][]stroke{
+	' ': {},
+	'A': {{{0, 0}, {0, 4}, {2, 6}, {4, 4}, {4, 0}}, {{0, 3}, {4, 3}}},
+	'B': {{{0, 0}, {0, 6}, {3, 6}, {4, 5}, {4, 4}, {3, 3}, {0, 3}}, {{3, 3}, {4, 2}, {4, 1}, {3, 0}, {0, 0}}},
+	'C': {{{4, 6}, {0, 6}, {0, 0}, {4, 0}}},
+	'D': {{{0, 0}, {0, 6}, {2, 6}, {4, 4}, {4, 2}, {2, 0}, {0, 0}}},
+	'E': {{{4, 6}, {0, 6}, {0, 0}, {4, 0}}, {{0, 3}, {3, 3}}},
+	'F': {{{4, 6}, {0, 6}, {0, 0}}, {{0, 3}, {3, 3}}},
+	'G': {{{4, 6}, {0, 6}, {0, 0}, {4, 0}, {4, 3}, {2, 3}}},
+	'H': {{{0, 0}, {0, 6}}, {{4, 0}, {4, 6}}, {{0, 3}, {4, 3}}},
+	'I': {{{2, 0}, {2, 6}}},
+	'J': {{{4, 6}, {4, 0}, {0, 0}, {0, 2}}},
+	'K': {{{0, 0}, {0, 6}}, {{4, 6}, {0, 3}, {4, 0}}},
+	'L': {{{0, 6}, {0, 0}, {4, 0}}},
+	'M': {{{0, 0}, {0, 6}, {2, 3}, {4, 6}, {4, 0}}},
+	'N': {{{0, 0}, {0, 6}, {4, 0}, {4, 6}}},
+	'O': {{{0, 0}, {0, 6}, {4, 6}, {4, 0}, {0, 0}}},
+	'P': {{{0, 0}, {0, 6}, {4, 6}, {4, 3}, {0, 3}}},
+	'Q': {{{0, 0}, {0, 6}, {4, 6}, {4, 0}, {0, 0}}, {{2, 2}, {4, 0}}},
+	'R': {{{0, 0}, {0, 6}, {4, 6}, {4, 3}, {0, 3}, {4, 0}}},
+	'S': {{{4, 6}, {0, 6}, {0, 3}, {4, 3}, {4, 0}, {0, 0}}},
+	'T': {{{0, 6}, {4, 6}}, {{2, 6}, {2, 0}}},
+	'U': {{{0, 6}, {0, 0}, {4, 0}, {4, 6}}},
+	'V': {{{0, 6}, {2, 0}, {4, 6}}},
+	'W': {{{0, 6}, {1, 0}, {2, 3}, {3, 0}, {4, 6}}},
+	'X': {{{0, 0}, {4, 6}}, {{0, 6}, {4, 0}}},
+	'Y': {{{0, 6}, {2, 3}, {4, 6}}, {{2, 3}, {2, 0}}},
+	'Z': {{{0, 6}, {4, 6}, {0, 0}, {4, 0}}},
+	'0': {{{0, 0}, {0, 6}, {4, 6}, {4, 0}, {0, 0}, {4, 6}}},
+	'1': {{{1, 5}, {2, 6}, {2, 0}}, {{1, 0}, {3, 0}}},
+	'2': {{{0, 6}, {4, 6}, {4, 3}, {0, 3}, {0, 0}, {4, 0}}},
+	'3': {{{0, 6}, {4, 6}, {4, 0}, {0, 0}}, {{1, 3}, {4, 3}}},
+	'4': {{{0, 6}, {0, 3}, {4, 3}}, {{4, 6}, {4, 0}}},
+	'5': {{{4, 6}, {0, 6}, {0, 3}, {3, 3}, {4, 2}, {4, 0}, {0, 0}}},
+	'6': {{{4, 6}, {0, 6}, {0, 0}, {4, 0}, {4, 3}, {0, 3}}},
+	'7': {{{0, 6}, {4, 6}, {1, 0}}},
+	'8': {{{0, 0}, {0, 6}, {4, 6}, {4, 0}, {0, 0}}, {{0, 3}, {4, 3}}},
+	'9': {{{4, 3}, {0, 3}, {0, 6}, {4, 6}, {4, 0}, {0, 0}}},
+}
+
+// CheckVectorText reports an error if text has a character WRITEVECTOR
+// can't draw. Letters are drawn in upper case.
+func CheckVectorText(text string) error {
+	for _, r := range strings.ToUpper(text) {
+		if _, exists := strokeFont[r]; !exists {
+			return fmt.Errorf("writevector can't draw %q, only letters, digits and spaces", r)
+		}
+	}
+	return nil
+}
+
+// WriteVectorCommand writes text with turtle moves using the built-in
+// stroke font. Letters stand upright along the turtle's heading and follow
+// one another to its right, so text at the home heading reads left to right.
+type WriteVectorCommand struct {
+	Text string
+}
+
+// NewWriteVectorCommand creates a new WriteVectorCommand
+func NewWriteVectorCommand(text string) *WriteVectorCommand {
+	return &WriteVectorCommand{Text: text}
+}
+
+// Execute draws each glyph's strokes, whether or not the pen is down, and
+// leaves the turtle at the start of the glyph after the text with its pen
+// as it was
+func (wvc *WriteVectorCommand) Execute(ctx *Context) error {
+	if err := CheckVectorText(wvc.Text); err != nil {
+		return err
+	}
+
+	t := ctx.Turtle
+	unit := GlyphUnit * t.PenSize()
+	angle := float64(t.GetAngle()) * math.Pi / 180
+	// up runs along the heading and right at a right angle to it
+	upX, upY := float32(math.Cos(angle))*unit, float32(math.Sin(angle))*unit
+	rightX, rightY := upY, -upX
+	originX, originY := t.GetPosition()
+	wasDown := t.IsDown()
+
+	at := func(x, y float32) (float32, float32) {
+		return originX + x*rightX + y*upX, originY + x*rightY + y*upY
+	}
+	// The pen is put back as it was, even when the text runs past the
+	// turtle's coordinate limit
+	defer func() {
+		if wasDown {
+			t.PenDown()
+		} else {
+			t.PenUp()
+		}
+	}()
+
+	for i, r := range strings.ToUpper(wvc.Text) {
+		offset := float32(i * (GlyphWidth + GlyphSpacing))
+		for _, s := range strokeFont[r] {
+			t.PenUp()
+			for j, p := range s {
+				if err := t.Goto(at(offset+p[0], p[1])); err != nil {
+					return err
+				}
+				if j == 0 {
+					t.PenDown()
+				}
+			}
+		}
+	}
+
+	t.PenUp()
+	return t.Goto(at(float32(len([]rune(wvc.Text))*(GlyphWidth+GlyphSpacing)), 0))
+}
+
+func (wvc *WriteVectorCommand) String() string {
+	return fmt.Sprintf("WRITEVECTOR %q", wvc.Text)
+}
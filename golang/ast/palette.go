@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// PaletteSize is the number of entries in a color palette
+const PaletteSize = 16
+
+// NamedColor pairs a standard Logo color name with its value
+type NamedColor struct {
+	Name  string
+	Color color.RGBA
+}
+
+// StandardColors lists the 16 standard Logo colors in palette order
+var StandardColors = [PaletteSize]NamedColor{
+	{Name: "black", Color: color.RGBA{R: 0, G: 0, B: 0, A: 255}},
+	{Name: "blue", Color: color.RGBA{R: 0, G: 0, B: 255, A: 255}},
+	{Name: "green", Color: color.RGBA{R: 0, G: 255, B: 0, A: 255}},
+	{Name: "cyan", Color: color.RGBA{R: 0, G: 255, B: 255, A: 255}},
+	{Name: "red", Color: color.RGBA{R: 255, G: 0, B: 0, A: 255}},
+	{Name: "magenta", Color: color.RGBA{R: 255, G: 0, B: 255, A: 255}},
+	{Name: "yellow", Color: color.RGBA{R: 255, G: 255, B: 0, A: 255}},
+	{Name: "white", Color: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+	{Name: "brown", Color: color.RGBA{R: 155, G: 96, B: 59, A: 255}},
+	{Name: "tan", Color: color.RGBA{R: 197, G: 136, B: 18, A: 255}},
+	{Name: "forest", Color: color.RGBA{R: 100, G: 162, B: 64, A: 255}},
+	{Name: "aqua", Color: color.RGBA{R: 120, G: 187, B: 187, A: 255}},
+	{Name: "salmon", Color: color.RGBA{R: 255, G: 149, B: 119, A: 255}},
+	{Name: "purple", Color: color.RGBA{R: 144, G: 113, B: 208, A: 255}},
+	{Name: "orange", Color: color.RGBA{R: 255, G: 163, B: 0, A: 255}},
+	{Name: "grey", Color: color.RGBA{R: 183, G: 183, B: 183, A: 255}},
+}
+
+// DefaultPalette returns a palette holding the standard colors
+func DefaultPalette() []color.Color {
+	palette := make([]color.Color, PaletteSize)
+	for i, c := range StandardColors {
+		palette[i] = c.Color
+	}
+	return palette
+}
+
+// SetPaletteColorCommand sets the turtle's pen color from a palette entry
+type SetPaletteColorCommand struct {
+	Index int
+}
+
+// NewSetPaletteColorCommand creates a new SetPaletteColorCommand
+func NewSetPaletteColorCommand(index int) *SetPaletteColorCommand {
+	return &SetPaletteColorCommand{Index: index}
+}
+
+// Execute looks up the palette entry and sets the turtle's pen color
+func (spcc *SetPaletteColorCommand) Execute(ctx *Context) error {
+	if spcc.Index < 0 || spcc.Index >= len(ctx.Palette) {
+		return fmt.Errorf("palette index %d out of range", spcc.Index)
+	}
+	ctx.Turtle.SetPenColor(ctx.Palette[spcc.Index])
+	return nil
+}
+
+func (spcc *SetPaletteColorCommand) String() string {
+	return fmt.Sprintf("SETCOLOR %d", spcc.Index)
+}
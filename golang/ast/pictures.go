@@ -0,0 +1,87 @@
+package ast
+
+import (
+	"fmt"
+	"image"
+	_ "image/png" // LOADPICT reads PNG files
+	"os"
+	"path/filepath"
+
+	"github.com/honeylogo/logo/rendering"
+)
+
+// The size of pictures saved by SAVEPICT, matching the drawing area of the
+// editor window
+const (
+	PictureWidth  = 800
+	PictureHeight = 800
+)
+
+// SavePictCommand writes the drawing to a PNG file
+type SavePictCommand struct {
+	Name string
+}
+
+// NewSavePictCommand creates a new SavePictCommand
+func NewSavePictCommand(name string) *SavePictCommand {
+	return &SavePictCommand{Name: name}
+}
+
+// Execute renders every turtle's drawing and saves it in the context's
+// picture directory
+func (spc *SavePictCommand) Execute(ctx *Context) error {
+	path, err := picturePath(ctx, spc.Name)
+	if err != nil {
+		return err
+	}
+	return rendering.SavePNG(ctx.Turtles.Drawing(), path, PictureWidth, PictureHeight)
+}
+
+func (spc *SavePictCommand) String() string {
+	return fmt.Sprintf("SAVEPICT %s", spc.Name)
+}
+
+// LoadPictCommand reads an image file and draws it underneath the drawing
+type LoadPictCommand struct {
+	Name string
+}
+
+// NewLoadPictCommand creates a new LoadPictCommand
+func NewLoadPictCommand(name string) *LoadPictCommand {
+	return &LoadPictCommand{Name: name}
+}
+
+// Execute loads the image from the context's picture directory and sets it
+// as the drawing's background
+func (lpc *LoadPictCommand) Execute(ctx *Context) error {
+	path, err := picturePath(ctx, lpc.Name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("loadpict %s: %w", lpc.Name, err)
+	}
+	ctx.Turtles.Drawing().SetBackground(img)
+	return nil
+}
+
+func (lpc *LoadPictCommand) String() string {
+	return fmt.Sprintf("LOADPICT %s", lpc.Name)
+}
+
+// picturePath resolves a picture name against the context's picture
+// directory. Names must stay inside the directory, so programs can't read or
+// overwrite files elsewhere.
+func picturePath(ctx *Context, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("picture %s must be a relative path inside the picture directory", name)
+	}
+	return filepath.Join(ctx.PictureDir, name), nil
+}
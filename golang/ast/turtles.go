@@ -0,0 +1,91 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NewTurtleCommand creates another turtle, sharing the drawing
+type NewTurtleCommand struct {
+	Name string
+}
+
+// NewNewTurtleCommand creates a new NewTurtleCommand
+func NewNewTurtleCommand(name string) *NewTurtleCommand {
+	return &NewTurtleCommand{Name: name}
+}
+
+// Execute adds the turtle to the context's turtles
+func (ntc *NewTurtleCommand) Execute(ctx *Context) error {
+	_, err := ctx.Turtles.Add(ntc.Name)
+	return err
+}
+
+func (ntc *NewTurtleCommand) String() string {
+	return fmt.Sprintf("NEWTURTLE %s", ntc.Name)
+}
+
+// TellCommand directs subsequent commands at a named turtle
+type TellCommand struct {
+	Name string
+}
+
+// NewTellCommand creates a new TellCommand
+func NewTellCommand(name string) *TellCommand {
+	return &TellCommand{Name: name}
+}
+
+// Execute makes the named turtle the current turtle
+func (tc *TellCommand) Execute(ctx *Context) error {
+	t, err := ctx.Turtles.Get(tc.Name)
+	if err != nil {
+		return err
+	}
+	ctx.Turtle = t
+	return nil
+}
+
+func (tc *TellCommand) String() string {
+	return fmt.Sprintf("TELL %s", tc.Name)
+}
+
+// AskCommand runs a block of commands with a named turtle, then returns to
+// the current turtle
+type AskCommand struct {
+	Name     string
+	Commands []Command
+}
+
+// NewAskCommand creates a new AskCommand
+func NewAskCommand(name string, commands []Command) *AskCommand {
+	return &AskCommand{
+		Name:     name,
+		Commands: commands,
+	}
+}
+
+// Execute runs the commands with the named turtle as the current turtle
+func (ac *AskCommand) Execute(ctx *Context) error {
+	t, err := ctx.Turtles.Get(ac.Name)
+	if err != nil {
+		return err
+	}
+	current := ctx.Turtle
+	ctx.Turtle = t
+	defer func() { ctx.Turtle = current }()
+
+	for _, cmd := range ac.Commands {
+		if err := ctx.Run(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ac *AskCommand) String() string {
+	cmds := make([]string, len(ac.Commands))
+	for i, cmd := range ac.Commands {
+		cmds[i] = cmd.String()
+	}
+	return fmt.Sprintf("ASK %s {\n%s\n}", ac.Name, strings.Join(cmds, "\n"))
+}
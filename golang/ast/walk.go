@@ -0,0 +1,54 @@
+package ast
+
+// Walk traverses a command tree in depth-first order, calling visit for cmd
+// and then for each command nested inside it. Walking stops at the first
+// error returned by visit.
+func Walk(cmd Command, visit func(Command) error) error {
+	if err := visit(cmd); err != nil {
+		return err
+	}
+	for _, child := range Children(cmd) {
+		if err := Walk(child, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the commands nested directly inside cmd
+func Children(cmd Command) []Command {
+	switch c := cmd.(type) {
+	case *Program:
+		return c.Commands
+	case *RepeatCommand:
+		return c.Commands
+	case *ProcedureDefinition:
+		return c.Body
+	case *AskCommand:
+		return c.Commands
+	case *IfCommand:
+		return c.Commands
+	case *WhileCommand:
+		return c.Commands
+	case *IfTrueCommand:
+		return c.Commands
+	case *IfFalseCommand:
+		return c.Commands
+	case *ForEachCommand:
+		return c.Commands
+	case *DoTimesCommand:
+		return c.Commands
+	}
+	return nil
+}
+
+// HasBlock reports whether cmd is a command with a block of commands nested
+// inside it, such as REPEAT, even when the block is empty
+func HasBlock(cmd Command) bool {
+	switch cmd.(type) {
+	case *Program, *RepeatCommand, *ProcedureDefinition, *AskCommand, *IfCommand, *WhileCommand,
+		*IfTrueCommand, *IfFalseCommand, *ForEachCommand, *DoTimesCommand:
+		return true
+	}
+	return false
+}
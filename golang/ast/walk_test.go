@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkCountsNestedCommands(t *testing.T) {
+	program := NewProgram([]Command{
+		NewForwardCommand(10),
+		NewRepeatCommand(4, []Command{
+			NewForwardCommand(20),
+			NewRepeatCommand(2, []Command{
+				NewForwardCommand(5),
+				NewRightCommand(90),
+			}),
+		}),
+		NewProcedureDefinition("square", nil, []Command{
+			NewForwardCommand(50),
+			NewLeftCommand(90),
+		}),
+	})
+
+	forwards := 0
+	visited := 0
+	err := Walk(program, func(cmd Command) error {
+		visited++
+		if _, ok := cmd.(*ForwardCommand); ok {
+			forwards++
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, forwards)
+	assert.Equal(t, 10, visited)
+}
+
+func TestWalkStopsOnError(t *testing.T) {
+	program := NewProgram([]Command{
+		NewForwardCommand(10),
+		NewRightCommand(90),
+		NewForwardCommand(20),
+	})
+
+	stop := errors.New("stop")
+	visited := 0
+	err := Walk(program, func(cmd Command) error {
+		visited++
+		if _, ok := cmd.(*RightCommand); ok {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 3, visited)
+}
@@ -0,0 +1,41 @@
+package drawing
+
+// Origin identifies where a drawing's origin sits on the canvas
+type Origin int
+
+const (
+	// OriginCenter places the origin at the centre of the canvas
+	OriginCenter Origin = iota
+	// OriginTopLeft places the origin at the top left corner of the canvas
+	OriginTopLeft
+	// OriginBottomLeft places the origin at the bottom left corner of the canvas
+	OriginBottomLeft
+)
+
+// CoordinateSystem describes how a drawing's coordinates map onto a canvas
+type CoordinateSystem struct {
+	Origin Origin
+	// YUp is true when +Y points towards the top of the canvas
+	YUp bool
+}
+
+// DefaultCoordinates is the turtle's convention: origin at the centre of the
+// canvas with +Y pointing up
+var DefaultCoordinates = CoordinateSystem{Origin: OriginCenter, YUp: true}
+
+// ToCanvas converts a point in drawing coordinates to canvas pixel
+// coordinates, where (0, 0) is the top left and +Y points down
+func (cs CoordinateSystem) ToCanvas(x, y float64, width, height int) (float64, float64) {
+	var originX, originY float64
+	switch cs.Origin {
+	case OriginCenter:
+		originX, originY = float64(width)/2, float64(height)/2
+	case OriginBottomLeft:
+		originX, originY = 0, float64(height)
+	}
+
+	if cs.YUp {
+		return originX + x, originY - y
+	}
+	return originX + x, originY + y
+}
@@ -0,0 +1,42 @@
+package drawing
+
+import (
+	"image/color"
+	"math"
+)
+
+// Disc is a filled circle stamped onto a drawing, in the drawing's
+// coordinates
+type Disc struct {
+	X, Y   float64 // Centre
+	Radius float64
+	Color  color.Color
+	Turtle int
+}
+
+// bounds returns the square the disc fits in
+func (disc Disc) bounds() Bounds {
+	return Bounds{
+		MinX: disc.X - disc.Radius,
+		MinY: disc.Y - disc.Radius,
+		MaxX: disc.X + disc.Radius,
+		MaxY: disc.Y + disc.Radius,
+	}
+}
+
+// AddDisc stamps a disc onto the drawing
+func (d *Drawing) AddDisc(disc Disc) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	disc.Radius = math.Abs(disc.Radius)
+	d.discs = append(d.discs, disc)
+	d.widen(disc.bounds())
+}
+
+// Discs returns a copy of the discs stamped onto the drawing, in the order
+// they were added
+func (d *Drawing) Discs() []Disc {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return append([]Disc(nil), d.discs...)
+}
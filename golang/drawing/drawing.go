@@ -0,0 +1,285 @@
+// Package drawing records the path traced by a turtle
+package drawing
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"time"
+)
+
+// Point is a position visited by a turtle, along with the pen state that
+// was used to reach it. Turtle identifies which turtle visited the point when
+// several turtles share a drawing. An empty PenPattern means a solid line.
+// Time is when the point was added if the drawing records timestamps, and
+// zero otherwise.
+type Point struct {
+	X, Y       float64
+	PenDown    bool
+	PenColor   color.Color
+	PenSize    float64
+	PenPattern string
+	Turtle     int
+	Time       time.Time
+}
+
+// Drawing is the sequence of points traced by a turtle, along with the
+// coordinate system the points are expressed in. A drawing is safe for
+// concurrent use, so a renderer can read it while a program adds to it.
+type Drawing struct {
+	mutex       sync.RWMutex
+	points      []Point
+	discs       []Disc
+	penDown     bool
+	penColor    color.Color
+	penSize     float64
+	coordinates CoordinateSystem
+	background  image.Image
+	bounds      Bounds // Extent of points and discs, kept up to date as they change
+	bounded     bool   // Whether bounds holds anything yet
+	timestamps  bool   // Whether points are stamped with the time they're added
+	version     int    // Changes whenever recorded points are discarded or moved
+}
+
+// Bounds is the smallest rectangle containing every point and disc of a
+// drawing, in the drawing's coordinates
+type Bounds struct {
+	MinX, MinY float64
+	MaxX, MaxY float64
+}
+
+// Width returns the horizontal extent of the bounds
+func (b Bounds) Width() float64 {
+	return b.MaxX - b.MinX
+}
+
+// Height returns the vertical extent of the bounds
+func (b Bounds) Height() float64 {
+	return b.MaxY - b.MinY
+}
+
+// NewDrawing creates a new drawing starting at the origin with the pen down,
+// using the default coordinate system
+func NewDrawing() *Drawing {
+	d := &Drawing{
+		penDown:     true,
+		penColor:    color.Black,
+		penSize:     1,
+		coordinates: DefaultCoordinates,
+	}
+	d.Add(0, 0)
+	return d
+}
+
+// Add appends a point using the current pen state
+func (d *Drawing) Add(x, y float64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.append(Point{
+		X:        x,
+		Y:        y,
+		PenDown:  d.penDown,
+		PenColor: d.penColor,
+		PenSize:  d.penSize,
+	})
+}
+
+// Reset discards every point and disc and starts the drawing again at
+// (x, y) using the current pen state, so callers can choose where the path
+// begins and whether it is drawn from there
+func (d *Drawing) Reset(x, y float64) {
+	d.mutex.Lock()
+	d.points = nil
+	d.discs = nil
+	d.version++
+	d.bounds = Bounds{}
+	d.bounded = false
+	d.mutex.Unlock()
+	d.Add(x, y)
+}
+
+// AddPoint appends a point with its own pen state and turtle
+func (d *Drawing) AddPoint(p Point) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.append(p)
+}
+
+// append adds a point, stamping it with the time if the drawing records
+// timestamps and it has none, and widens the bounds to include it. The
+// caller must hold the lock.
+func (d *Drawing) append(p Point) {
+	if d.timestamps && p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	d.points = append(d.points, p)
+	d.widen(Bounds{MinX: p.X, MinY: p.Y, MaxX: p.X, MaxY: p.Y})
+}
+
+// widen grows the bounds to include b. The caller must hold the lock.
+func (d *Drawing) widen(b Bounds) {
+	if !d.bounded {
+		d.bounds = b
+		d.bounded = true
+		return
+	}
+	d.bounds.MinX = min(d.bounds.MinX, b.MinX)
+	d.bounds.MinY = min(d.bounds.MinY, b.MinY)
+	d.bounds.MaxX = max(d.bounds.MaxX, b.MaxX)
+	d.bounds.MaxY = max(d.bounds.MaxY, b.MaxY)
+}
+
+// recomputeBounds finds the bounds again after points or discs are removed
+// or moved. The caller must hold the lock.
+func (d *Drawing) recomputeBounds() {
+	d.bounds = Bounds{}
+	d.bounded = false
+	for _, p := range d.points {
+		d.widen(Bounds{MinX: p.X, MinY: p.Y, MaxX: p.X, MaxY: p.Y})
+	}
+	for _, disc := range d.discs {
+		d.widen(disc.bounds())
+	}
+}
+
+// Bounds returns the smallest rectangle containing every point, whether it
+// was reached with the pen up or down, and every disc. The bounds are kept
+// as the drawing grows, so this doesn't scan it. An empty drawing has zero
+// bounds.
+func (d *Drawing) Bounds() Bounds {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.bounds
+}
+
+// SetPenDown sets whether subsequent points are drawn
+func (d *Drawing) SetPenDown(down bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.penDown = down
+}
+
+// SetPenColor sets the color of subsequent points
+func (d *Drawing) SetPenColor(c color.Color) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.penColor = c
+}
+
+// SetPenSize sets the size of subsequent points
+func (d *Drawing) SetPenSize(size float64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.penSize = size
+}
+
+// SetTimestamps sets whether points added from now on are stamped with the
+// time they're added, for syncing playback or measuring drawing speed.
+// It's off by default, so drawings don't pay for reading the clock.
+func (d *Drawing) SetTimestamps(on bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.timestamps = on
+}
+
+// Timestamps reports whether points are stamped with the time they're added
+func (d *Drawing) Timestamps() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.timestamps
+}
+
+// Coordinates returns the coordinate system the points are expressed in
+func (d *Drawing) Coordinates() CoordinateSystem {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.coordinates
+}
+
+// SetCoordinates sets the coordinate system the points are expressed in
+func (d *Drawing) SetCoordinates(cs CoordinateSystem) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.coordinates = cs
+}
+
+// Background returns the image drawn underneath the points, or nil if none
+// has been set
+func (d *Drawing) Background() image.Image {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.background
+}
+
+// SetBackground sets an image to draw underneath the points, with its top
+// left corner at the top left of the canvas
+func (d *Drawing) SetBackground(img image.Image) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.background = img
+}
+
+// Points returns a copy of the recorded points, which later additions to
+// the drawing don't change
+func (d *Drawing) Points() []Point {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return append([]Point(nil), d.points...)
+}
+
+// pointsSince returns a copy of the points from index start on, along with
+// the drawing's version. Points added since a call with the same version
+// come after the ones it returned.
+func (d *Drawing) pointsSince(start int) ([]Point, int) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	if start >= len(d.points) {
+		return nil, d.version
+	}
+	return append([]Point(nil), d.points[start:]...), d.version
+}
+
+// Checkpoint is an opaque record of a drawing's state, created by Snapshot
+type Checkpoint struct {
+	pointCount int
+	discCount  int
+	penDown    bool
+	penColor   color.Color
+	penSize    float64
+}
+
+// Snapshot records the current state of the drawing so it can be restored
+// later. Only the number of points and discs is kept, not a copy of them.
+func (d *Drawing) Snapshot() Checkpoint {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return Checkpoint{
+		pointCount: len(d.points),
+		discCount:  len(d.discs),
+		penDown:    d.penDown,
+		penColor:   d.penColor,
+		penSize:    d.penSize,
+	}
+}
+
+// Restore rolls the drawing back to a checkpoint, discarding any points
+// and discs added since it was taken
+func (d *Drawing) Restore(c Checkpoint) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if c.pointCount > len(d.points) {
+		return fmt.Errorf("checkpoint has %d points but drawing only has %d", c.pointCount, len(d.points))
+	}
+	if c.discCount > len(d.discs) {
+		return fmt.Errorf("checkpoint has %d discs but drawing only has %d", c.discCount, len(d.discs))
+	}
+	d.points = d.points[:c.pointCount]
+	d.discs = d.discs[:c.discCount]
+	d.version++
+	d.recomputeBounds()
+	d.penDown = c.penDown
+	d.penColor = c.penColor
+	d.penSize = c.penSize
+	return nil
+}
@@ -0,0 +1,269 @@
+package drawing
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	d := NewDrawing()
+	d.Add(0, 10)
+	checkpoint := d.Snapshot()
+
+	d.SetPenColor(color.RGBA{R: 255, A: 255})
+	d.SetPenSize(3)
+	d.SetPenDown(false)
+	d.Add(10, 10)
+	assert.Len(t, d.Points(), 3)
+
+	assert.NoError(t, d.Restore(checkpoint))
+	assert.Len(t, d.Points(), 2)
+	assert.Equal(t, Point{X: 0, Y: 10, PenDown: true, PenColor: color.Black, PenSize: 1}, d.Points()[1])
+
+	// Pen state is restored along with the points
+	d.Add(20, 10)
+	last := d.Points()[2]
+	assert.True(t, last.PenDown)
+	assert.Equal(t, color.Black, last.PenColor)
+	assert.Equal(t, 1.0, last.PenSize)
+}
+
+func TestRestoreStaleCheckpoint(t *testing.T) {
+	d := NewDrawing()
+	early := d.Snapshot()
+	d.Add(0, 10)
+	late := d.Snapshot()
+
+	assert.NoError(t, d.Restore(early))
+	assert.Error(t, d.Restore(late))
+	assert.Len(t, d.Points(), 1)
+}
+
+func TestToCanvas(t *testing.T) {
+	x, y := DefaultCoordinates.ToCanvas(0, 0, 200, 100)
+	assert.Equal(t, 100.0, x)
+	assert.Equal(t, 50.0, y)
+
+	x, y = CoordinateSystem{Origin: OriginBottomLeft, YUp: true}.ToCanvas(10, 20, 200, 100)
+	assert.Equal(t, 10.0, x)
+	assert.Equal(t, 80.0, y)
+}
+
+func TestSegmentsSquare(t *testing.T) {
+	d := NewDrawing()
+	d.Add(0, 100)
+	d.Add(100, 100)
+	d.Add(100, 0)
+	d.Add(0, 0)
+
+	assert.Equal(t, []Segment{
+		{StartX: 0, StartY: 0, EndX: 0, EndY: 100, Color: color.Black, Width: 1},
+		{StartX: 0, StartY: 100, EndX: 100, EndY: 100, Color: color.Black, Width: 1},
+		{StartX: 100, StartY: 100, EndX: 100, EndY: 0, Color: color.Black, Width: 1},
+		{StartX: 100, StartY: 0, EndX: 0, EndY: 0, Color: color.Black, Width: 1},
+	}, d.Segments())
+}
+
+func TestSegmentTracker(t *testing.T) {
+	d := NewDrawing()
+	tracker := NewSegmentTracker(d)
+	assert.Empty(t, tracker.Next())
+
+	d.Add(0, 100)
+	d.AddPoint(Point{X: 50, Y: 0, PenDown: true, PenColor: color.Black, PenSize: 1, Turtle: 1})
+	d.Add(100, 100)
+	assert.Equal(t, []Segment{
+		{StartX: 0, StartY: 0, EndX: 0, EndY: 100, Color: color.Black, Width: 1},
+		{StartX: 0, StartY: 100, EndX: 100, EndY: 100, Color: color.Black, Width: 1},
+	}, tracker.Next())
+	assert.Empty(t, tracker.Next())
+
+	// Each turtle carries on from its own last point
+	d.AddPoint(Point{X: 50, Y: 50, PenDown: true, PenColor: color.Black, PenSize: 1, Turtle: 1})
+	d.Add(100, 0)
+	assert.Equal(t, []Segment{
+		{StartX: 50, StartY: 0, EndX: 50, EndY: 50, Color: color.Black, Width: 1, Turtle: 1},
+		{StartX: 100, StartY: 100, EndX: 100, EndY: 0, Color: color.Black, Width: 1},
+	}, tracker.Next())
+
+	// Changed points start the tracker again
+	d.Translate(10, 0)
+	assert.Equal(t, d.Segments(), tracker.Next())
+	d.Reset(0, 0)
+	d.Add(0, 10)
+	assert.Equal(t, []Segment{
+		{StartX: 0, StartY: 0, EndX: 0, EndY: 10, Color: color.Black, Width: 1},
+	}, tracker.Next())
+}
+
+func TestSegmentsSkipPenUp(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	d := NewDrawing()
+	d.SetPenDown(false)
+	d.Add(50, 0)
+	d.SetPenDown(true)
+	d.SetPenColor(red)
+	d.SetPenSize(3)
+	d.Add(50, 50)
+	d.Add(50, 50)
+
+	assert.Equal(t, []Segment{
+		{StartX: 50, StartY: 0, EndX: 50, EndY: 50, Color: red, Width: 3},
+	}, d.Segments())
+}
+
+// TestConcurrentAccess reads the drawing while points are added, which
+// `go test -race` reports if the drawing isn't synchronized
+func TestConcurrentAccess(t *testing.T) {
+	d := NewDrawing()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			d.SetPenDown(i%3 != 0)
+			d.SetPenColor(color.RGBA{R: uint8(i), A: 255})
+			d.Add(float64(i), float64(i))
+		}
+	}()
+
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		points := d.Points()
+		assert.NotEmpty(t, points)
+		d.Segments()
+		d.Snapshot()
+	}
+
+	assert.Len(t, d.Points(), 1001)
+
+	// Points are copied, so the caller's slice doesn't change later
+	points := d.Points()
+	d.Add(-1, -1)
+	assert.Len(t, points, 1001)
+}
+
+func TestReset(t *testing.T) {
+	d := NewDrawing()
+	d.Add(10, 10)
+
+	d.SetPenDown(false)
+	d.Reset(100, 100)
+	d.Add(50, 50)
+	d.SetPenDown(true)
+	d.Add(50, 80)
+
+	// Nothing is drawn from the origin or the starting point
+	assert.Len(t, d.Points(), 3)
+	assert.Equal(t, []Segment{
+		{StartX: 50, StartY: 50, EndX: 50, EndY: 80, Color: color.Black, Width: 1},
+	}, d.Segments())
+}
+
+func TestBounds(t *testing.T) {
+	d := NewDrawing()
+	assert.Equal(t, Bounds{}, d.Bounds())
+
+	d.Add(10, -5)
+	d.SetPenDown(false)
+	d.Add(-20, 30)
+	d.AddPoint(Point{X: 5, Y: 40, Turtle: 1})
+	assert.Equal(t, Bounds{MinX: -20, MinY: -5, MaxX: 10, MaxY: 40}, d.Bounds())
+	assert.Equal(t, 30.0, d.Bounds().Width())
+	assert.Equal(t, 45.0, d.Bounds().Height())
+
+	checkpoint := d.Snapshot()
+	d.Add(100, 100)
+	assert.NoError(t, d.Restore(checkpoint))
+	assert.Equal(t, Bounds{MinX: -20, MinY: -5, MaxX: 10, MaxY: 40}, d.Bounds())
+
+	d.Reset(3, 4)
+	assert.Equal(t, Bounds{MinX: 3, MinY: 4, MaxX: 3, MaxY: 4}, d.Bounds())
+	d.Add(6, 8)
+	d.Scale(2)
+	assert.Equal(t, Bounds{MinX: 6, MinY: 8, MaxX: 12, MaxY: 16}, d.Bounds())
+}
+
+func TestDiscs(t *testing.T) {
+	d := NewDrawing()
+	red := color.RGBA{R: 255, A: 255}
+	checkpoint := d.Snapshot()
+	d.AddDisc(Disc{X: 10, Y: 20, Radius: -5, Color: red})
+
+	// The radius is kept positive and the disc widens the bounds
+	assert.Equal(t, []Disc{{X: 10, Y: 20, Radius: 5, Color: red}}, d.Discs())
+	assert.Equal(t, Bounds{MinX: 0, MinY: 0, MaxX: 15, MaxY: 25}, d.Bounds())
+
+	d.Scale(2)
+	assert.Equal(t, []Disc{{X: 20, Y: 40, Radius: 10, Color: red}}, d.Discs())
+
+	assert.NoError(t, d.Restore(checkpoint))
+	assert.Empty(t, d.Discs())
+	assert.Equal(t, Bounds{}, d.Bounds())
+
+	d.AddDisc(Disc{Radius: 1})
+	d.Reset(0, 0)
+	assert.Empty(t, d.Discs())
+}
+
+func TestTimestamps(t *testing.T) {
+	d := NewDrawing()
+	assert.False(t, d.Timestamps())
+	d.Add(10, 0)
+
+	d.SetTimestamps(true)
+	assert.True(t, d.Timestamps())
+	before := time.Now()
+	for i := 1; i <= 5; i++ {
+		d.Add(10, float64(i))
+	}
+	stamped := time.Unix(0, 0).Add(time.Hour)
+	d.AddPoint(Point{X: 20, Time: stamped})
+
+	points := d.Points()
+	assert.True(t, points[0].Time.IsZero())
+	assert.True(t, points[1].Time.IsZero())
+	previous := before
+	for _, p := range points[2:7] {
+		assert.False(t, p.Time.Before(previous), "point %v", p)
+		previous = p.Time
+	}
+	// A point added with its own time keeps it
+	assert.Equal(t, stamped, points[7].Time)
+
+	d.SetTimestamps(false)
+	d.Add(30, 0)
+	assert.True(t, d.Points()[8].Time.IsZero())
+}
+
+func TestPolylines(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	d := NewDrawing()
+	d.Add(0, 10)
+	d.Add(10, 10)
+	d.SetPenColor(red)
+	d.Add(10, 0)
+	d.SetPenDown(false)
+	d.Add(20, 0)
+	d.SetPenDown(true)
+	d.Add(30, 0)
+	d.AddPoint(Point{X: 5, Y: 5, Turtle: 1})
+	d.AddPoint(Point{X: 5, Y: 15, PenDown: true, PenColor: red, PenSize: 1, Turtle: 1})
+	d.Add(40, 0)
+
+	// The pen changing, lifting or another turtle drawing each start a new
+	// polyline
+	assert.Equal(t, []Polyline{
+		{Vertices: []Vertex{{0, 0}, {0, 10}, {10, 10}}, Color: color.Black, Width: 1},
+		{Vertices: []Vertex{{10, 10}, {10, 0}}, Color: red, Width: 1},
+		{Vertices: []Vertex{{20, 0}, {30, 0}}, Color: red, Width: 1},
+		{Vertices: []Vertex{{5, 5}, {5, 15}}, Color: red, Width: 1, Turtle: 1},
+		{Vertices: []Vertex{{30, 0}, {40, 0}}, Color: red, Width: 1},
+	}, d.Polylines())
+}
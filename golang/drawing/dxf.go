@@ -0,0 +1,43 @@
+package drawing
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ToDXF writes the drawing's pen-down segments as LINE entities in a
+// minimal DXF file, holding only an ENTITIES section, for importing into a
+// CAD program. Coordinates are written as they are in the drawing, on
+// layer 0 with a z of 0. Discs aren't included.
+func (d *Drawing) ToDXF() string {
+	var b strings.Builder
+	dxfGroup(&b, 0, "SECTION")
+	dxfGroup(&b, 2, "ENTITIES")
+	for _, s := range d.Segments() {
+		dxfGroup(&b, 0, "LINE")
+		dxfGroup(&b, 8, "0")
+		dxfGroup(&b, 10, dxfNumber(s.StartX))
+		dxfGroup(&b, 20, dxfNumber(s.StartY))
+		dxfGroup(&b, 30, "0")
+		dxfGroup(&b, 11, dxfNumber(s.EndX))
+		dxfGroup(&b, 21, dxfNumber(s.EndY))
+		dxfGroup(&b, 31, "0")
+	}
+	dxfGroup(&b, 0, "ENDSEC")
+	dxfGroup(&b, 0, "EOF")
+	return b.String()
+}
+
+// dxfGroup writes a DXF group: its code on one line and its value on the
+// next
+func dxfGroup(b *strings.Builder, code int, value string) {
+	b.WriteString(strconv.Itoa(code))
+	b.WriteString("\n")
+	b.WriteString(value)
+	b.WriteString("\n")
+}
+
+// dxfNumber writes a coordinate with as many digits as it needs
+func dxfNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
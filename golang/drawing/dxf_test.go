@@ -0,0 +1,42 @@
+package drawing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDXFSquare(t *testing.T) {
+	d := NewDrawing()
+	d.Add(0, 100)
+	d.Add(100, 100)
+	d.Add(100, 0)
+	d.Add(0, 0)
+
+	dxf := d.ToDXF()
+	lines := strings.Split(strings.TrimSuffix(dxf, "\n"), "\n")
+	assert.Equal(t, []string{"0", "SECTION", "2", "ENTITIES"}, lines[:4])
+	assert.Equal(t, []string{"0", "ENDSEC", "0", "EOF"}, lines[len(lines)-4:])
+	assert.Equal(t, 4, strings.Count(dxf, "0\nLINE\n"))
+
+	// The first edge runs up from the origin
+	assert.Equal(t, []string{
+		"0", "LINE", "8", "0",
+		"10", "0", "20", "0", "30", "0",
+		"11", "0", "21", "100", "31", "0",
+	}, lines[4:20])
+	assert.Contains(t, dxf, "10\n100\n20\n100\n30\n0\n11\n100\n21\n0\n")
+}
+
+func TestDXFSkipsPenUp(t *testing.T) {
+	d := NewDrawing()
+	d.SetPenDown(false)
+	d.Add(10, 10)
+	d.SetPenDown(true)
+	d.Add(12.5, -3)
+
+	assert.Equal(t, 1, strings.Count(d.ToDXF(), "0\nLINE\n"))
+	assert.Contains(t, d.ToDXF(), "10\n10\n20\n10\n30\n0\n11\n12.5\n21\n-3\n31\n0\n")
+	assert.Equal(t, "0\nSECTION\n2\nENTITIES\n0\nENDSEC\n0\nEOF\n", NewDrawing().ToDXF())
+}
@@ -0,0 +1,81 @@
+package drawing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GCodeOptions controls the G-code written by ToGCode
+type GCodeOptions struct {
+	// Units are "mm" or "in", and apply to coordinates as they are in the
+	// drawing, so scale the drawing first to fit the plotter's bed
+	Units string
+	// FeedRate is the speed pen-down moves are drawn at, in units per
+	// minute
+	FeedRate float64
+	// PenUpZ and PenDownZ are the heights the pen is lifted to and lowered
+	// to
+	PenUpZ   float64
+	PenDownZ float64
+}
+
+// DefaultGCodeOptions draws in millimetres at 1000 mm a minute, lifting the
+// pen 5 mm off the paper
+var DefaultGCodeOptions = GCodeOptions{
+	Units:    "mm",
+	FeedRate: 1000,
+	PenUpZ:   5,
+	PenDownZ: 0,
+}
+
+// gcodeUnits maps each unit ToGCode accepts to the command selecting it
+var gcodeUnits = map[string]string{
+	"mm": "G21",
+	"in": "G20",
+}
+
+// ToGCode writes G-code for a pen plotter that draws the drawing. Each
+// polyline is reached with the pen up by a rapid G0 move, then the pen is
+// lowered and the polyline drawn with G1 moves at the feed rate, and the
+// pen lifted again. Discs aren't drawn.
+func (d *Drawing) ToGCode(opts GCodeOptions) (string, error) {
+	units, ok := gcodeUnits[opts.Units]
+	if !ok {
+		return "", fmt.Errorf("unknown G-code units %q, valid units are: mm, in", opts.Units)
+	}
+	if opts.FeedRate <= 0 {
+		return "", fmt.Errorf("feed rate must be more than 0, got %s", gcodeNumber(opts.FeedRate))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\nG90\n", units)
+	fmt.Fprintf(&b, "G0 Z%s\n", gcodeNumber(opts.PenUpZ))
+	for _, polyline := range d.Polylines() {
+		start := polyline.Vertices[0]
+		fmt.Fprintf(&b, "G0 X%s Y%s\n", gcodeNumber(start.X), gcodeNumber(start.Y))
+		fmt.Fprintf(&b, "G0 Z%s\n", gcodeNumber(opts.PenDownZ))
+		for i, v := range polyline.Vertices[1:] {
+			fmt.Fprintf(&b, "G1 X%s Y%s", gcodeNumber(v.X), gcodeNumber(v.Y))
+			if i == 0 {
+				// The feed rate is modal, so setting it once per polyline
+				// keeps later moves at the same speed
+				fmt.Fprintf(&b, " F%s", gcodeNumber(opts.FeedRate))
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "G0 Z%s\n", gcodeNumber(opts.PenUpZ))
+	}
+	return b.String(), nil
+}
+
+// gcodeNumber writes a number to three decimal places at most, dropping
+// trailing zeros and the noise left by turns
+func gcodeNumber(value float64) string {
+	text := strconv.FormatFloat(value, 'f', 3, 64)
+	text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	if text == "-0" {
+		return "0"
+	}
+	return text
+}
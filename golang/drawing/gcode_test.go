@@ -0,0 +1,61 @@
+package drawing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCodeSquare(t *testing.T) {
+	d := NewDrawing()
+	d.SetPenDown(false)
+	d.Add(10, 10)
+	d.SetPenDown(true)
+	d.Add(10, 20)
+	d.Add(20, 20)
+	d.Add(20, 10)
+	d.Add(10, 10)
+
+	gcode, err := d.ToGCode(DefaultGCodeOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, `G21
+G90
+G0 Z5
+G0 X10 Y10
+G0 Z0
+G1 X10 Y20 F1000
+G1 X20 Y20
+G1 X20 Y10
+G1 X10 Y10
+G0 Z5
+`, gcode)
+}
+
+func TestGCodePenTransitions(t *testing.T) {
+	d := NewDrawing()
+	d.Add(0, 10.5)
+	d.SetPenDown(false)
+	d.Add(-5, 0)
+	d.SetPenDown(true)
+	d.Add(-5, -0.0001)
+
+	gcode, err := d.ToGCode(GCodeOptions{Units: "in", FeedRate: 250.5, PenUpZ: 0.2, PenDownZ: -0.05})
+	assert.NoError(t, err)
+	assert.Equal(t, `G20
+G90
+G0 Z0.2
+G0 X0 Y0
+G0 Z-0.05
+G1 X0 Y10.5 F250.5
+G0 Z0.2
+G0 X-5 Y0
+G0 Z-0.05
+G1 X-5 Y0 F250.5
+G0 Z0.2
+`, gcode)
+
+	_, err = d.ToGCode(GCodeOptions{Units: "cm", FeedRate: 100})
+	assert.ErrorContains(t, err, `unknown G-code units "cm"`)
+	_, err = d.ToGCode(GCodeOptions{Units: "mm"})
+	assert.ErrorContains(t, err, "feed rate must be more than 0, got 0")
+}
@@ -0,0 +1,37 @@
+package drawing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// HPGLUnitsPerMM is how many plotter units ToHPGL writes for each unit of
+// the drawing, taken as a millimetre. HP plotters step in 0.025 mm units.
+const HPGLUnitsPerMM = 40
+
+// ToHPGL writes HPGL for a vintage HP pen plotter that draws the drawing
+// with pen 1, in absolute plotter units. Each polyline is reached with the
+// pen up and drawn with it down. Discs aren't drawn.
+func (d *Drawing) ToHPGL() string {
+	var b strings.Builder
+	b.WriteString("IN;SP1;PU;\n")
+	for _, polyline := range d.Polylines() {
+		start := polyline.Vertices[0]
+		fmt.Fprintf(&b, "PU;PA%s;\n", hpglPoint(start))
+		coordinates := make([]string, len(polyline.Vertices)-1)
+		for i, v := range polyline.Vertices[1:] {
+			coordinates[i] = hpglPoint(v)
+		}
+		fmt.Fprintf(&b, "PD;PA%s;\n", strings.Join(coordinates, ","))
+	}
+	b.WriteString("PU;SP0;\n")
+	return b.String()
+}
+
+// hpglPoint writes a vertex as a pair of whole plotter units
+func hpglPoint(v Vertex) string {
+	x := int64(math.Round(v.X * HPGLUnitsPerMM))
+	y := int64(math.Round(v.Y * HPGLUnitsPerMM))
+	return fmt.Sprintf("%d,%d", x, y)
+}
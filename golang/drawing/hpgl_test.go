@@ -0,0 +1,31 @@
+package drawing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHPGL(t *testing.T) {
+	d := NewDrawing()
+	d.Add(0, 10)
+	d.Add(10.01, 10)
+	d.SetPenDown(false)
+	d.Add(-5, 0)
+	d.SetPenDown(true)
+	d.Add(-5, -2.5)
+
+	hpgl := d.ToHPGL()
+	assert.True(t, strings.HasPrefix(hpgl, "IN;SP1;PU;\n"), hpgl)
+	// Millimetres become 0.025 mm plotter units, rounded to whole units
+	assert.Equal(t, `IN;SP1;PU;
+PU;PA0,0;
+PD;PA0,400,400,400;
+PU;PA-200,0;
+PD;PA-200,-100;
+PU;SP0;
+`, hpgl)
+
+	assert.Equal(t, "IN;SP1;PU;\nPU;SP0;\n", NewDrawing().ToHPGL())
+}
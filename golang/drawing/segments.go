@@ -0,0 +1,117 @@
+package drawing
+
+import (
+	"image/color"
+)
+
+// Segment is a straight line drawn between two points
+type Segment struct {
+	StartX, StartY float64
+	EndX, EndY     float64
+	Color          color.Color
+	Width          float64
+	Pattern        string
+	Turtle         int
+}
+
+// Segments returns the lines drawn between consecutive points of each turtle
+// where the pen was down. Zero-length moves draw nothing and are left out.
+func (d *Drawing) Segments() []Segment {
+	return appendSegments([]Segment{}, map[int]Point{}, d.Points())
+}
+
+// appendSegments appends the segments drawn by points to segments. last
+// holds the point each turtle reached before them, and is updated as they
+// are read.
+func appendSegments(segments []Segment, last map[int]Point, points []Point) []Segment {
+	for _, end := range points {
+		start, seen := last[end.Turtle]
+		last[end.Turtle] = end
+		if !seen || !end.PenDown || (start.X == end.X && start.Y == end.Y) {
+			continue
+		}
+		segments = append(segments, Segment{
+			StartX:  start.X,
+			StartY:  start.Y,
+			EndX:    end.X,
+			EndY:    end.Y,
+			Color:   end.PenColor,
+			Width:   end.PenSize,
+			Pattern: end.PenPattern,
+			Turtle:  end.Turtle,
+		})
+	}
+	return segments
+}
+
+// SegmentTracker follows a drawing as it grows, reading only the points
+// added since it was last asked, so following a long drawing step by step
+// doesn't rebuild every segment each time
+type SegmentTracker struct {
+	drawing *Drawing
+	read    int           // Number of points read so far
+	version int           // Drawing version the points were read from
+	last    map[int]Point // Last point read for each turtle
+}
+
+// NewSegmentTracker creates a tracker that has read nothing of d yet
+func NewSegmentTracker(d *Drawing) *SegmentTracker {
+	return &SegmentTracker{drawing: d, version: -1}
+}
+
+// Next returns the segments drawn since the last call. If the drawing's
+// points were discarded or moved in between, as a reset does, it starts
+// again and returns every segment of the drawing.
+func (t *SegmentTracker) Next() []Segment {
+	points, version := t.drawing.pointsSince(t.read)
+	if version != t.version {
+		points, version = t.drawing.pointsSince(0)
+		t.read = 0
+		t.version = version
+		t.last = map[int]Point{}
+	}
+	t.read += len(points)
+	return appendSegments([]Segment{}, t.last, points)
+}
+
+// Vertex is a corner of a polyline
+type Vertex struct {
+	X, Y float64
+}
+
+// Polyline is a run of segments that each start where the last one ended,
+// drawn by one turtle with the same pen, so a plotter can draw it without
+// lifting the pen
+type Polyline struct {
+	Vertices []Vertex
+	Color    color.Color
+	Width    float64
+	Pattern  string
+	Turtle   int
+}
+
+// Polylines joins the drawing's segments into polylines, in the order they
+// were drawn. A new polyline starts wherever the pen was lifted, the pen
+// changed or another turtle drew in between.
+func (d *Drawing) Polylines() []Polyline {
+	polylines := []Polyline{}
+	for _, s := range d.Segments() {
+		if n := len(polylines); n > 0 {
+			last := &polylines[n-1]
+			end := last.Vertices[len(last.Vertices)-1]
+			if end.X == s.StartX && end.Y == s.StartY && last.Color == s.Color &&
+				last.Width == s.Width && last.Pattern == s.Pattern && last.Turtle == s.Turtle {
+				last.Vertices = append(last.Vertices, Vertex{X: s.EndX, Y: s.EndY})
+				continue
+			}
+		}
+		polylines = append(polylines, Polyline{
+			Vertices: []Vertex{{X: s.StartX, Y: s.StartY}, {X: s.EndX, Y: s.EndY}},
+			Color:    s.Color,
+			Width:    s.Width,
+			Pattern:  s.Pattern,
+			Turtle:   s.Turtle,
+		})
+	}
+	return polylines
+}
@@ -0,0 +1,51 @@
+package drawing
+
+import "math"
+
+// Scale multiplies the coordinates of every point and disc by factor, about
+// the origin, along with the radius of each disc. Pen sizes are left alone.
+func (d *Drawing) Scale(factor float64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i := range d.discs {
+		d.discs[i].Radius *= math.Abs(factor)
+	}
+	d.transform(func(x, y float64) (float64, float64) {
+		return x * factor, y * factor
+	})
+}
+
+// Translate moves every point and disc by dx and dy
+func (d *Drawing) Translate(dx, dy float64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.transform(func(x, y float64) (float64, float64) {
+		return x + dx, y + dy
+	})
+}
+
+// Rotate turns every point and disc about the origin by degrees,
+// counterclockwise when +Y points up. Points don't record headings, so only
+// positions change.
+func (d *Drawing) Rotate(degrees float64) {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.transform(func(x, y float64) (float64, float64) {
+		return x*cos - y*sin, x*sin + y*cos
+	})
+}
+
+// transform replaces the coordinates of every point and disc centre with f
+// applied to them. The caller holds the mutex, so the whole change is seen
+// at once.
+func (d *Drawing) transform(f func(x, y float64) (float64, float64)) {
+	for i := range d.points {
+		d.points[i].X, d.points[i].Y = f(d.points[i].X, d.points[i].Y)
+	}
+	for i := range d.discs {
+		d.discs[i].X, d.discs[i].Y = f(d.discs[i].X, d.discs[i].Y)
+	}
+	d.version++
+	d.recomputeBounds()
+}
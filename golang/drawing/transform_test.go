@@ -0,0 +1,51 @@
+package drawing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unitSquare returns a drawing of a square with sides of length 1
+func unitSquare() *Drawing {
+	d := NewDrawing()
+	d.Add(1, 0)
+	d.Add(1, 1)
+	d.Add(0, 1)
+	d.Add(0, 0)
+	return d
+}
+
+// assertPositions checks the drawing's points are at the expected positions
+func assertPositions(t *testing.T, expected [][2]float64, d *Drawing) {
+	t.Helper()
+	points := d.Points()
+	if assert.Len(t, points, len(expected)) {
+		for i, p := range points {
+			assert.InDelta(t, expected[i][0], p.X, 1e-9, "point %d", i)
+			assert.InDelta(t, expected[i][1], p.Y, 1e-9, "point %d", i)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	d := unitSquare()
+	d.Scale(2)
+	assertPositions(t, [][2]float64{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}, d)
+	assert.Equal(t, 1.0, d.Points()[1].PenSize)
+}
+
+func TestTranslate(t *testing.T) {
+	d := unitSquare()
+	d.Translate(3, -1)
+	assertPositions(t, [][2]float64{{3, -1}, {4, -1}, {4, 0}, {3, 0}, {3, -1}}, d)
+}
+
+func TestRotate(t *testing.T) {
+	d := unitSquare()
+	d.Rotate(90)
+	assertPositions(t, [][2]float64{{0, 0}, {0, 1}, {-1, 1}, {-1, 0}, {0, 0}}, d)
+
+	d.Rotate(-90)
+	assertPositions(t, [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}, d)
+}
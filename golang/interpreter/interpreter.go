@@ -1,44 +1,208 @@
 package interpreter
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/drawing"
 	"github.com/honeylogo/logo/parser"
 	"github.com/honeylogo/logo/turtle"
-	"github.com/honeylogo/logo/drawing"
 )
 
 // Interpreter represents the Logo language interpreter
 type Interpreter struct {
-	turtle     *turtle.Turtle
-	procedures map[string][]parser.Token
-	callStack  []string
-	context    *ast.Context
+	turtle    *turtle.Turtle
+	callStack []string
+	context   *ast.Context
+	history   []ast.Command
+	observer  func(cmd ast.Command, ctx *ast.Context)
+	onStep    func(cmd ast.Command, s Snapshot)
+	trace     io.Writer
 }
 
 // New creates a new interpreter
 func New() *Interpreter {
-	t := turtle.New()
-	return &Interpreter{
-		turtle:     t,
-		procedures: make(map[string][]parser.Token),
-		context:    ast.NewContext(),
+	t := turtle.NewHeadless()
+	i := &Interpreter{
+		turtle:  t,
+		context: ast.NewContext(t),
 	}
+	i.context.Observer = i.observe
+	return i
 }
 
 // Execute runs a Logo command string
 func (i *Interpreter) Execute(cmdStr string) (*drawing.Drawing, error) {
 	// Parse the input into an AST program
-	program, err := parser.ParseProgram(cmdStr)
+	program, err := parser.ParseProgramWithProcedures(cmdStr, i.arities())
 	if err != nil {
 		return nil, err
 	}
 
-	// Execute the program
-	return program.Execute(i.context)
+	// Execute the program, recording each top-level command that succeeds
+	i.context.Steps = 0
+	for _, cmd := range program.Commands {
+		if err := i.ExecuteCommand(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return i.context.Turtles.Drawing(), nil
+}
+
+// ExecuteContext runs a Logo command string like Execute, stopping the
+// program with ctx's error if ctx is done before the program finishes
+func (i *Interpreter) ExecuteContext(ctx context.Context, cmdStr string) (*drawing.Drawing, error) {
+	i.context.Done = ctx.Done()
+	defer func() { i.context.Done = nil }()
+
+	d, err := i.Execute(cmdStr)
+	if errors.Is(err, ast.ErrStopped) {
+		return nil, ctx.Err()
+	}
+	return d, err
+}
+
+// History returns the top-level commands executed so far, in order
+func (i *Interpreter) History() []ast.Command {
+	return slices.Clone(i.history)
+}
+
+// ClearHistory forgets the commands executed so far, without undoing them
+func (i *Interpreter) ClearHistory() {
+	i.history = nil
+}
+
+// Replay starts again with a fresh turtle, variables and procedures, then
+// re-runs every command in the history. Settings such as the output and
+// palette are kept.
+func (i *Interpreter) Replay() (*drawing.Drawing, error) {
+	history := i.history
+	i.reset()
+	for _, cmd := range history {
+		if err := i.ExecuteCommand(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return i.context.Turtles.Drawing(), nil
+}
+
+// Reset starts again with a fresh turtle, drawing and variables and an
+// empty history, restoring the default pen. User-defined procedures are
+// kept unless clearProcedures is set. Settings such as the output and
+// palette are kept either way.
+func (i *Interpreter) Reset(clearProcedures bool) {
+	procedures := i.context.Procedures
+	i.reset()
+	if !clearProcedures {
+		i.context.Procedures = procedures
+	}
+}
+
+// reset replaces the turtle and execution state with fresh ones, keeping
+// the interpreter's settings, including the turtle's coordinate limit, and
+// clearing the history
+func (i *Interpreter) reset() {
+	previous := i.context
+	limit := i.turtle.CoordinateLimit()
+	i.turtle = turtle.NewHeadless()
+	i.turtle.SetCoordinateLimit(limit)
+	i.context = ast.NewContext(i.turtle)
+	i.context.Palette = previous.Palette
+	i.context.Output = previous.Output
+	i.context.PictureDir = previous.PictureDir
+	i.context.SkipWaits = previous.SkipWaits
+	i.context.Observer = previous.Observer
+	i.context.MaxSteps = previous.MaxSteps
+	i.history = nil
+}
+
+// Procedures returns the names of the user-defined procedures, sorted
+func (i *Interpreter) Procedures() []string {
+	names := make([]string, 0, len(i.context.Procedures))
+	for name := range i.context.Procedures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProcedureSource returns the source of a user-defined procedure,
+// reconstructed from its parsed form
+func (i *Interpreter) ProcedureSource(name string) (string, bool) {
+	pd, exists := i.context.Procedures[strings.ToLower(name)]
+	if !exists {
+		return "", false
+	}
+	source, err := parser.FormatCommand(pd)
+	if err != nil {
+		return "", false
+	}
+	return source, true
+}
+
+// Erase removes a user-defined procedure so its name is free again.
+// Builtin commands cannot be erased.
+func (i *Interpreter) Erase(name string) error {
+	if parser.IsBuiltin(name) {
+		return fmt.Errorf("cannot erase %s: it is a builtin command", name)
+	}
+	return i.ExecuteCommand(ast.NewEraseCommand(strings.ToLower(name)))
+}
+
+// arities returns the number of inputs each defined procedure takes, so
+// later programs can call procedures defined by earlier ones
+func (i *Interpreter) arities() map[string]int {
+	arities := make(map[string]int, len(i.context.Procedures))
+	for name, pd := range i.context.Procedures {
+		arities[name] = len(pd.Params)
+	}
+	return arities
+}
+
+// SetOutput sets where PRINT writes, which is os.Stdout by default
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.context.Output = w
+}
+
+// SetPictureDir sets the directory SAVEPICT and LOADPICT read and write
+// files in, which is the current directory by default. Programs can't reach
+// files outside it.
+func (i *Interpreter) SetPictureDir(dir string) {
+	i.context.PictureDir = dir
+}
+
+// SetSkipWaits controls whether WAIT commands return immediately, for
+// headless or batch runs where delays serve no purpose
+func (i *Interpreter) SetSkipWaits(skip bool) {
+	i.context.SkipWaits = skip
+}
+
+// SetMaxSteps limits how many steps each call to Execute may take, counting
+// every command without a block of its own, however deeply it is nested,
+// and every pass of a loop, so untrusted programs can't run forever. A
+// program going over the limit stops with an error wrapping
+// ast.ErrStepLimit. A limit of 0 or less removes it.
+func (i *Interpreter) SetMaxSteps(n int) {
+	i.context.MaxSteps = max(n, 0)
+}
+
+// SetPaletteEntry replaces the color used by SETPENCOLOR for a palette index
+func (i *Interpreter) SetPaletteEntry(index int, c color.Color) error {
+	if index < 0 || index >= len(i.context.Palette) {
+		return fmt.Errorf("palette index %d out of range", index)
+	}
+	i.context.Palette[index] = c
+	return nil
 }
 
 // parseColor parses a color string into RGB values
@@ -72,12 +236,143 @@ func parseColor(colorStr string) (uint8, uint8, uint8, error) {
 	return uint8(r), uint8(g), uint8(b), nil
 }
 
-// ExecuteCommand runs a single command
+// ExecuteCommand runs a single command, adding it to the history if it
+// succeeds
 func (i *Interpreter) ExecuteCommand(cmd ast.Command) error {
-	return cmd.Execute(i.context)
+	if err := i.context.Run(cmd); err != nil {
+		return err
+	}
+	i.history = append(i.history, cmd)
+	return nil
+}
+
+// OnStep calls step after each command runs, at any depth, with the state
+// of the turtle just after it. Blocks such as REPEAT are reported after the
+// commands inside them. A nil step stops the calls.
+func (i *Interpreter) OnStep(step func(cmd ast.Command, s Snapshot)) {
+	i.onStep = step
+}
+
+// SetObserver calls observer after each command runs, at any depth, with
+// the context it ran in. It sees commands in the same order as OnStep, and
+// the two can be used together. A nil observer stops the calls.
+func (i *Interpreter) SetObserver(observer func(cmd ast.Command, ctx *ast.Context)) {
+	i.observer = observer
+}
+
+// SetTrace writes a line to w for every primitive command that runs, at any
+// depth, giving the command and where its turtle is afterwards, such as
+// "FORWARD 50 -> (0,50)". Blocks and procedure calls aren't traced
+// themselves, only the commands inside them. A nil writer stops the trace.
+func (i *Interpreter) SetTrace(w io.Writer) {
+	i.trace = w
+}
+
+// Profile runs a Logo command string like Execute and reports how long was
+// spent in each type of command, keyed by type name such as
+// "ForwardCommand". A command is charged with the time since the previous
+// command finished, so blocks such as REPEAT are only charged for their own
+// work and the durations add up to the whole run.
+func (i *Interpreter) Profile(cmdStr string) (map[string]time.Duration, error) {
+	profile := map[string]time.Duration{}
+	previous := i.observer
+	defer func() { i.observer = previous }()
+
+	last := time.Now()
+	i.observer = func(cmd ast.Command, ctx *ast.Context) {
+		now := time.Now()
+		profile[strings.TrimPrefix(fmt.Sprintf("%T", resolve(cmd, ctx)), "*ast.")] += now.Sub(last)
+		if previous != nil {
+			previous(cmd, ctx)
+		}
+		last = time.Now()
+	}
+
+	if _, err := i.Execute(cmdStr); err != nil {
+		return nil, err
+	}
+	return profile, nil
 }
 
-// GetTurtle returns the interpreter's turtle
+// observe passes a command that has just run on to the observer and step
+// function
+func (i *Interpreter) observe(cmd ast.Command, ctx *ast.Context) {
+	if i.observer != nil {
+		i.observer(cmd, ctx)
+	}
+	if i.onStep != nil {
+		i.onStep(cmd, i.Snapshot())
+	}
+	if i.trace != nil {
+		i.traceCommand(cmd, ctx)
+	}
+}
+
+// traceCommand writes cmd's line of the trace, if it's a primitive
+func (i *Interpreter) traceCommand(cmd ast.Command, ctx *ast.Context) {
+	if _, ok := cmd.(*ast.ProcedureCallCommand); ok || ast.HasBlock(cmd) {
+		return
+	}
+	x, y := ctx.Turtle.GetPosition()
+	fmt.Fprintf(i.trace, "%s -> (%s,%s)\n", resolve(cmd, ctx), traceNumber(x), traceNumber(y))
+}
+
+// resolve returns the command a command with variable arguments created
+// when it ran, or cmd itself for any other command. The arguments are
+// evaluated again, so ones reading the turtle, such as xcor, give its state
+// after the command.
+func resolve(cmd ast.Command, ctx *ast.Context) ast.Command {
+	if dc, ok := cmd.(*ast.DeferredCommand); ok {
+		if resolved, err := dc.Resolve(ctx); err == nil {
+			return resolved
+		}
+	}
+	return cmd
+}
+
+// traceNumber writes a coordinate to two decimal places at most, so the
+// rounding left by turns doesn't clutter the trace
+func traceNumber(value float32) string {
+	rounded := math.Round(float64(value)*100) / 100
+	if rounded == 0 {
+		// Avoid writing -0
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// Drawing returns the drawing made by all turtles so far
+func (i *Interpreter) Drawing() *drawing.Drawing {
+	return i.context.Turtles.Drawing()
+}
+
+// GetTurtle returns the turtle commands are currently directed at
 func (i *Interpreter) GetTurtle() *turtle.Turtle {
-	return i.turtle
+	return i.context.Turtle
+}
+
+// Snapshot is the state of the current turtle at a moment in time
+type Snapshot struct {
+	X, Y       float32 // Position relative to home, with +Y pointing up
+	Heading    float32 // As the HEADING reader reports it
+	PenDown    bool
+	PenColor   color.Color
+	PenSize    float32
+	PointCount int // Points recorded by all turtles
+}
+
+// Snapshot captures the state of the current turtle, so tests can check it
+// in one comparison
+func (i *Interpreter) Snapshot() Snapshot {
+	t := i.context.Turtle
+	x, y := t.GetPosition()
+	return Snapshot{
+		X:          x,
+		Y:          y,
+		Heading:    t.Heading(),
+		PenDown:    t.IsDown(),
+		PenColor:   t.GetColor(),
+		PenSize:    t.PenSize(),
+		PointCount: len(i.context.Turtles.Drawing().Points()),
+	}
 }
@@ -1,8 +1,23 @@
 package interpreter
 
 import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/parser"
+	"github.com/honeylogo/logo/turtle"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,5 +81,1133 @@ func TestAliasCommands(t *testing.T) {
 	drawing, err = interp.Execute("lt 90")
 	assert.NoError(t, err)
 	assert.NotNil(t, drawing)
-	assert.InDelta(t, 270.0, interp.GetTurtle().GetAngle(), 0.001)
+	assert.InDelta(t, 180.0, interp.GetTurtle().GetAngle(), 0.001)
+}
+
+func TestPaletteColors(t *testing.T) {
+	interp := New()
+
+	// Default palette entry 4 is red
+	drawing, err := interp.Execute("setpencolor 4 fd 10")
+	assert.NoError(t, err)
+	points := drawing.Points()
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, points[len(points)-1].PenColor)
+
+	// Customized palette entry
+	orange := color.RGBA{R: 255, G: 128, A: 255}
+	assert.NoError(t, interp.SetPaletteEntry(4, orange))
+	drawing, err = interp.Execute("setpencolor 4 fd 10")
+	assert.NoError(t, err)
+	points = drawing.Points()
+	assert.Equal(t, orange, points[len(points)-1].PenColor)
+
+	// Other entries keep their defaults
+	drawing, err = interp.Execute("setpencolor 1 fd 10")
+	assert.NoError(t, err)
+	points = drawing.Points()
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, points[len(points)-1].PenColor)
+
+	assert.Error(t, interp.SetPaletteEntry(16, orange))
+	assert.Error(t, interp.SetPaletteEntry(-1, orange))
+
+	_, err = interp.Execute("setpencolor 16")
+	assert.Error(t, err)
+}
+
+func TestWaitCommand(t *testing.T) {
+	interp := New()
+
+	// Three ticks is 50ms
+	start := time.Now()
+	_, err := interp.Execute("wait 3")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Ten seconds of waiting is skipped entirely
+	interp.SetSkipWaits(true)
+	start = time.Now()
+	_, err = interp.Execute("fd 10 wait 600 fd 10")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.InDelta(t, 20.0, y, 0.001)
+
+	_, err = interp.Execute("wait -1")
+	assert.Error(t, err)
+}
+
+func TestArcCommands(t *testing.T) {
+	right := New()
+	_, err := right.Execute("arcr 50 90")
+	assert.NoError(t, err)
+	rx, ry := right.GetTurtle().GetPosition()
+	assert.InDelta(t, 50.0, rx, 0.01)
+	assert.InDelta(t, 50.0, ry, 0.01)
+	assert.InDelta(t, 0.0, right.GetTurtle().GetAngle(), 0.01)
+
+	left := New()
+	_, err = left.Execute("arcl 50 90")
+	assert.NoError(t, err)
+	lx, ly := left.GetTurtle().GetPosition()
+	assert.InDelta(t, 180.0, left.GetTurtle().GetAngle(), 0.01)
+
+	// The left arc is the mirror image of the right arc
+	assert.InDelta(t, -rx, lx, 0.01)
+	assert.InDelta(t, ry, ly, 0.01)
+
+	// Every point along the arc is on the circle around (50, 0)
+	for _, p := range right.GetTurtle().Drawing().Points() {
+		assert.InDelta(t, 50.0, math.Hypot(p.X-50, p.Y), 0.01)
+	}
+
+	_, err = right.Execute("arcr 50")
+	assert.ErrorContains(t, err, "arcr command requires 2 number arguments")
+}
+
+func TestSetXY(t *testing.T) {
+	interp := New()
+
+	drawing, err := interp.Execute("setxy 30 40")
+	assert.NoError(t, err)
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 30.0, x, 0.001)
+	assert.InDelta(t, 40.0, y, 0.001)
+	points := drawing.Points()
+	last := points[len(points)-1]
+	assert.InDelta(t, 30.0, last.X, 0.001)
+	assert.InDelta(t, 40.0, last.Y, 0.001)
+
+	// SETPOS is an alias, and SETX/SETY keep the other coordinate
+	_, err = interp.Execute("setpos -10 20 setx 5")
+	assert.NoError(t, err)
+	x, y = interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 5.0, x, 0.001)
+	assert.InDelta(t, 20.0, y, 0.001)
+
+	_, err = interp.Execute("sety 7")
+	assert.NoError(t, err)
+	x, y = interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 5.0, x, 0.001)
+	assert.InDelta(t, 7.0, y, 0.001)
+
+	_, err = interp.Execute("setxy 30")
+	assert.ErrorContains(t, err, "setxy command requires 2 number arguments")
+}
+
+func TestPenUpPositionTracking(t *testing.T) {
+	interp := New()
+
+	drawing, err := interp.Execute("pu fd 30 rt 90 fd 20 setxy 50 30 pd fd 10")
+	assert.NoError(t, err)
+
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 60.0, x, 0.001)
+	assert.InDelta(t, 30.0, y, 0.001)
+
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 1) {
+		assert.InDelta(t, 50.0, segments[0].StartX, 0.001)
+		assert.InDelta(t, 30.0, segments[0].StartY, 0.001)
+		assert.InDelta(t, 60.0, segments[0].EndX, 0.001)
+		assert.InDelta(t, 30.0, segments[0].EndY, 0.001)
+	}
+}
+
+func TestPenUpAtStart(t *testing.T) {
+	drawing, err := New().Execute("penup fd 100 pendown fd 50")
+	assert.NoError(t, err)
+
+	// Nothing is drawn from the origin, only the move after PENDOWN
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 1) {
+		assert.InDelta(t, 100.0, segments[0].StartY, 0.001)
+		assert.InDelta(t, 150.0, segments[0].EndY, 0.001)
+	}
+}
+
+func TestSetShape(t *testing.T) {
+	interp := New()
+
+	_, err := interp.Execute(`setshape "triangle`)
+	assert.NoError(t, err)
+	assert.Equal(t, "triangle", interp.GetTurtle().Shape())
+
+	_, err = interp.Execute(`setshape "dragon`)
+	assert.ErrorContains(t, err, `unknown shape "dragon"`)
+
+	_, err = interp.Execute("setshape")
+	assert.ErrorContains(t, err, "setshape command requires a shape name")
+}
+
+func TestMultipleTurtles(t *testing.T) {
+	interp := New()
+
+	drawing, err := interp.Execute(`newturtle "bob
+fd 10
+ask "bob [ rt 90 fd 20 ]
+fd 10
+tell "bob
+fd 5`)
+	assert.NoError(t, err)
+
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 25.0, x, 0.001)
+	assert.InDelta(t, 0.0, y, 0.001)
+
+	counts := map[int]int{}
+	for _, segment := range drawing.Segments() {
+		counts[segment.Turtle]++
+	}
+	assert.Equal(t, map[int]int{0: 2, 1: 2}, counts)
+
+	_, err = interp.Execute(`tell "alice`)
+	assert.ErrorContains(t, err, `unknown turtle "alice"`)
+	_, err = interp.Execute(`newturtle "bob`)
+	assert.ErrorContains(t, err, `turtle "bob" already exists`)
+	_, err = interp.Execute(`ask "bob fd 10`)
+	assert.ErrorContains(t, err, "ask command requires a block")
+}
+
+func TestPenGradient(t *testing.T) {
+	interp := New()
+
+	drawing, err := interp.Execute("setpengradient 255 0 0 0 0 255 3 repeat 3 [ fd 10 ]")
+	assert.NoError(t, err)
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 3) {
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, segments[0].Color)
+		assert.Equal(t, color.RGBA{R: 128, B: 128, A: 255}, segments[1].Color)
+		assert.Equal(t, color.RGBA{B: 255, A: 255}, segments[2].Color)
+	}
+
+	_, err = interp.Execute("setpengradient 255 0 0 0 0 300 3")
+	assert.ErrorContains(t, err, "color values must be between 0 and 255")
+	_, err = interp.Execute("setpengradient 255 0 0 0 0 255 1.5")
+	assert.ErrorContains(t, err, "steps must be a whole number of at least 2, got 1.5")
+}
+
+func TestPrint(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	_, err := interp.Execute(`print sum 2 3
+pr "hello
+make "size 2.5 * 4
+print :size + 1`)
+	assert.NoError(t, err)
+	assert.Equal(t, "5\nhello\n11\n", output.String())
+
+	_, err = interp.Execute("print :missing")
+	assert.ErrorContains(t, err, "missing has no value")
+
+	_, err = interp.Execute("print")
+	assert.ErrorContains(t, err, "print command requires a word or a number")
+}
+
+func TestRegisteredCommand(t *testing.T) {
+	err := parser.RegisterCommand("square", nil, 1, func(args []float64) ast.Command {
+		return ast.NewRepeatCommand(4, []ast.Command{
+			ast.NewForwardCommand(float32(args[0])),
+			ast.NewRightCommand(90),
+		})
+	})
+	assert.NoError(t, err)
+
+	interp := New()
+	drawing, err := interp.Execute("square 30")
+	assert.NoError(t, err)
+	assert.Len(t, drawing.Segments(), 4)
+}
+
+func TestConditions(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	_, err := interp.Execute(`make "x 50
+if and :x > 0 :x < 100 [ print "inside ]
+if or :x < 0 :x > 100 [ print "outside ]
+if not :x = 50 [ print "changed ]
+make "n 0
+while :n < 3 [ print :n make "n :n + 1 ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "inside\n0\n1\n2\n", output.String())
+
+	// The second operand of AND is skipped once the first is false
+	_, err = interp.Execute(`if and 0 = 1 :missing [ print "never ]`)
+	assert.NoError(t, err)
+	_, err = interp.Execute(`if or 1 :missing [ print "short ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "inside\n0\n1\n2\nshort\n", output.String())
+
+	_, err = interp.Execute("if 1 fd 10")
+	assert.ErrorContains(t, err, "if command requires a block")
+}
+
+func TestDoTimes(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	drawing, err := interp.Execute("dotimes [i 4] [ print :i fd :i * 10 ]")
+	assert.NoError(t, err)
+	assert.Equal(t, "0\n1\n2\n3\n", output.String())
+	assert.Len(t, drawing.Segments(), 3)
+	_, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 60.0, y, 0.001)
+
+	// The loop variable only exists inside the loop
+	_, err = interp.Execute("print :i")
+	assert.ErrorContains(t, err, "i has no value")
+
+	output.Reset()
+	_, err = interp.Execute(`make "i 7 dotimes [i 2] [ print :i ] print :i`)
+	assert.NoError(t, err)
+	assert.Equal(t, "0\n1\n7\n", output.String())
+
+	_, err = interp.Execute("dotimes [i] [ fd 10 ]")
+	assert.ErrorContains(t, err, "dotimes command requires a count")
+}
+
+func TestRepCount(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	// A procedure called from a REPEAT sees its count
+	_, err := interp.Execute(`to side
+  print repcount
+  fd repcount * 10
+end
+repeat 3 [ side ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n", output.String())
+	_, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 60.0, y, 0.001)
+
+	// A REPEAT in the procedure or a parameter of the same name shadows it,
+	// and the outer count is back once the procedure returns
+	output.Reset()
+	_, err = interp.Execute(`to inner
+  repeat 2 [ print repcount * 10 ]
+end
+to param :repcount
+  print :repcount
+end
+repeat 2 [ inner param 7 print repcount ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "10\n20\n7\n1\n10\n20\n7\n2\n", output.String())
+
+	// The count only exists inside a REPEAT
+	_, err = interp.Execute("print repcount")
+	assert.ErrorContains(t, err, "repcount has no value")
+
+	output.Reset()
+	_, err = interp.Execute(`make "repcount 5 repeat 1 [ print repcount ] print :repcount`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n5\n", output.String())
+}
+
+func TestMathFunctions(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	_, err := interp.Execute(`print sin 90 print sqrt 9 print round 2.6 print abs minus 4 print int 7.9`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n3\n3\n4\n7\n", output.String())
+
+	// Moving along a computed diagonal
+	_, err = interp.Execute(`rt 45 fd sqrt 200`)
+	assert.NoError(t, err)
+	assert.True(t, interp.GetTurtle().PositionEquals(10, 10, 1e-4))
+
+	_, err = interp.Execute(`make "n -1 fd sqrt :n`)
+	assert.ErrorContains(t, err, "sqrt: -1 is negative")
+}
+
+func TestVariableArguments(t *testing.T) {
+	interp := New()
+
+	_, err := interp.Execute(`make "size 25 fd :size rt :size + 65 fd :size * 2`)
+	assert.NoError(t, err)
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 50.0, x, 0.001)
+	assert.InDelta(t, 25.0, y, 0.001)
+
+	// Argument checks happen once the values are known
+	_, err = interp.Execute(`make "size 0 setpensize :size`)
+	assert.ErrorContains(t, err, "pen size must be greater than 0")
+	_, err = interp.Execute("fd :missing")
+	assert.ErrorContains(t, err, "missing has no value")
+}
+
+func TestProcedures(t *testing.T) {
+	interp := New()
+
+	_, err := interp.Execute(`to box :size
+  repeat 4 [ fd :size rt 90 ]
+end
+to star
+  repeat 5 [ fd 50 rt 144 ]
+end`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"box", "star"}, interp.Procedures())
+
+	source, ok := interp.ProcedureSource("box")
+	assert.True(t, ok)
+	assert.Equal(t, "to box :size\n  repeat 4 [\n    forward :size\n    right 90\n  ]\nend\n", source)
+	source, ok = interp.ProcedureSource("STAR")
+	assert.True(t, ok)
+	assert.Equal(t, "to star\n  repeat 5 [\n    forward 50\n    right 144\n  ]\nend\n", source)
+
+	_, ok = interp.ProcedureSource("circle")
+	assert.False(t, ok)
+
+	// Procedures defined earlier can be called by later programs
+	drawing, err := interp.Execute("box 10")
+	assert.NoError(t, err)
+	assert.Len(t, drawing.Segments(), 4)
+
+	_, err = interp.Execute("box")
+	assert.ErrorContains(t, err, "box expects 1 inputs")
+}
+
+func TestRecursionLimit(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute("to spin rt 1 spin end spin")
+	assert.ErrorContains(t, err, "nested more than 1000 deep")
+}
+
+func TestErase(t *testing.T) {
+	interp := New()
+
+	_, err := interp.Execute("to hop\npu fd 10 pd\nend\nto skip\nrt 90\nend")
+	assert.NoError(t, err)
+
+	_, err = interp.Execute(`erase "hop`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"skip"}, interp.Procedures())
+
+	_, err = interp.Execute("hop")
+	var unknown *parser.UnknownCommandError
+	assert.ErrorAs(t, err, &unknown)
+
+	assert.NoError(t, interp.Erase("SKIP"))
+	assert.Empty(t, interp.Procedures())
+	_, err = interp.Execute("skip")
+	assert.ErrorAs(t, err, &unknown)
+
+	// The name is free to be defined again
+	_, err = interp.Execute("to hop\nfd 5\nend\nhop")
+	assert.NoError(t, err)
+
+	assert.ErrorContains(t, interp.Erase("forward"), "cannot erase forward: it is a builtin command")
+	_, err = interp.Execute(`erase "fd`)
+	assert.ErrorContains(t, err, "cannot erase fd: it is a builtin command")
+	assert.ErrorContains(t, interp.Erase("circle"), "circle is not a procedure")
+}
+
+func TestPrintTurtleState(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	_, err := interp.Execute("print pos print heading")
+	assert.NoError(t, err)
+	assert.Equal(t, "[0 0]\n270\n", output.String())
+
+	output.Reset()
+	_, err = interp.Execute("fd 30 rt 90 fd 50 rt 45 print pos print heading print xcor print ycor")
+	assert.NoError(t, err)
+	assert.Equal(t, "[50 30]\n45\n50\n30\n", output.String())
+
+	// The readers can be used in expressions, and heading matches SETHEADING
+	output.Reset()
+	_, err = interp.Execute("setheading heading + 45 setx xcor * 2 print heading print pos")
+	assert.NoError(t, err)
+	assert.Equal(t, "90\n[100 30]\n", output.String())
+
+	_, err = interp.Execute("fd pos")
+	assert.ErrorContains(t, err, "pos is a list, not a number")
+}
+
+func TestForEach(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	drawing, err := interp.Execute("foreach [10 20 30] [ print ? fd ? rt 120 ]")
+	assert.NoError(t, err)
+	assert.Equal(t, "10\n20\n30\n", output.String())
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 3) {
+		for i, length := range []float32{10, 20, 30} {
+			dx := segments[i].EndX - segments[i].StartX
+			dy := segments[i].EndY - segments[i].StartY
+			assert.InDelta(t, length, math.Hypot(float64(dx), float64(dy)), 0.001)
+		}
+	}
+
+	// A named variable, restored afterwards
+	output.Reset()
+	_, err = interp.Execute(`make "n 99 foreach "n [1 2] [ print :n * 2 ] print :n`)
+	assert.NoError(t, err)
+	assert.Equal(t, "2\n4\n99\n", output.String())
+
+	_, err = interp.Execute("print ?")
+	assert.ErrorContains(t, err, "? has no value")
+
+	_, err = interp.Execute(`foreach [1 "two] [ fd ? ]`)
+	assert.ErrorContains(t, err, "foreach list can only contain numbers, got two")
+}
+
+func TestJumpBeforeDrawing(t *testing.T) {
+	interp := New()
+	drawing, err := interp.Execute("penup setxy 100 100 pendown fd 10")
+	assert.NoError(t, err)
+
+	// The jump is recorded with the pen up, so no line is drawn from (0, 0)
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 1) {
+		assert.InDelta(t, 100.0, segments[0].StartX, 0.001)
+		assert.InDelta(t, 100.0, segments[0].StartY, 0.001)
+		assert.InDelta(t, 110.0, segments[0].EndY, 0.001)
+	}
+}
+
+// BenchmarkExecute runs a program of 10,000 moves with debug logging going
+// nowhere and with it turned off
+func BenchmarkExecute(b *testing.B) {
+	program := strings.Repeat("fd 1 rt 1\n", 5000)
+	logger := log.Logger
+	defer func() { log.Logger = logger }()
+
+	for _, level := range []zerolog.Level{zerolog.DebugLevel, zerolog.InfoLevel} {
+		b.Run("level="+level.String(), func(b *testing.B) {
+			log.Logger = zerolog.New(io.Discard).Level(level)
+			for i := 0; i < b.N; i++ {
+				if _, err := New().Execute(program); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetPenPattern(t *testing.T) {
+	interp := New()
+	assert.Equal(t, "solid", interp.GetTurtle().PenPattern())
+
+	drawing, err := interp.Execute(`setpenpattern "dashed fd 10 setpenpattern "solid fd 10`)
+	assert.NoError(t, err)
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 2) {
+		assert.Equal(t, "dashed", segments[0].Pattern)
+		assert.Equal(t, "solid", segments[1].Pattern)
+	}
+
+	_, err = interp.Execute(`setpenpattern "wavy`)
+	assert.ErrorContains(t, err, `unknown pen pattern "wavy"`)
+}
+
+func TestSavePictLoadPict(t *testing.T) {
+	dir := t.TempDir()
+	interp := New()
+	interp.SetPictureDir(dir)
+
+	_, err := interp.Execute(`fd 50 savepict "square.png`)
+	assert.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "square.png"))
+	if assert.NoError(t, err) {
+		assert.NotZero(t, info.Size())
+	}
+
+	drawing, err := interp.Execute(`loadpict "square.png`)
+	assert.NoError(t, err)
+	if assert.NotNil(t, drawing.Background()) {
+		assert.Equal(t, image.Rect(0, 0, ast.PictureWidth, ast.PictureHeight), drawing.Background().Bounds())
+	}
+
+	// File names keep their case
+	_, err = interp.Execute(`savepict "MyPic.png`)
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Contains(t, names, "MyPic.png")
+	_, err = interp.Execute(`loadpict "MyPic.png`)
+	assert.NoError(t, err)
+
+	_, err = interp.Execute(`savepict "../escape.png`)
+	assert.ErrorContains(t, err, "must be a relative path inside the picture directory")
+	_, err = interp.Execute(`loadpict "missing.png`)
+	assert.Error(t, err)
+}
+
+func TestSetPosList(t *testing.T) {
+	interp := New()
+
+	drawing, err := interp.Execute("setpos [30 40]")
+	assert.NoError(t, err)
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 30.0, x, 0.001)
+	assert.InDelta(t, 40.0, y, 0.001)
+	assert.Len(t, drawing.Segments(), 1)
+
+	_, err = interp.Execute(`make "x -10 setpos [:x :x * 2]`)
+	assert.NoError(t, err)
+	x, y = interp.GetTurtle().GetPosition()
+	assert.InDelta(t, -10.0, x, 0.001)
+	assert.InDelta(t, -20.0, y, 0.001)
+
+	_, err = interp.Execute("setpos [30 40 50]")
+	assert.ErrorContains(t, err, "setxy list must have 2 numbers, got 3")
+	_, err = interp.Execute("setpos [30]")
+	assert.ErrorContains(t, err, "setxy list must have 2 numbers, got 1")
+	_, err = interp.Execute("setpos [fd 10]")
+	assert.ErrorContains(t, err, "setxy list can only contain numbers, got forward")
+}
+
+func TestSlide(t *testing.T) {
+	tests := []struct {
+		heading  string
+		x, y     float64
+		expected float32
+	}{
+		// SETHEADING 0 faces +X, so the turtle's right is -Y
+		{"setheading 0", 10, -5, 0},
+		// SETHEADING 90 faces -Y, so the turtle's right is -X
+		{"setheading 90", -5, -10, 90},
+	}
+
+	for _, tt := range tests {
+		interp := New()
+		_, err := interp.Execute(tt.heading + " slide 10 5")
+		assert.NoError(t, err, tt.heading)
+		x, y := interp.GetTurtle().GetPosition()
+		assert.InDelta(t, tt.x, x, 0.001, tt.heading)
+		assert.InDelta(t, tt.y, y, 0.001, tt.heading)
+		assert.InDelta(t, tt.expected, interp.GetTurtle().Heading(), 0.001, tt.heading)
+	}
+}
+
+func TestHistoryReplay(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	_, err := interp.Execute("to step :n\nfd :n rt 90\nend")
+	assert.NoError(t, err)
+	_, err = interp.Execute(`make "size 30 step :size step 20`)
+	assert.NoError(t, err)
+	_, err = interp.Execute("fd 10 dance")
+	assert.Error(t, err)
+	_, err = interp.Execute("print xcor")
+	assert.NoError(t, err)
+
+	assert.Len(t, interp.History(), 5)
+	x, y := interp.GetTurtle().GetPosition()
+	heading := interp.GetTurtle().Heading()
+	segments := len(interp.GetTurtle().Drawing().Segments())
+
+	drawing, err := interp.Replay()
+	assert.NoError(t, err)
+	replayedX, replayedY := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, x, replayedX, 0.001)
+	assert.InDelta(t, y, replayedY, 0.001)
+	assert.InDelta(t, heading, interp.GetTurtle().Heading(), 0.001)
+	assert.Len(t, drawing.Segments(), segments)
+	assert.Equal(t, "20\n20\n", output.String())
+	assert.Len(t, interp.History(), 5)
+
+	// With the history cleared, replaying just starts afresh
+	interp.ClearHistory()
+	assert.Empty(t, interp.History())
+	_, err = interp.Replay()
+	assert.NoError(t, err)
+	x, y = interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.InDelta(t, 0.0, y, 0.001)
+}
+
+func TestTestIfTrueIfFalse(t *testing.T) {
+	interp := New()
+	var output bytes.Buffer
+	interp.SetOutput(&output)
+
+	_, err := interp.Execute(`test 1 < 2
+iftrue [ print "yes ]
+iffalse [ print "no ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "yes\n", output.String())
+
+	output.Reset()
+	_, err = interp.Execute(`test 1 > 2
+ift [ print "yes ]
+iff [ print "no ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "no\n", output.String())
+
+	// A TEST inside a procedure doesn't change its caller's result, and a
+	// procedure starts without one
+	output.Reset()
+	_, err = interp.Execute(`to check
+  test 0
+  iftrue [ print "inner ]
+end
+test 1
+check
+iftrue [ print "outer ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "outer\n", output.String())
+
+	_, err = interp.Execute(`to untested
+  iftrue [ print "never ]
+end
+untested`)
+	assert.ErrorContains(t, err, "iftrue without a TEST")
+
+	_, err = New().Execute(`iffalse [ fd 10 ]`)
+	assert.ErrorContains(t, err, "iffalse without a TEST")
+}
+
+func TestSnapshot(t *testing.T) {
+	interp := New()
+	assert.Equal(t, Snapshot{
+		Heading:    270,
+		PenDown:    true,
+		PenColor:   color.Black,
+		PenSize:    1,
+		PointCount: 1, // The starting position
+	}, interp.Snapshot())
+
+	_, err := interp.Execute(`fd 50 rt 90 setpc "red setps 3 fd 20 pu bk 5`)
+	assert.NoError(t, err)
+	assert.Equal(t, Snapshot{
+		X:          15,
+		Y:          50,
+		Heading:    0,
+		PenDown:    false,
+		PenColor:   color.RGBA{R: 255, A: 255},
+		PenSize:    3,
+		PointCount: 4,
+	}, interp.Snapshot())
+}
+
+// complexProgram leaves the turtles, pen, variables and procedures far from
+// their defaults
+const complexProgram = `to box :size
+  repeat 4 [ fd :size rt 90 ]
+end
+make "x 7
+setpc "red setps 5 setpenpattern "dashed
+pu fd 30 pd rt 45 box 20
+newturtle "bob tell "bob fd 10`
+
+// defaultSnapshot is the state of a new interpreter's turtle
+var defaultSnapshot = Snapshot{Heading: 270, PenDown: true, PenColor: color.Black, PenSize: 1, PointCount: 1}
+
+func TestResetCommand(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute(complexProgram)
+	assert.NoError(t, err)
+	_, err = interp.Execute(`setshape "arrow`)
+	assert.NoError(t, err)
+
+	drawing, err := interp.Execute("reset")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultSnapshot, interp.Snapshot())
+	assert.Empty(t, drawing.Segments())
+	assert.Equal(t, "solid", interp.GetTurtle().PenPattern())
+	assert.Equal(t, "turtle", interp.GetTurtle().Shape())
+	assert.Equal(t, []string{turtle.DefaultTurtle}, interp.context.Turtles.Names())
+
+	// Variables are forgotten but procedures are kept
+	_, err = interp.Execute("fd :x")
+	assert.ErrorContains(t, err, "x has no value")
+	drawing, err = interp.Execute("box 10")
+	assert.NoError(t, err)
+	assert.Len(t, drawing.Segments(), 4)
+}
+
+func TestReset(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute(complexProgram)
+	assert.NoError(t, err)
+
+	interp.Reset(false)
+	assert.Equal(t, defaultSnapshot, interp.Snapshot())
+	assert.Empty(t, interp.History())
+	assert.Equal(t, []string{"box"}, interp.Procedures())
+
+	interp.Reset(true)
+	assert.Equal(t, defaultSnapshot, interp.Snapshot())
+	assert.Empty(t, interp.Procedures())
+}
+
+func TestSpiral(t *testing.T) {
+	interp := New()
+	drawing, err := interp.Execute("spiral 10 5 90 6")
+	assert.NoError(t, err)
+
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 6) {
+		for i, s := range segments {
+			length := math.Hypot(s.EndX-s.StartX, s.EndY-s.StartY)
+			assert.InDelta(t, 10+5*float64(i), length, 0.001, "segment %d", i)
+		}
+	}
+	// Six right turns of 90 from facing up leave the turtle facing down
+	assert.InDelta(t, 90, interp.GetTurtle().Heading(), 0.001)
+
+	drawing, err = New().Execute(`make "n 3 spiral 5 :n 120 :n`)
+	assert.NoError(t, err)
+	assert.Len(t, drawing.Segments(), 3)
+
+	_, err = New().Execute("spiral 10 5 90 2.5")
+	assert.ErrorContains(t, err, "steps must be a whole number of at least 0, got 2.5")
+}
+
+func TestCoordinateLimit(t *testing.T) {
+	interp := New()
+	interp.GetTurtle().SetCoordinateLimit(1e6)
+
+	// A spiral with growing sides runs away until it passes the limit
+	_, err := interp.Execute("spiral 1 1000 90 100000")
+	assert.ErrorIs(t, err, turtle.ErrCoordinateLimit)
+	x, y := interp.GetTurtle().GetPosition()
+	assert.LessOrEqual(t, math.Abs(float64(x)), 1e6)
+	assert.LessOrEqual(t, math.Abs(float64(y)), 1e6)
+
+	// Text too large for the limit stops with the pen as it was
+	interp = New()
+	interp.GetTurtle().SetCoordinateLimit(5)
+	_, err = interp.Execute(`setpensize 100 writevector "HELLO`)
+	assert.ErrorIs(t, err, turtle.ErrCoordinateLimit)
+	assert.True(t, interp.GetTurtle().IsDown())
+
+	// Starting again keeps the limit, as RESET does
+	interp = New()
+	interp.GetTurtle().SetCoordinateLimit(1000)
+	interp.Reset(false)
+	assert.Equal(t, 1000.0, interp.GetTurtle().CoordinateLimit())
+	_, err = interp.Execute("reset")
+	assert.NoError(t, err)
+	_, err = interp.Replay()
+	assert.NoError(t, err)
+	assert.Equal(t, 1000.0, interp.GetTurtle().CoordinateLimit())
+
+	// Turtles made by the program are held to the same limit
+	interp = New()
+	interp.GetTurtle().SetCoordinateLimit(1000)
+	_, err = interp.Execute(`newturtle "x tell "x fd 50000`)
+	assert.ErrorIs(t, err, turtle.ErrCoordinateLimit)
+}
+
+func TestPolygon(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute("fd 20 rt 30 polygon 4 50")
+	assert.NoError(t, err)
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 0, x, 0.001)
+	assert.InDelta(t, 20, y, 0.001)
+	assert.InDelta(t, 300, interp.GetTurtle().Heading(), 0.001)
+	assert.Len(t, interp.GetTurtle().Drawing().Segments(), 5)
+
+	for _, invalid := range []string{"polygon 2 50", "polygon 4.5 50"} {
+		_, err = New().Execute(invalid)
+		assert.ErrorContains(t, err, "sides must be a whole number of at least 3", invalid)
+	}
+	_, err = New().Execute(`make "n 2 polygon :n 50`)
+	assert.ErrorContains(t, err, "sides must be a whole number of at least 3, got 2")
+
+	assert.ErrorContains(t, ast.NewPolygonCommand(1, 10).Execute(ast.NewContext(turtle.NewHeadless())),
+		"a polygon needs at least 3 sides, got 1")
+}
+
+func TestMirror(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute(`to flag
+  fd 30 rt 90 fd 10 rt 90 fd 10 lt 45
+end
+flag pu home pd mirror flag`)
+	assert.NoError(t, err)
+	assert.True(t, interp.GetTurtle().Mirrored())
+
+	// The second flag is the first reflected left to right
+	segments := interp.Drawing().Segments()
+	assert.Len(t, segments, 6)
+	for i, s := range segments[:3] {
+		m := segments[i+3]
+		assert.InDelta(t, -s.StartX, m.StartX, 0.001, "edge %d", i)
+		assert.InDelta(t, s.StartY, m.StartY, 0.001, "edge %d", i)
+		assert.InDelta(t, -s.EndX, m.EndX, 0.001, "edge %d", i)
+		assert.InDelta(t, s.EndY, m.EndY, 0.001, "edge %d", i)
+	}
+
+	_, err = interp.Execute("mirror")
+	assert.NoError(t, err)
+	assert.False(t, interp.GetTurtle().Mirrored())
+}
+
+func TestRect(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute("rect 40 20")
+	assert.NoError(t, err)
+
+	segments := interp.GetTurtle().Drawing().Segments()
+	assert.Len(t, segments, 4)
+	corners := [][2]float64{{0, 0}, {0, 40}, {20, 40}, {20, 0}, {0, 0}}
+	for i, s := range segments {
+		assert.InDelta(t, corners[i][0], s.StartX, 0.001, "edge %d", i)
+		assert.InDelta(t, corners[i][1], s.StartY, 0.001, "edge %d", i)
+		assert.InDelta(t, corners[i+1][0], s.EndX, 0.001, "edge %d", i)
+		assert.InDelta(t, corners[i+1][1], s.EndY, 0.001, "edge %d", i)
+	}
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 0, x, 0.001)
+	assert.InDelta(t, 0, y, 0.001)
+	assert.InDelta(t, 270, interp.GetTurtle().Heading(), 0.001)
+}
+
+func TestEllipse(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute("ellipse 30 10")
+	assert.NoError(t, err)
+
+	// The ellipse is centred 30 to the turtle's right and closes where it
+	// started, with the turtle facing the same way
+	segments := interp.GetTurtle().Drawing().Segments()
+	assert.Len(t, segments, 72)
+	assert.Equal(t, segments[0].StartX, segments[len(segments)-1].EndX)
+	assert.Equal(t, segments[0].StartY, segments[len(segments)-1].EndY)
+	assert.Equal(t, float32(270), interp.GetTurtle().Heading())
+	for i, s := range segments {
+		dx, dy := (s.EndX-30)/30, s.EndY/10
+		assert.InDelta(t, 1, dx*dx+dy*dy, 0.001, "segment %d", i)
+		// Each step is a short chord, so the outline looks smooth
+		assert.Less(t, math.Hypot(s.EndX-s.StartX, s.EndY-s.StartY), 3.0, "segment %d", i)
+	}
+	assert.InDelta(t, 60, interp.Drawing().Bounds().Width(), 0.001)
+	assert.InDelta(t, 20, interp.Drawing().Bounds().Height(), 0.001)
+}
+
+func TestWriteVector(t *testing.T) {
+	interp := New()
+	drawing, err := interp.Execute(`setps 2 pu writevector "I`)
+	assert.NoError(t, err)
+
+	// At pen size 2 each font unit is 4 pixels, so the stroke is 24 long
+	segments := drawing.Segments()
+	if assert.Len(t, segments, 1) {
+		s := segments[0]
+		assert.InDelta(t, 8, s.StartX, 0.001)
+		assert.InDelta(t, 8, s.EndX, 0.001)
+		assert.InDelta(t, 0, s.StartY, 0.001)
+		assert.InDelta(t, 24, s.EndY, 0.001)
+	}
+
+	// The turtle moves on to the next glyph, pen still up
+	x, y := interp.GetTurtle().GetPosition()
+	assert.InDelta(t, 24, x, 0.001)
+	assert.InDelta(t, 0, y, 0.001)
+	assert.False(t, interp.GetTurtle().IsDown())
+
+	drawing, err = New().Execute(`writevector "hi`)
+	assert.NoError(t, err)
+	assert.Len(t, drawing.Segments(), 4)
+
+	_, err = New().Execute(`writevector "a+b`)
+	assert.ErrorContains(t, err, `writevector can't draw '+'`)
+}
+
+func TestOnStep(t *testing.T) {
+	interp := New()
+	steps := []string{}
+	ys := []float32{}
+	interp.OnStep(func(cmd ast.Command, s Snapshot) {
+		steps = append(steps, cmd.String())
+		ys = append(ys, s.Y)
+	})
+
+	_, err := interp.Execute("fd 5 repeat 2 [ fd 10 ]")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"FORWARD 5", "FORWARD 10", "FORWARD 10", "REPEAT 2 {\nFORWARD 10\n}"}, steps)
+	assert.Equal(t, []float32{5, 15, 25, 25}, ys)
+
+	interp.OnStep(nil)
+	_, err = interp.Execute("fd 5")
+	assert.NoError(t, err)
+	assert.Len(t, steps, 4)
+}
+
+func TestExecuteContext(t *testing.T) {
+	interp := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := interp.ExecuteContext(ctx, "while 1 [ rt 1 ]")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A long WAIT stops as soon as the context is done
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = interp.ExecuteContext(ctx, "wait 60000")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = interp.ExecuteContext(context.Background(), "repeat 3 [ fd 1 ]")
+	assert.NoError(t, err)
+}
+
+func TestSetObserver(t *testing.T) {
+	interp := New()
+	counts := map[string]int{}
+	interp.SetObserver(func(cmd ast.Command, ctx *ast.Context) {
+		counts[strings.Fields(cmd.String())[0]]++
+		assert.Equal(t, interp.GetTurtle(), ctx.Turtle)
+	})
+
+	_, err := interp.Execute(`to side :n
+  fd :n rt 90
+end
+repeat 2 [ repeat 2 [ side 10 ] pu ]`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"PROCEDURE": 1,
+		"REPEAT":    3,
+		"CALL":      4,
+		"FORWARD":   4,
+		"RIGHT":     4,
+		"PEN":       2,
+	}, counts)
+}
+
+func TestSetTrace(t *testing.T) {
+	interp := New()
+	var trace bytes.Buffer
+	interp.SetTrace(&trace)
+
+	_, err := interp.Execute(`to side :n
+  fd :n
+end
+fd 50
+repeat 2 [ side 30 rt 90 ]
+pu setxy -10 5`)
+	assert.NoError(t, err)
+	// Variable arguments are traced with their values
+	assert.Equal(t, `FORWARD 50 -> (0,50)
+FORWARD 30 -> (0,80)
+RIGHT 90 -> (0,80)
+FORWARD 30 -> (30,80)
+RIGHT 90 -> (30,80)
+PEN UP -> (30,80)
+SETPOSITION (-10, 5) -> (-10,5)
+`, trace.String())
+
+	trace.Reset()
+	interp.SetTrace(nil)
+	_, err = interp.Execute("fd 10")
+	assert.NoError(t, err)
+	assert.Empty(t, trace.String())
+}
+
+func TestProfile(t *testing.T) {
+	interp := New()
+	profile, err := interp.Profile("repeat 10 [ fd 10 rt 36 ] pu")
+	assert.NoError(t, err)
+
+	types := []string{}
+	var total time.Duration
+	for name, d := range profile {
+		types = append(types, name)
+		total += d
+	}
+	assert.ElementsMatch(t, []string{"RepeatCommand", "ForwardCommand", "RightCommand", "PenUpCommand"}, types)
+	assert.Positive(t, total)
+
+	// Commands with variable arguments are charged to the command they run
+	profile, err = interp.Profile(`make "y 10 fd :y rt :y`)
+	assert.NoError(t, err)
+	assert.Contains(t, profile, "ForwardCommand")
+	assert.Contains(t, profile, "RightCommand")
+	assert.NotContains(t, profile, "DeferredCommand")
+
+	_, err = interp.Profile("fd")
+	assert.Error(t, err)
+}
+
+func TestFractionalTurns(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute("repeat 3600 [ rt 0.1 ]")
+	assert.NoError(t, err)
+	assert.InDelta(t, 270.0, interp.GetTurtle().Heading(), 1e-4)
+}
+
+func TestCompassHeadings(t *testing.T) {
+	interp := New()
+	var out bytes.Buffer
+	interp.SetOutput(&out)
+
+	_, err := interp.Execute(`compass setheadings "compass seth 0 fd 10 rt 45 compass print heading`)
+	assert.NoError(t, err)
+	assert.Equal(t, "N 0°\nNE 45°\n45\n", out.String())
+	assert.InDelta(t, 10, interp.GetTurtle().GetY(), 1e-4)
+
+	_, err = interp.Execute(`setheadings "polar`)
+	assert.Error(t, err)
+}
+
+func TestSetMaxSteps(t *testing.T) {
+	interp := New()
+	interp.SetMaxSteps(100)
+
+	_, err := interp.Execute("repeat 1000000000 [ fd 1 rt 1 ]")
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+	// Each pass of the loop is a step as well as its two commands
+	assert.Equal(t, float32(33), interp.GetTurtle().Heading()-270)
+
+	// Each run gets the whole allowance
+	_, err = interp.Execute("repeat 33 [ fd 1 rt 1 ]")
+	assert.NoError(t, err)
+
+	// Loops count their passes even when they run no commands
+	_, err = interp.Execute("while 1 [ ]")
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+	_, err = interp.Execute("repeat 100 [ repeat 100 [ ] ]")
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+	_, err = interp.Execute("dotimes [i 1000] [ ]")
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+	_, err = interp.Execute(`to spin
+  rt 1 spin
+end
+spin`)
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+
+	interp.SetMaxSteps(0)
+	_, err = interp.Execute("repeat 200 [ fd 1 ]")
+	assert.NoError(t, err)
+}
+
+func TestDisc(t *testing.T) {
+	interp := New()
+	_, err := interp.Execute(`setpc "red pu fd 20 disc 10 disc 2 + 3`)
+	assert.NoError(t, err)
+
+	discs := interp.Drawing().Discs()
+	assert.Len(t, discs, 2)
+	assert.InDelta(t, 20, discs[0].Y, 1e-4)
+	assert.Equal(t, 10.0, discs[0].Radius)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, discs[0].Color)
+	assert.Equal(t, 5.0, discs[1].Radius)
+
+	_, err = interp.Execute("disc -1")
+	assert.Error(t, err)
 }
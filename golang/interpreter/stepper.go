@@ -0,0 +1,191 @@
+package interpreter
+
+import (
+	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/drawing"
+	"github.com/honeylogo/logo/turtle"
+)
+
+// Stepper runs a program one step at a time, for building a debugger. A
+// step runs one command, descending into REPEAT, IF, WHILE and procedure
+// calls: starting each pass of a loop, entering an IF whose condition holds
+// and entering a procedure are steps of their own, and the commands inside
+// follow as further steps. Other commands, such as DOTIMES and ASK, run
+// whole in a single step.
+type Stepper struct {
+	turtle  *turtle.Turtle
+	context *ast.Context
+	frames  []*frame
+}
+
+// frame is a block of commands being stepped through
+type frame struct {
+	commands []ast.Command
+	index    int // Index of the next command to run
+	// again, when set, reports whether the block runs once more after its
+	// last command, and next does the bookkeeping for starting a pass
+	again func() (bool, error)
+	next  func()
+	// exit, when set, is called once the block is finished with
+	exit func()
+}
+
+// NewStepper creates a stepper that runs program on a fresh headless turtle
+func NewStepper(program *ast.Program) *Stepper {
+	t := turtle.NewHeadless()
+	return &Stepper{
+		turtle:  t,
+		context: ast.NewContext(t),
+		frames:  []*frame{{commands: program.Commands}},
+	}
+}
+
+// Turtle returns the turtle the commands run so far are directed at
+func (s *Stepper) Turtle() *turtle.Turtle {
+	return s.context.Turtle
+}
+
+// Drawing returns what the commands run so far have drawn
+func (s *Stepper) Drawing() *drawing.Drawing {
+	return s.context.Turtles.Drawing()
+}
+
+// Step runs the next step of the program, reporting whether the program has
+// finished. After an error the program is abandoned and done is true.
+func (s *Stepper) Step() (done bool, err error) {
+	if err := s.settle(); err != nil {
+		return s.abandon(err)
+	}
+	if len(s.frames) == 0 {
+		return true, nil
+	}
+
+	top := s.frames[len(s.frames)-1]
+	if top.index == len(top.commands) {
+		// Start the next pass of a loop
+		top.index = 0
+		top.next()
+	} else {
+		cmd := top.commands[top.index]
+		top.index++
+		if err := s.run(cmd); err != nil {
+			return s.abandon(err)
+		}
+	}
+
+	if err := s.settle(); err != nil {
+		return s.abandon(err)
+	}
+	return len(s.frames) == 0, nil
+}
+
+// run carries out one command, pushing a frame for the blocks it descends
+// into rather than running them
+func (s *Stepper) run(cmd ast.Command) error {
+	switch c := cmd.(type) {
+	case *ast.RepeatCommand:
+		if c.Times > 0 {
+			vars := s.context.Variables
+			previous, existed := vars[ast.RepCount]
+			pass := 1
+			vars[ast.RepCount] = 1
+			s.push(&frame{
+				commands: c.Commands,
+				again:    func() (bool, error) { return pass < c.Times, nil },
+				next: func() {
+					pass++
+					vars[ast.RepCount] = float32(pass)
+				},
+				exit: func() {
+					if existed {
+						vars[ast.RepCount] = previous
+					} else {
+						delete(vars, ast.RepCount)
+					}
+				},
+			})
+		}
+	case *ast.IfCommand:
+		holds, err := s.holds(c.Condition)
+		if err != nil {
+			return err
+		}
+		if holds {
+			s.push(&frame{commands: c.Commands})
+		}
+	case *ast.WhileCommand:
+		holds, err := s.holds(c.Condition)
+		if err != nil {
+			return err
+		}
+		if holds {
+			s.push(&frame{
+				commands: c.Commands,
+				again:    func() (bool, error) { return s.holds(c.Condition) },
+				next:     func() {},
+			})
+		}
+	case *ast.ProcedureCallCommand:
+		body, exit, err := c.Enter(s.context)
+		if err != nil {
+			return err
+		}
+		s.push(&frame{commands: body, exit: exit})
+	default:
+		return cmd.Execute(s.context)
+	}
+	return nil
+}
+
+// holds evaluates a condition
+func (s *Stepper) holds(condition ast.Expression) (bool, error) {
+	value, err := condition.Evaluate(s.context)
+	if err != nil {
+		return false, err
+	}
+	return ast.IsTrue(value), nil
+}
+
+// push starts stepping through a block
+func (s *Stepper) push(f *frame) {
+	s.frames = append(s.frames, f)
+}
+
+// settle pops the blocks that have no commands left to run, so that an
+// empty stack means the program has finished
+func (s *Stepper) settle() error {
+	for len(s.frames) > 0 {
+		top := s.frames[len(s.frames)-1]
+		if top.index < len(top.commands) {
+			return nil
+		}
+		if top.again != nil {
+			again, err := top.again()
+			if err != nil {
+				return err
+			}
+			if again {
+				return nil
+			}
+		}
+		s.pop()
+	}
+	return nil
+}
+
+// pop finishes with the innermost block
+func (s *Stepper) pop() {
+	top := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+	if top.exit != nil {
+		top.exit()
+	}
+}
+
+// abandon finishes with every block after an error
+func (s *Stepper) abandon(err error) (bool, error) {
+	for len(s.frames) > 0 {
+		s.pop()
+	}
+	return true, err
+}
@@ -0,0 +1,110 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepperRepeat(t *testing.T) {
+	program, err := parser.ParseProgram("repeat 2 [ fd 10 ]")
+	assert.NoError(t, err)
+	stepper := NewStepper(program)
+
+	// Each pass of the loop is a step, followed by the forward inside it
+	expected := []struct {
+		y    float32
+		done bool
+	}{
+		{0, false},
+		{10, false},
+		{10, false},
+		{20, true},
+	}
+	for i, step := range expected {
+		done, err := stepper.Step()
+		assert.NoError(t, err)
+		assert.Equal(t, step.done, done, "step %d", i+1)
+		assert.InDelta(t, step.y, stepper.Turtle().GetY(), 0.001, "step %d", i+1)
+	}
+	assert.Len(t, stepper.Drawing().Segments(), 2)
+
+	done, err := stepper.Step()
+	assert.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestStepperRepCount(t *testing.T) {
+	program, err := parser.ParseProgram("repeat 3 [ fd repcount ]")
+	assert.NoError(t, err)
+	stepper := NewStepper(program)
+
+	for done := false; !done; {
+		done, err = stepper.Step()
+		assert.NoError(t, err)
+	}
+	assert.InDelta(t, 6, stepper.Turtle().GetY(), 0.001)
+	_, exists := stepper.context.Variables["repcount"]
+	assert.False(t, exists)
+
+	// An outer count comes back once an inner REPEAT finishes
+	program, err = parser.ParseProgram("repeat 2 [ repeat 3 [ ] fd repcount ]")
+	assert.NoError(t, err)
+	stepper = NewStepper(program)
+	for done := false; !done; {
+		done, err = stepper.Step()
+		assert.NoError(t, err)
+	}
+	assert.InDelta(t, 3, stepper.Turtle().GetY(), 0.001)
+}
+
+func TestStepperProcedures(t *testing.T) {
+	program, err := parser.ParseProgram(`to side :n
+if :n > 5 [ fd :n ]
+rt 90
+end
+make "n 1
+side 10
+side 2`)
+	assert.NoError(t, err)
+	stepper := NewStepper(program)
+
+	steps := 0
+	for done := false; !done; steps++ {
+		done, err = stepper.Step()
+		assert.NoError(t, err)
+
+		// The input is bound only while the procedure runs
+		if steps == 3 {
+			assert.Equal(t, float32(10), stepper.context.Variables["n"])
+		}
+	}
+	// to, make, side, if, fd, rt, side, if, rt
+	assert.Equal(t, 9, steps)
+	assert.Equal(t, float32(1), stepper.context.Variables["n"])
+	assert.InDelta(t, 10.0, stepper.Turtle().GetY(), 0.001)
+	assert.InDelta(t, 90.0, stepper.Turtle().Heading(), 0.001)
+}
+
+func TestStepperWhileAndErrors(t *testing.T) {
+	program, err := parser.ParseProgram(`make "i 0 while :i < 2 [ make "i :i + 1 ] fd :missing fd 10`)
+	assert.NoError(t, err)
+	stepper := NewStepper(program)
+
+	// make, while, make, while pass, make
+	for i := 0; i < 5; i++ {
+		done, err := stepper.Step()
+		assert.NoError(t, err)
+		assert.False(t, done)
+	}
+	assert.Equal(t, float32(2), stepper.context.Variables["i"])
+
+	done, err := stepper.Step()
+	assert.ErrorContains(t, err, "missing has no value")
+	assert.True(t, done)
+	done, err = stepper.Step()
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Empty(t, stepper.Drawing().Segments())
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/honeylogo/logo/ast"
+)
+
+// Metrics summarizes the size and complexity of a program
+type Metrics struct {
+	// Commands is the total number of commands, including those nested in
+	// blocks and procedure bodies. Procedure definitions are not counted.
+	Commands int
+	// Depth is the deepest level of block nesting, 0 for a flat program
+	Depth int
+	// Procedures is the number of procedures defined
+	Procedures int
+	// DistinctCommands is the number of different commands used
+	DistinctCommands int
+	// HasLoops reports whether the program contains any loop
+	HasLoops bool
+}
+
+// Analyze parses a program and computes its metrics
+func Analyze(input string) (Metrics, error) {
+	program, err := ParseProgram(input)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	metrics := Metrics{}
+	distinct := map[string]bool{}
+	err = ast.Walk(program, func(cmd ast.Command) error {
+		switch cmd.(type) {
+		case *ast.Program:
+			return nil
+		case *ast.ProcedureDefinition:
+			metrics.Procedures++
+			return nil
+		case *ast.RepeatCommand, *ast.WhileCommand, *ast.DoTimesCommand, *ast.ForEachCommand:
+			metrics.HasLoops = true
+		}
+		metrics.Commands++
+		distinct[commandKind(cmd)] = true
+		return nil
+	})
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	metrics.DistinctCommands = len(distinct)
+	metrics.Depth = blockDepth(program.Commands)
+	return metrics, nil
+}
+
+// commandKind names the command cmd runs, for counting distinct commands.
+// Commands with variable arguments are named after the type of command the
+// command table creates for them, the same as the command with constant
+// arguments, and procedure calls after the procedure they call.
+func commandKind(cmd ast.Command) string {
+	switch c := cmd.(type) {
+	case *ast.DeferredCommand:
+		if def, exists := findCommandDefinition(c.Name); exists {
+			return fmt.Sprintf("%T", def.CreateCommand(make([]float32, def.ArgCount)))
+		}
+		return c.Name
+	case *ast.ProcedureCallCommand:
+		return "call " + c.Name
+	}
+	return fmt.Sprintf("%T", cmd)
+}
+
+// blockDepth returns how deeply blocks nest within the given commands
+func blockDepth(commands []ast.Command) int {
+	deepest := 0
+	for _, cmd := range commands {
+		children := ast.Children(cmd)
+		if children == nil {
+			continue
+		}
+		if d := 1 + blockDepth(children); d > deepest {
+			deepest = d
+		}
+	}
+	return deepest
+}
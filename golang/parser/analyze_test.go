@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		program  string
+		expected Metrics
+	}{
+		{
+			program:  "",
+			expected: Metrics{},
+		},
+		{
+			program:  "fd 10 rt 90 fd 10",
+			expected: Metrics{Commands: 3, DistinctCommands: 2},
+		},
+		{
+			program:  "repeat 4 [ fd 50 rt 90 ]",
+			expected: Metrics{Commands: 3, Depth: 1, DistinctCommands: 3, HasLoops: true},
+		},
+		{
+			program: `to square :size
+  repeat 4 [ fd 50 rt 90 ]
+end
+to spin
+  repeat 2 [ repeat 3 [ rt 60 ] pu ]
+end
+home`,
+			expected: Metrics{Commands: 8, Depth: 3, Procedures: 2, DistinctCommands: 5, HasLoops: true},
+		},
+		{
+			program:  `make "x 10 fd :x rt :x bk :x fd :x`,
+			expected: Metrics{Commands: 5, DistinctCommands: 4},
+		},
+		{
+			program:  `make "x 10 fd 10 fd :x setxy :x 0 setpos [1 2]`,
+			expected: Metrics{Commands: 5, DistinctCommands: 3},
+		},
+		{
+			program: `to a
+end
+to b
+end
+a b a`,
+			expected: Metrics{Commands: 3, Depth: 1, Procedures: 2, DistinctCommands: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		metrics, err := Analyze(tt.program)
+		assert.NoError(t, err, tt.program)
+		assert.Equal(t, tt.expected, metrics, tt.program)
+	}
+}
+
+func TestAnalyzeError(t *testing.T) {
+	_, err := Analyze("repeat 4 [ fd 50")
+	assert.Error(t, err)
+}
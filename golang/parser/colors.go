@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/honeylogo/logo/ast"
+)
+
+// findNamedColor finds a standard color by name
+func findNamedColor(name string) (ast.NamedColor, bool) {
+	for _, c := range ast.StandardColors {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return ast.NamedColor{}, false
+}
+
+// parseHexColor parses a color written in hex as #rgb or #rrggbb, where a
+// three-digit color such as #f80 is short for #ff8800
+func parseHexColor(text string) (uint8, uint8, uint8, error) {
+	digits := strings.TrimPrefix(text, "#")
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	if len(digits) != 6 {
+		return 0, 0, 0, fmt.Errorf("hex color %q must have 3 or 6 digits", text)
+	}
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("hex color %q can only contain the digits 0-9 and a-f", text)
+	}
+	return uint8(value >> 16), uint8(value >> 8), uint8(value), nil
+}
+
+// parseSetPenColor parses SETPENCOLOR, which takes a color name such as
+// "red, a hex color such as "#ff8800, a single palette index or three red,
+// green and blue values
+func parseSetPenColor(tokens []Token, start int) (ast.Command, int, error) {
+	if start+1 < len(tokens) && tokens[start+1].Type == StringToken && strings.HasPrefix(tokens[start+1].Value, "#") {
+		r, g, b, err := parseHexColor(tokens[start+1].Value)
+		if err != nil {
+			return nil, 0, argumentError(tokens, start, "%v", err)
+		}
+		return ast.NewSetColorCommand(r, g, b), start + 2, nil
+	}
+	if start+1 < len(tokens) && tokens[start+1].Type == StringToken {
+		c, exists := findNamedColor(strings.ToLower(tokens[start+1].Value))
+		if !exists {
+			names := make([]string, len(ast.StandardColors))
+			for i, sc := range ast.StandardColors {
+				names[i] = sc.Name
+			}
+			return nil, 0, argumentError(tokens, start, "unknown color %q, valid colors are: %s",
+				tokens[start+1].Value, strings.Join(names, ", "))
+		}
+		return ast.NewSetColorCommand(c.Color.R, c.Color.G, c.Color.B), start + 2, nil
+	}
+
+	values, next, err := parseColorValues(tokens, start)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch len(values) {
+	case 1:
+		index := values[0]
+		if index != float32(math.Trunc(float64(index))) || index < 0 || index >= ast.PaletteSize {
+			return nil, 0, argumentError(tokens, start, "palette index must be a whole number between 0 and %d, got %s",
+				ast.PaletteSize-1, formatNumber(index))
+		}
+		return ast.NewSetPaletteColorCommand(int(index)), next, nil
+
+	case 3:
+		for _, value := range values {
+			if value < 0 || value > 255 {
+				return nil, 0, argumentError(tokens, start, "color values must be between 0 and 255")
+			}
+		}
+		return ast.NewSetColorCommand(uint8(values[0]), uint8(values[1]), uint8(values[2])), next, nil
+	}
+
+	return nil, 0, argumentError(tokens, start, "setpencolor command requires a color name, a hex color, a palette index or red, green and blue values")
+}
+
+// parseColorValues reads up to three constant numbers following the color
+// command at start, returning them and the index of the token after them
+func parseColorValues(tokens []Token, start int) ([]float32, int, error) {
+	values := []float32{}
+	next := start + 1
+	for len(values) < 3 && next < len(tokens) && isExpressionStart(tokens[next]) {
+		expr, after, err := parseExpression(tokens, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, err := expr.Evaluate(nil)
+		if err != nil {
+			return nil, 0, argumentError(tokens, start, "%s command: %v", tokens[start].Value, err)
+		}
+		values = append(values, value)
+		next = after
+	}
+	return values, next, nil
+}
+
+// parseSetPenHSV parses SETPENHSV, which takes a hue in degrees and a
+// saturation and value between 0 and 1, and sets the equivalent RGB color
+func parseSetPenHSV(tokens []Token, start int) (ast.Command, int, error) {
+	values, next, err := parseColorValues(tokens, start)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(values) != 3 {
+		return nil, 0, argumentError(tokens, start, "setpenhsv command requires hue, saturation and value")
+	}
+
+	h, s, v := values[0], values[1], values[2]
+	if h < 0 || h >= 360 {
+		return nil, 0, argumentError(tokens, start, "hue must be at least 0 and less than 360, got %s", formatNumber(h))
+	}
+	if s < 0 || s > 1 || v < 0 || v > 1 {
+		return nil, 0, argumentError(tokens, start, "saturation and value must be between 0 and 1")
+	}
+	r, g, b := hsvToRGB(h, s, v)
+	return ast.NewSetColorCommand(r, g, b), next, nil
+}
+
+// hsvToRGB converts a hue in degrees and a saturation and value between 0
+// and 1 to red, green and blue values
+func hsvToRGB(h, s, v float32) (uint8, uint8, uint8) {
+	c := float64(v * s)
+	x := c * (1 - math.Abs(math.Mod(float64(h)/60, 2)-1))
+	m := float64(v) - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	channel := func(value float64) uint8 {
+		return uint8(math.Round((value + m) * 255))
+	}
+	return channel(r), channel(g), channel(b)
+}
+
+// validatePenGradient checks SETPENGRADIENT's two red, green and blue colors
+// and its whole number of steps
+func validatePenGradient(args []float32) error {
+	for _, value := range args[:6] {
+		if value < 0 || value > 255 {
+			return fmt.Errorf("color values must be between 0 and 255")
+		}
+	}
+	steps := args[6]
+	if steps != float32(math.Trunc(float64(steps))) || steps < 2 {
+		return fmt.Errorf("steps must be a whole number of at least 2, got %s", formatNumber(steps))
+	}
+	return nil
+}
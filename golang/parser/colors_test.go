@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPenColorName(t *testing.T) {
+	program, err := ParseProgram(`setpencolor "red`)
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{ast.NewSetColorCommand(255, 0, 0)}, program.Commands)
+
+	program, err = ParseProgram(`setpc "Blue fd 10`)
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 2)
+	assert.Equal(t, ast.NewSetColorCommand(0, 0, 255), program.Commands[0])
+}
+
+func TestSetPenColorRGB(t *testing.T) {
+	program, err := ParseProgram("setpencolor 255 128 0")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{ast.NewSetColorCommand(255, 128, 0)}, program.Commands)
+
+	_, err = ParseProgram("setpencolor 255 128")
+	assert.ErrorContains(t, err, "requires a color name, a hex color, a palette index or red, green and blue values")
+
+	_, err = ParseProgram("setpencolor 300 0 0")
+	assert.ErrorContains(t, err, "between 0 and 255")
+}
+
+func TestSetPenColorUnknownName(t *testing.T) {
+	_, err := ParseProgram(`setpencolor "chartreuse`)
+	assert.ErrorContains(t, err, `unknown color "chartreuse"`)
+	assert.ErrorContains(t, err, "red")
+	assert.ErrorContains(t, err, "magenta")
+}
+
+func TestSetPenColorHex(t *testing.T) {
+	program, err := ParseProgram(`setpencolor "#ff8800 fd 10`)
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 2)
+	assert.Equal(t, ast.NewSetColorCommand(255, 136, 0), program.Commands[0])
+
+	program, err = ParseProgram(`setpc "#F80`)
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{ast.NewSetColorCommand(255, 136, 0)}, program.Commands)
+
+	_, err = ParseProgram(`setpencolor "#xyz`)
+	assert.ErrorContains(t, err, `hex color "#xyz" can only contain the digits 0-9 and a-f`)
+
+	_, err = ParseProgram(`setpencolor "#ff88`)
+	assert.ErrorContains(t, err, `hex color "#ff88" must have 3 or 6 digits`)
+}
+
+func TestSetPenColorIndex(t *testing.T) {
+	program, err := ParseProgram("setpencolor 4 fd 10")
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 2)
+	assert.Equal(t, ast.NewSetPaletteColorCommand(4), program.Commands[0])
+
+	for _, invalid := range []string{"setpencolor 16", "setpencolor -1", "setpencolor 2.5"} {
+		_, err = ParseProgram(invalid)
+		assert.ErrorContains(t, err, "palette index must be a whole number between 0 and 15", invalid)
+	}
+}
+
+func TestHSVToRGB(t *testing.T) {
+	tests := []struct {
+		h, s, v float32
+		rgb     [3]uint8
+	}{
+		{0, 1, 1, [3]uint8{255, 0, 0}},
+		{120, 1, 1, [3]uint8{0, 255, 0}},
+		{240, 1, 1, [3]uint8{0, 0, 255}},
+		{60, 1, 1, [3]uint8{255, 255, 0}},
+		{300, 1, 1, [3]uint8{255, 0, 255}},
+		{0, 0, 1, [3]uint8{255, 255, 255}},
+		{0, 0, 0.5, [3]uint8{128, 128, 128}},
+		{200, 0.5, 0.8, [3]uint8{102, 170, 204}},
+	}
+
+	for _, tt := range tests {
+		r, g, b := hsvToRGB(tt.h, tt.s, tt.v)
+		assert.Equal(t, tt.rgb, [3]uint8{r, g, b}, "hsv %v %v %v", tt.h, tt.s, tt.v)
+	}
+}
+
+func TestSetPenHSV(t *testing.T) {
+	program, err := ParseProgram("setpenhsv 0 1 1 fd 10")
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 2)
+	assert.Equal(t, ast.NewSetColorCommand(255, 0, 0), program.Commands[0])
+
+	program, err = ParseProgram("setpenhsv 60 * 2 0.5 + 0.5 1")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{ast.NewSetColorCommand(0, 255, 0)}, program.Commands)
+
+	_, err = ParseProgram("setpenhsv 0 1")
+	assert.ErrorContains(t, err, "setpenhsv command requires hue, saturation and value")
+
+	_, err = ParseProgram("setpenhsv 360 1 1")
+	assert.ErrorContains(t, err, "hue must be at least 0 and less than 360, got 360")
+
+	_, err = ParseProgram("setpenhsv 0 1.5 1")
+	assert.ErrorContains(t, err, "saturation and value must be between 0 and 1")
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// UnknownCommandError reports a word that isn't a command or a procedure
+type UnknownCommandError struct {
+	Name string
+	Pos  Position
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command: %s at %s", e.Name, e.Pos)
+}
+
+// ArgumentError reports a command whose arguments are missing or invalid
+type ArgumentError struct {
+	Command string
+	Pos     Position
+	Reason  string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("%s at %s", e.Reason, e.Pos)
+}
+
+// SyntaxError reports input that doesn't fit the structure of a program,
+// such as unbalanced brackets or a misplaced token
+type SyntaxError struct {
+	Pos     Position
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at %s", e.Message, e.Pos)
+}
+
+// argumentError creates an ArgumentError for the command token at start
+func argumentError(tokens []Token, start int, format string, args ...any) *ArgumentError {
+	return &ArgumentError{
+		Command: tokens[start].Value,
+		Pos:     tokens[start].Pos,
+		Reason:  fmt.Sprintf(format, args...),
+	}
+}
+
+// syntaxError creates a SyntaxError at the given position
+func syntaxError(pos Position, format string, args ...any) *SyntaxError {
+	return &SyntaxError{
+		Pos:     pos,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownCommandError(t *testing.T) {
+	err := Validate("fd 10\ndance 5")
+	var unknown *UnknownCommandError
+	if assert.True(t, errors.As(err, &unknown)) {
+		assert.Equal(t, "dance", unknown.Name)
+		assert.Equal(t, Position{Line: 2, Column: 1}, unknown.Pos)
+	}
+}
+
+func TestArgumentError(t *testing.T) {
+	tests := []struct {
+		program string
+		command string
+	}{
+		{"fd 10 forward", "forward"},
+		{"setxy 10", "setxy"},
+		{"repeat 4 fd 10", "repeat"},
+		{"setpencolor 300 0 0", "setpencolor"},
+		{"fd quotient 1 0", "forward"},
+	}
+
+	for _, tt := range tests {
+		var argument *ArgumentError
+		if assert.True(t, errors.As(Validate(tt.program), &argument), tt.program) {
+			assert.Equal(t, tt.command, argument.Command, tt.program)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		program string
+		pos     Position
+	}{
+		{"repeat 2 [ fd 10", Position{Line: 1, Column: 10}},
+		{"fd 10 ]", Position{Line: 1, Column: 7}},
+		{"end", Position{Line: 1, Column: 1}},
+		{"fd 10 20", Position{Line: 1, Column: 7}},
+	}
+
+	for _, tt := range tests {
+		var syntax *SyntaxError
+		if assert.True(t, errors.As(Validate(tt.program), &syntax), tt.program) {
+			assert.Equal(t, tt.pos, syntax.Pos, tt.program)
+		}
+	}
+
+	// Errors collected while recovering keep their concrete types
+	_, errs := ParseProgramCollectErrors("dance\nforward")
+	if assert.Len(t, errs, 2) {
+		assert.IsType(t, &UnknownCommandError{}, errs[0].Err)
+		assert.IsType(t, &ArgumentError{}, errs[1].Err)
+	}
+}
+
+func TestWrongArgumentType(t *testing.T) {
+	tests := []struct {
+		program string
+		message string
+	}{
+		{`fd "abc`, `forward expects a number or expression but got STRING "abc" at line 1, column 1`},
+		{"fd 10\nrt * 5", `right expects a number or expression but got OPERATOR "*" at line 2, column 1`},
+		{"fd [ 10 ]", `forward expects a number or expression but got OPEN_BRACKET "[" at line 1, column 1`},
+		{`setxy 10 "up`, `setxy expects a number or expression but got STRING "up"`},
+		{`make "x "y`, `make expects a number or expression but got STRING "y"`},
+		{`if "yes [ fd 10 ]`, `if expects a number or expression but got STRING "yes"`},
+		{`test "yes`, `test expects a number or expression but got STRING "yes"`},
+		{`dotimes [i "ten] [ fd 10 ]`, `dotimes expects a number or expression but got STRING "ten"`},
+		{`fd sum 1 "two`, `sum expects a number or expression but got STRING "two"`},
+		{"to hop :n\nfd :n\nend\nhop \"far", `hop expects a number or expression but got STRING "far"`},
+	}
+
+	for _, tt := range tests {
+		err := Validate(tt.program)
+		assert.ErrorContains(t, err, tt.message, tt.program)
+		var argument *ArgumentError
+		assert.True(t, errors.As(err, &argument), tt.program)
+	}
+
+	// A missing argument is still reported as missing
+	assert.ErrorContains(t, Validate("fd\npu"), "forward command requires a number argument")
+	assert.ErrorContains(t, Validate("repeat 2 [ rt ]"), "right command requires a number argument")
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/turtle"
+)
+
+// MaxFlattenSteps is how many commands Flatten runs before giving up, which
+// stops loops and recursion that never finish
+const MaxFlattenSteps = 1000000
+
+// Flatten parses a program and unrolls it into the sequence of primitives it
+// runs, for exporting to formats without loops, such as segment lists or
+// GCODE. Loops are unrolled, conditions decided and procedure calls inlined
+// by running the program on a headless turtle, and arguments that use
+// variables are replaced by the values they had. Commands that only affect
+// the running program, such as MAKE and PRINT, are left out. ASK can't be
+// flattened, as a flat sequence has no way to return to the turtle it
+// interrupted. SAVEPICT is kept but doesn't write a file while flattening.
+// A program that runs more than MaxFlattenSteps commands fails
+// with an error wrapping ast.ErrStepLimit.
+func Flatten(program string) ([]ast.Command, error) {
+	parsed, err := ParseProgram(program)
+	if err != nil {
+		return nil, err
+	}
+
+	var flat []ast.Command
+	err = ast.Walk(parsed, func(cmd ast.Command) error {
+		if _, ok := cmd.(*ast.AskCommand); ok {
+			return fmt.Errorf("cannot flatten %s", cmd.String())
+		}
+		children := ast.Children(cmd)
+		for i, child := range children {
+			if !ast.HasBlock(child) {
+				children[i] = &flatCommand{cmd: child, flat: &flat}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := ast.NewContext(turtle.NewHeadless())
+	ctx.Output = io.Discard
+	ctx.SkipWaits = true
+	ctx.MaxSteps = MaxFlattenSteps
+	for _, cmd := range parsed.Commands {
+		if err := ctx.Run(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return flat, nil
+}
+
+// flatCommand stands in for a command without a block while Flatten runs a
+// program, adding the primitive it runs to the flat sequence
+type flatCommand struct {
+	cmd  ast.Command
+	flat *[]ast.Command
+}
+
+// Execute resolves the command's arguments, records it if it's a primitive
+// and runs it
+func (fc *flatCommand) Execute(ctx *ast.Context) error {
+	cmd := fc.cmd
+	if dc, ok := cmd.(*ast.DeferredCommand); ok {
+		resolved, err := dc.Resolve(ctx)
+		if err != nil {
+			return err
+		}
+		cmd = resolved
+	}
+	switch cmd.(type) {
+	case *ast.ProcedureCallCommand, *ast.MakeCommand, *ast.PrintCommand, *ast.TestCommand,
+		*ast.WaitCommand, *ast.CompassCommand, *ast.EraseCommand:
+	case *ast.SavePictCommand:
+		// Keep it for the export, without writing files while flattening
+		*fc.flat = append(*fc.flat, cmd)
+		return nil
+	default:
+		*fc.flat = append(*fc.flat, cmd)
+	}
+	return cmd.Execute(ctx)
+}
+
+func (fc *flatCommand) String() string {
+	return fc.cmd.String()
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenRepeat(t *testing.T) {
+	flat, err := Flatten("repeat 3 [ fd 10 ]")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{
+		ast.NewForwardCommand(10),
+		ast.NewForwardCommand(10),
+		ast.NewForwardCommand(10),
+	}, flat)
+}
+
+func TestFlattenProcedures(t *testing.T) {
+	// Calls are inlined with their arguments' values, and conditions are
+	// decided as the program runs
+	flat, err := Flatten(`to side :n
+  if :n > 15 [ pu ]
+  fd :n rt 90
+end
+make "size 10
+dotimes [i 2] [ side :size + :i * 10 ]
+print "done`)
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{
+		ast.NewForwardCommand(10),
+		ast.NewRightCommand(90),
+		ast.NewPenUpCommand(),
+		ast.NewForwardCommand(20),
+		ast.NewRightCommand(90),
+	}, flat)
+}
+
+func TestFlattenEmptyBlocks(t *testing.T) {
+	// Blocks with nothing in them are never taken for primitives
+	flat, err := Flatten(`to nothing
+end
+fd 10 repeat 3 [ ] if 1 [ ] dotimes [i 2] [ ] foreach [1 2] [ ] nothing fd 5`)
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{
+		ast.NewForwardCommand(10),
+		ast.NewForwardCommand(5),
+	}, flat)
+}
+
+func TestFlattenErrors(t *testing.T) {
+	_, err := Flatten("while 1 [ fd 1 ]")
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+	_, err = Flatten("while 1 [ ]")
+	assert.ErrorIs(t, err, ast.ErrStepLimit)
+
+	_, err = Flatten(`to spin
+  rt 1 spin
+end
+spin`)
+	assert.ErrorContains(t, err, "nested more than")
+
+	_, err = Flatten(`newturtle "bob ask "bob [ fd 10 ]`)
+	assert.ErrorContains(t, err, "cannot flatten")
+
+	_, err = Flatten("repeat 3 [ fd 10")
+	assert.Error(t, err)
+}
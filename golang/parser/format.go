@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/honeylogo/logo/ast"
+)
+
+// indent is the indentation used for each level of nesting
+const indent = "  "
+
+// Format parses a program and re-emits it in canonical form: one command per
+// line, full lower-case command names, blocks indented by two spaces per
+// level of nesting and numbers written without redundant decimals.
+func Format(input string) (string, error) {
+	program, err := ParseProgram(input)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, cmd := range program.Commands {
+		if err := formatCommand(&b, cmd, 0); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// FormatCommand re-emits a single parsed command in the canonical form
+// used by Format
+func FormatCommand(cmd ast.Command) (string, error) {
+	var b strings.Builder
+	if err := formatCommand(&b, cmd, 0); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// formatCommand writes the canonical source of cmd at the given depth
+func formatCommand(b *strings.Builder, cmd ast.Command, depth int) error {
+	prefix := strings.Repeat(indent, depth)
+
+	switch c := cmd.(type) {
+	case *ast.ForwardCommand:
+		fmt.Fprintf(b, "%sforward %s\n", prefix, formatNumber(c.Distance))
+	case *ast.BackwardCommand:
+		fmt.Fprintf(b, "%sbackward %s\n", prefix, formatNumber(c.Distance))
+	case *ast.LeftCommand:
+		fmt.Fprintf(b, "%sleft %s\n", prefix, formatNumber(c.Angle))
+	case *ast.RightCommand:
+		fmt.Fprintf(b, "%sright %s\n", prefix, formatNumber(c.Angle))
+	case *ast.PenUpCommand:
+		fmt.Fprintf(b, "%spenup\n", prefix)
+	case *ast.PenDownCommand:
+		fmt.Fprintf(b, "%spendown\n", prefix)
+	case *ast.SetColorCommand:
+		fmt.Fprintf(b, "%ssetpencolor %d %d %d\n", prefix, c.R, c.G, c.B)
+	case *ast.SetPaletteColorCommand:
+		fmt.Fprintf(b, "%ssetpencolor %d\n", prefix, c.Index)
+	case *ast.SetPenGradientCommand:
+		fmt.Fprintf(b, "%ssetpengradient %d %d %d %d %d %d %d\n", prefix,
+			c.From[0], c.From[1], c.From[2], c.To[0], c.To[1], c.To[2], c.Steps)
+	case *ast.SetShapeCommand:
+		fmt.Fprintf(b, "%ssetshape \"%s\n", prefix, c.Shape)
+	case *ast.SetHeadingConventionCommand:
+		fmt.Fprintf(b, "%ssetheadings \"%s\n", prefix, c.Convention)
+	case *ast.SetPenPatternCommand:
+		fmt.Fprintf(b, "%ssetpenpattern \"%s\n", prefix, c.Pattern)
+	case *ast.SetPenSizeCommand:
+		fmt.Fprintf(b, "%ssetpensize %s\n", prefix, formatNumber(c.Size))
+	case *ast.RampPenSizeCommand:
+		fmt.Fprintf(b, "%srampensize %s %s %d\n", prefix, formatNumber(c.From), formatNumber(c.To), c.Steps)
+	case *ast.SetXCommand:
+		fmt.Fprintf(b, "%ssetx %s\n", prefix, formatNumber(c.X))
+	case *ast.SetYCommand:
+		fmt.Fprintf(b, "%ssety %s\n", prefix, formatNumber(c.Y))
+	case *ast.SetPositionCommand:
+		fmt.Fprintf(b, "%ssetxy %s %s\n", prefix, formatNumber(c.X), formatNumber(c.Y))
+	case *ast.SetHeadingCommand:
+		fmt.Fprintf(b, "%ssetheading %s\n", prefix, formatNumber(c.Angle))
+	case *ast.HomeCommand:
+		fmt.Fprintf(b, "%shome\n", prefix)
+	case *ast.ResetCommand:
+		fmt.Fprintf(b, "%sreset\n", prefix)
+	case *ast.MirrorCommand:
+		fmt.Fprintf(b, "%smirror\n", prefix)
+	case *ast.CompassCommand:
+		fmt.Fprintf(b, "%scompass\n", prefix)
+	case *ast.ArcRightCommand:
+		fmt.Fprintf(b, "%sarcr %s %s\n", prefix, formatNumber(c.Radius), formatNumber(c.Angle))
+	case *ast.ArcLeftCommand:
+		fmt.Fprintf(b, "%sarcl %s %s\n", prefix, formatNumber(c.Radius), formatNumber(c.Angle))
+	case *ast.SlideCommand:
+		fmt.Fprintf(b, "%sslide %s %s\n", prefix, formatNumber(c.DX), formatNumber(c.DY))
+	case *ast.SpiralCommand:
+		fmt.Fprintf(b, "%sspiral %s %s %s %d\n", prefix,
+			formatNumber(c.Start), formatNumber(c.Increment), formatNumber(c.Angle), c.Steps)
+	case *ast.PolygonCommand:
+		fmt.Fprintf(b, "%spolygon %d %s\n", prefix, c.Sides, formatNumber(c.Size))
+	case *ast.EllipseCommand:
+		fmt.Fprintf(b, "%sellipse %s %s\n", prefix, formatNumber(c.RX), formatNumber(c.RY))
+	case *ast.RectCommand:
+		fmt.Fprintf(b, "%srect %s %s\n", prefix, formatNumber(c.Width), formatNumber(c.Height))
+	case *ast.DiscCommand:
+		fmt.Fprintf(b, "%sdisc %s\n", prefix, formatNumber(c.Radius))
+	case *ast.WaitCommand:
+		fmt.Fprintf(b, "%swait %s\n", prefix, formatNumber(c.Ticks))
+	case *ast.MakeCommand:
+		fmt.Fprintf(b, "%smake \"%s %s\n", prefix, c.Name, formatExpression(c.Value))
+	case *ast.PrintCommand:
+		if c.Position {
+			fmt.Fprintf(b, "%sprint pos\n", prefix)
+		} else if c.Value != nil {
+			fmt.Fprintf(b, "%sprint %s\n", prefix, formatExpression(c.Value))
+		} else {
+			fmt.Fprintf(b, "%sprint %s\n", prefix, formatWord(c.Text))
+		}
+	case *ast.WriteVectorCommand:
+		fmt.Fprintf(b, "%swritevector %s\n", prefix, formatWord(c.Text))
+	case *ast.SavePictCommand:
+		fmt.Fprintf(b, "%ssavepict %s\n", prefix, formatWord(c.Name))
+	case *ast.LoadPictCommand:
+		fmt.Fprintf(b, "%sloadpict %s\n", prefix, formatWord(c.Name))
+	case *ast.EraseCommand:
+		fmt.Fprintf(b, "%serase \"%s\n", prefix, c.Name)
+	case *ast.NewTurtleCommand:
+		fmt.Fprintf(b, "%snewturtle \"%s\n", prefix, c.Name)
+	case *ast.TellCommand:
+		fmt.Fprintf(b, "%stell \"%s\n", prefix, c.Name)
+
+	case *ast.RepeatCommand:
+		if len(c.Commands) == 0 {
+			fmt.Fprintf(b, "%srepeat %d [ ]\n", prefix, c.Times)
+			return nil
+		}
+		fmt.Fprintf(b, "%srepeat %d [\n", prefix, c.Times)
+		for _, child := range c.Commands {
+			if err := formatCommand(b, child, depth+1); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "%s]\n", prefix)
+
+	case *ast.AskCommand:
+		return formatBlock(b, fmt.Sprintf("%sask \"%s", prefix, c.Name), c.Commands, depth)
+	case *ast.IfCommand:
+		return formatBlock(b, fmt.Sprintf("%sif %s", prefix, formatExpression(c.Condition)), c.Commands, depth)
+	case *ast.DoTimesCommand:
+		header := fmt.Sprintf("%sdotimes [%s %s]", prefix, c.Variable, formatExpression(c.Count))
+		return formatBlock(b, header, c.Commands, depth)
+	case *ast.ForEachCommand:
+		values := make([]string, len(c.Values))
+		for i, value := range c.Values {
+			values[i] = formatNumber(value)
+		}
+		header := fmt.Sprintf("%sforeach [%s]", prefix, strings.Join(values, " "))
+		if c.Variable != "?" {
+			header = fmt.Sprintf("%sforeach \"%s [%s]", prefix, c.Variable, strings.Join(values, " "))
+		}
+		return formatBlock(b, header, c.Commands, depth)
+	case *ast.DeferredCommand:
+		parts := []string{c.Name}
+		for _, arg := range c.Args {
+			parts = append(parts, formatExpression(arg))
+		}
+		fmt.Fprintf(b, "%s%s\n", prefix, strings.Join(parts, " "))
+	case *ast.TestCommand:
+		fmt.Fprintf(b, "%stest %s\n", prefix, formatExpression(c.Condition))
+	case *ast.IfTrueCommand:
+		return formatBlock(b, prefix+"iftrue", c.Commands, depth)
+	case *ast.IfFalseCommand:
+		return formatBlock(b, prefix+"iffalse", c.Commands, depth)
+	case *ast.WhileCommand:
+		return formatBlock(b, fmt.Sprintf("%swhile %s", prefix, formatExpression(c.Condition)), c.Commands, depth)
+
+	case *ast.ProcedureDefinition:
+		fmt.Fprintf(b, "%sto %s", prefix, c.Name)
+		for _, param := range c.Params {
+			fmt.Fprintf(b, " :%s", param)
+		}
+		b.WriteString("\n")
+		for _, child := range c.Body {
+			if err := formatCommand(b, child, depth+1); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "%send\n", prefix)
+
+	default:
+		return fmt.Errorf("cannot format command %s", cmd.String())
+	}
+
+	return nil
+}
+
+// formatBlock writes a command header followed by a bracketed block
+func formatBlock(b *strings.Builder, header string, commands []ast.Command, depth int) error {
+	if len(commands) == 0 {
+		fmt.Fprintf(b, "%s [ ]\n", header)
+		return nil
+	}
+	fmt.Fprintf(b, "%s [\n", header)
+	for _, child := range commands {
+		if err := formatCommand(b, child, depth+1); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(b, "%s]\n", strings.Repeat(indent, depth))
+	return nil
+}
+
+// formatExpression writes an expression in source form. The parser has no
+// parentheses, so operands never need them.
+func formatExpression(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.NumberExpression:
+		return formatNumber(e.Value)
+	case *ast.VariableExpression:
+		if e.Name == "?" || e.Name == ast.RepCount {
+			return e.Name
+		}
+		return ":" + e.Name
+	case *ast.TurtleExpression:
+		return e.Name
+	case *ast.BinaryExpression:
+		return fmt.Sprintf("%s %s %s", formatExpression(e.Left), e.Operator, formatExpression(e.Right))
+	case *ast.LogicalExpression:
+		return fmt.Sprintf("%s %s %s", e.Operator, formatExpression(e.Left), formatExpression(e.Right))
+	case *ast.FunctionExpression:
+		parts := []string{e.Name}
+		for _, arg := range e.Args {
+			parts = append(parts, formatExpression(arg))
+		}
+		return strings.Join(parts, " ")
+	}
+	return expr.String()
+}
+
+// formatWord writes a quoted word, closing the quote if the word has spaces
+func formatWord(text string) string {
+	if strings.ContainsFunc(text, unicode.IsSpace) {
+		return "\"" + text + "\""
+	}
+	return "\"" + text
+}
+
+// formatNumber writes a number in its shortest form, so 50 rather than 50.00
+func formatNumber(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
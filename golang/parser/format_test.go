@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRepeat(t *testing.T) {
+	formatted, err := Format("repeat 4 [fd 50 rt 90]")
+	assert.NoError(t, err)
+	assert.Equal(t, "repeat 4 [\n  forward 50\n  right 90\n]\n", formatted)
+}
+
+func TestFormatNested(t *testing.T) {
+	formatted, err := Format("PU  home PD\nREPEAT 2 [ repeat 3 [ FD 10.50 lt 30 ] bk sum 1 1 ] setps 2")
+	assert.NoError(t, err)
+	assert.Equal(t, `penup
+home
+pendown
+repeat 2 [
+  repeat 3 [
+    forward 10.5
+    left 30
+  ]
+  backward 2
+]
+setpensize 2
+`, formatted)
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	formatted, err := Format("repeat 4 [fd 50 repeat 2 [rt 45]] seth 90")
+	assert.NoError(t, err)
+
+	again, err := Format(formatted)
+	assert.NoError(t, err)
+	assert.Equal(t, formatted, again)
+}
+
+func TestFormatError(t *testing.T) {
+	_, err := Format("repeat 4 [ fd 50")
+	assert.Error(t, err)
+}
+
+func TestFormatTurtles(t *testing.T) {
+	formatted, err := Format(`newturtle "bob tell "bob ask "default [fd 10]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "newturtle \"bob\ntell \"bob\nask \"default [\n  forward 10\n]\n", formatted)
+}
+
+func TestFormatPrint(t *testing.T) {
+	formatted, err := Format(`MAKE "x sum 1 2 * 3 PR :x + 1 print "hi print "hello world"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "make \"x sum 1 2 * 3\nprint :x + 1\nprint \"hi\nprint \"hello world\"\n", formatted)
+}
+
+func TestFormatConditions(t *testing.T) {
+	formatted, err := Format(`if and :x > 0 not :x = 5 [fd 10] while :x < 3 [make "x :x + 1]`)
+	assert.NoError(t, err)
+	assert.Equal(t, `if and :x > 0 not :x = 5 [
+  forward 10
+]
+while :x < 3 [
+  make "x :x + 1
+]
+`, formatted)
+}
+
+func TestFormatDoTimes(t *testing.T) {
+	formatted, err := Format("dotimes [i 5] [fd :i * 10 rt 90]")
+	assert.NoError(t, err)
+	assert.Equal(t, "dotimes [i 5] [\n  forward :i * 10\n  right 90\n]\n", formatted)
+}
+
+func TestFormatForEach(t *testing.T) {
+	formatted, err := Format(`foreach [10 20.5] [fd ?] foreach "n [1] [rt :n]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "foreach [10 20.5] [\n  forward ?\n]\nforeach \"n [1] [\n  right :n\n]\n", formatted)
+}
+
+func TestFormatTest(t *testing.T) {
+	formatted, err := Format(`test :x > 1 ift [fd 10] iff []`)
+	assert.NoError(t, err)
+	assert.Equal(t, "test :x > 1\niftrue [\n  forward 10\n]\niffalse [ ]\n", formatted)
+}
@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/honeylogo/logo/ast"
+)
+
+// goHeader starts the Go source written by ToGo. The parser package is only
+// imported when a command's arguments must be evaluated as it runs.
+const goHeader = `// Code generated from a Logo program. DO NOT EDIT.
+
+package main
+
+`
+
+// ToGo parses a program and writes Go source for a Program function that
+// builds the same commands with the ast package's constructors, so the
+// program can be compiled into a binary and run against any turtle.
+// Commands whose arguments use variables are rebuilt with DeferCommand.
+// Commands registered from Go have no constructor to call and are reported
+// as errors.
+func ToGo(input string) (string, error) {
+	program, err := ParseProgram(input)
+	if err != nil {
+		return "", err
+	}
+
+	commands, err := goBlock(program.Commands)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(goHeader)
+	deferred := false
+	ast.Walk(program, func(c ast.Command) error {
+		if _, ok := c.(*ast.DeferredCommand); ok {
+			deferred = true
+		}
+		return nil
+	})
+	if deferred {
+		b.WriteString("import (\n\t\"github.com/honeylogo/logo/ast\"\n\t\"github.com/honeylogo/logo/parser\"\n)\n")
+	} else {
+		b.WriteString("import \"github.com/honeylogo/logo/ast\"\n")
+	}
+	b.WriteString("\n// Program returns the commands of the Logo program\n")
+	fmt.Fprintf(&b, "func Program() *ast.Program {\n\treturn ast.NewProgram(%s)\n}\n", commands)
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("generated Go does not format: %v", err)
+	}
+	return string(source), nil
+}
+
+// DeferCommand creates a command from the command table whose arguments are
+// evaluated each time it runs, as the parser does for arguments that use
+// variables. It is called by code generated by ToGo, and panics if name is
+// not a command in the table.
+func DeferCommand(name string, args ...ast.Expression) *ast.DeferredCommand {
+	def, exists := findCommandDefinition(name)
+	if !exists {
+		panic(fmt.Sprintf("%s is not a defined command", name))
+	}
+	return ast.NewDeferredCommand(name, args, createDefined(def, name, Position{}))
+}
+
+// goBlock writes a slice of commands, one per line
+func goBlock(commands []ast.Command) (string, error) {
+	var b strings.Builder
+	b.WriteString("[]ast.Command{\n")
+	for _, cmd := range commands {
+		text, err := goCommand(cmd)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s,\n", text)
+	}
+	b.WriteString("}")
+	return b.String(), nil
+}
+
+// goCommand writes the constructor call that creates cmd
+func goCommand(cmd ast.Command) (string, error) {
+	switch c := cmd.(type) {
+	case *ast.ForwardCommand:
+		return goCall("NewForwardCommand", formatNumber(c.Distance)), nil
+	case *ast.BackwardCommand:
+		return goCall("NewBackwardCommand", formatNumber(c.Distance)), nil
+	case *ast.LeftCommand:
+		return goCall("NewLeftCommand", formatNumber(c.Angle)), nil
+	case *ast.RightCommand:
+		return goCall("NewRightCommand", formatNumber(c.Angle)), nil
+	case *ast.PenUpCommand:
+		return goCall("NewPenUpCommand"), nil
+	case *ast.PenDownCommand:
+		return goCall("NewPenDownCommand"), nil
+	case *ast.SetColorCommand:
+		return goCall("NewSetColorCommand", fmt.Sprint(c.R), fmt.Sprint(c.G), fmt.Sprint(c.B)), nil
+	case *ast.SetPaletteColorCommand:
+		return goCall("NewSetPaletteColorCommand", fmt.Sprint(c.Index)), nil
+	case *ast.SetPenGradientCommand:
+		return goCall("NewSetPenGradientCommand", goRGB(c.From), goRGB(c.To), fmt.Sprint(c.Steps)), nil
+	case *ast.RampPenSizeCommand:
+		return goCall("NewRampPenSizeCommand", formatNumber(c.From), formatNumber(c.To), fmt.Sprint(c.Steps)), nil
+	case *ast.SetPenSizeCommand:
+		return goCall("NewSetPenSizeCommand", formatNumber(c.Size)), nil
+	case *ast.SetShapeCommand:
+		return goCall("NewSetShapeCommand", fmt.Sprintf("%q", c.Shape)), nil
+	case *ast.SetPenPatternCommand:
+		return goCall("NewSetPenPatternCommand", fmt.Sprintf("%q", c.Pattern)), nil
+	case *ast.SetHeadingConventionCommand:
+		return goCall("NewSetHeadingConventionCommand", fmt.Sprintf("%q", c.Convention)), nil
+	case *ast.MirrorCommand:
+		return goCall("NewMirrorCommand"), nil
+	case *ast.CompassCommand:
+		return goCall("NewCompassCommand"), nil
+	case *ast.SetXCommand:
+		return goCall("NewSetXCommand", formatNumber(c.X)), nil
+	case *ast.SetYCommand:
+		return goCall("NewSetYCommand", formatNumber(c.Y)), nil
+	case *ast.SetPositionCommand:
+		return goCall("NewSetPositionCommand", formatNumber(c.X), formatNumber(c.Y)), nil
+	case *ast.SlideCommand:
+		return goCall("NewSlideCommand", formatNumber(c.DX), formatNumber(c.DY)), nil
+	case *ast.SpiralCommand:
+		return goCall("NewSpiralCommand", formatNumber(c.Start), formatNumber(c.Increment), formatNumber(c.Angle), fmt.Sprint(c.Steps)), nil
+	case *ast.PolygonCommand:
+		return goCall("NewPolygonCommand", fmt.Sprint(c.Sides), formatNumber(c.Size)), nil
+	case *ast.EllipseCommand:
+		return goCall("NewEllipseCommand", formatNumber(c.RX), formatNumber(c.RY)), nil
+	case *ast.RectCommand:
+		return goCall("NewRectCommand", formatNumber(c.Width), formatNumber(c.Height)), nil
+	case *ast.DiscCommand:
+		return goCall("NewDiscCommand", formatNumber(c.Radius)), nil
+	case *ast.SetHeadingCommand:
+		return goCall("NewSetHeadingCommand", formatNumber(c.Angle)), nil
+	case *ast.HomeCommand:
+		return goCall("NewHomeCommand"), nil
+	case *ast.ResetCommand:
+		return goCall("NewResetCommand"), nil
+	case *ast.ArcRightCommand:
+		return goCall("NewArcRightCommand", formatNumber(c.Radius), formatNumber(c.Angle)), nil
+	case *ast.ArcLeftCommand:
+		return goCall("NewArcLeftCommand", formatNumber(c.Radius), formatNumber(c.Angle)), nil
+	case *ast.WaitCommand:
+		return goCall("NewWaitCommand", formatNumber(c.Ticks)), nil
+	case *ast.WriteVectorCommand:
+		return goCall("NewWriteVectorCommand", fmt.Sprintf("%q", c.Text)), nil
+	case *ast.SavePictCommand:
+		return goCall("NewSavePictCommand", fmt.Sprintf("%q", c.Name)), nil
+	case *ast.LoadPictCommand:
+		return goCall("NewLoadPictCommand", fmt.Sprintf("%q", c.Name)), nil
+	case *ast.NewTurtleCommand:
+		return goCall("NewNewTurtleCommand", fmt.Sprintf("%q", c.Name)), nil
+	case *ast.TellCommand:
+		return goCall("NewTellCommand", fmt.Sprintf("%q", c.Name)), nil
+	case *ast.EraseCommand:
+		return goCall("NewEraseCommand", fmt.Sprintf("%q", c.Name)), nil
+	case *ast.MakeCommand:
+		return goCall("NewMakeCommand", fmt.Sprintf("%q", c.Name), goExpression(c.Value)), nil
+	case *ast.PrintCommand:
+		if c.Position {
+			return goCall("NewPrintPositionCommand"), nil
+		} else if c.Value != nil {
+			return goCall("NewPrintValueCommand", goExpression(c.Value)), nil
+		}
+		return goCall("NewPrintCommand", fmt.Sprintf("%q", c.Text)), nil
+	case *ast.TestCommand:
+		return goCall("NewTestCommand", goExpression(c.Condition)), nil
+	case *ast.ProcedureCallCommand:
+		return goCall("NewProcedureCallCommand", fmt.Sprintf("%q", c.Name), goExpressions(c.Args)), nil
+	case *ast.DeferredCommand:
+		args := []string{fmt.Sprintf("%q", c.Name)}
+		for _, arg := range c.Args {
+			args = append(args, goExpression(arg))
+		}
+		return fmt.Sprintf("parser.DeferCommand(%s)", strings.Join(args, ", ")), nil
+
+	case *ast.RepeatCommand:
+		return goNested(c.Commands, "NewRepeatCommand", fmt.Sprint(c.Times))
+	case *ast.IfCommand:
+		return goNested(c.Commands, "NewIfCommand", goExpression(c.Condition))
+	case *ast.IfTrueCommand:
+		return goNested(c.Commands, "NewIfTrueCommand")
+	case *ast.IfFalseCommand:
+		return goNested(c.Commands, "NewIfFalseCommand")
+	case *ast.WhileCommand:
+		return goNested(c.Commands, "NewWhileCommand", goExpression(c.Condition))
+	case *ast.DoTimesCommand:
+		return goNested(c.Commands, "NewDoTimesCommand", fmt.Sprintf("%q", c.Variable), goExpression(c.Count))
+	case *ast.ForEachCommand:
+		values := make([]string, len(c.Values))
+		for i, value := range c.Values {
+			values[i] = formatNumber(value)
+		}
+		return goNested(c.Commands, "NewForEachCommand", fmt.Sprintf("%q", c.Variable), fmt.Sprintf("[]float32{%s}", strings.Join(values, ", ")))
+	case *ast.AskCommand:
+		return goNested(c.Commands, "NewAskCommand", fmt.Sprintf("%q", c.Name))
+	case *ast.ProcedureDefinition:
+		params := make([]string, len(c.Params))
+		for i, param := range c.Params {
+			params[i] = fmt.Sprintf("%q", param)
+		}
+		return goNested(c.Body, "NewProcedureDefinition", fmt.Sprintf("%q", c.Name), fmt.Sprintf("[]string{%s}", strings.Join(params, ", ")))
+	}
+
+	return "", fmt.Errorf("cannot convert %s to Go", cmd.String())
+}
+
+// goNested writes a call to a constructor whose last argument is a block
+// of commands
+func goNested(commands []ast.Command, constructor string, args ...string) (string, error) {
+	block, err := goBlock(commands)
+	if err != nil {
+		return "", err
+	}
+	return goCall(constructor, append(args, block)...), nil
+}
+
+// goCall writes a call to an ast constructor
+func goCall(constructor string, args ...string) string {
+	return fmt.Sprintf("ast.%s(%s)", constructor, strings.Join(args, ", "))
+}
+
+// goRGB writes a color as the array literal the gradient constructor takes
+func goRGB(rgb [3]uint8) string {
+	return fmt.Sprintf("[3]uint8{%d, %d, %d}", rgb[0], rgb[1], rgb[2])
+}
+
+// goExpression writes the constructor calls that build an expression
+func goExpression(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.NumberExpression:
+		return goCall("NewNumberExpression", formatNumber(e.Value))
+	case *ast.VariableExpression:
+		return goCall("NewVariableExpression", fmt.Sprintf("%q", e.Name))
+	case *ast.TurtleExpression:
+		return goCall("NewTurtleExpression", fmt.Sprintf("%q", e.Name))
+	case *ast.BinaryExpression:
+		return goCall("NewBinaryExpression", fmt.Sprintf("%q", e.Operator), goExpression(e.Left), goExpression(e.Right))
+	case *ast.LogicalExpression:
+		return goCall("NewLogicalExpression", fmt.Sprintf("%q", e.Operator), goExpression(e.Left), goExpression(e.Right))
+	case *ast.FunctionExpression:
+		return goCall("NewFunctionExpression", fmt.Sprintf("%q", e.Name), goExpressions(e.Args))
+	}
+	return expr.String()
+}
+
+// goExpressions writes a slice of expressions
+func goExpressions(exprs []ast.Expression) string {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = goExpression(expr)
+	}
+	return fmt.Sprintf("[]ast.Expression{%s}", strings.Join(parts, ", "))
+}
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToGoRepeat(t *testing.T) {
+	source, err := ToGo("fd 50 repeat 4 [ fd 10 rt 90 ]")
+	assert.NoError(t, err)
+	assert.Equal(t, `// Code generated from a Logo program. DO NOT EDIT.
+
+package main
+
+import "github.com/honeylogo/logo/ast"
+
+// Program returns the commands of the Logo program
+func Program() *ast.Program {
+	return ast.NewProgram([]ast.Command{
+		ast.NewForwardCommand(50),
+		ast.NewRepeatCommand(4, []ast.Command{
+			ast.NewForwardCommand(10),
+			ast.NewRightCommand(90),
+		}),
+	})
+}
+`, source)
+}
+
+func TestToGoDeferred(t *testing.T) {
+	source, err := ToGo(`to square :size
+  repeat 4 [ fd :size rt 90 ]
+end
+square 2.5`)
+	assert.NoError(t, err)
+	assert.Contains(t, source, "\t\"github.com/honeylogo/logo/parser\"\n")
+	assert.Contains(t, source, `ast.NewProcedureDefinition("square", []string{"size"}, []ast.Command{
+			ast.NewRepeatCommand(4, []ast.Command{
+				parser.DeferCommand("forward", ast.NewVariableExpression("size")),
+				ast.NewRightCommand(90),
+			}),
+		}),
+		ast.NewProcedureCallCommand("square", []ast.Expression{ast.NewNumberExpression(2.5)}),`)
+}
+
+func TestDeferCommand(t *testing.T) {
+	cmd := DeferCommand("forward", ast.NewVariableExpression("size"))
+	created, err := cmd.Create([]float32{20})
+	assert.NoError(t, err)
+	assert.Equal(t, ast.NewForwardCommand(20), created)
+
+	assert.Panics(t, func() { DeferCommand("nosuchcommand") })
+}
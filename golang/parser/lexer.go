@@ -2,8 +2,10 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/rs/zerolog/log"
 )
@@ -12,6 +14,17 @@ import (
 type Token struct {
 	Type  TokenType
 	Value string
+	Pos   Position
+}
+
+// Position identifies a location in the program source
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
 }
 
 // TokenType defines the type of tokens
@@ -29,8 +42,10 @@ const (
 	EndToken       TokenType = "END"
 	MakeToken      TokenType = "MAKE"
 	IfToken        TokenType = "IF"
+	WhileToken     TokenType = "WHILE"
 	StringToken    TokenType = "STRING"
 	OperatorToken  TokenType = "OPERATOR"
+	FunctionToken  TokenType = "FUNCTION"
 	CommentToken   TokenType = "COMMENT"
 )
 
@@ -38,116 +53,271 @@ const (
 type Lexer struct {
 	input  string
 	tokens []Token
+	words  *wordScanner // Created by the first call to Next
 }
 
 // NewLexer creates a new lexer
 func NewLexer(input string) *Lexer {
 	return &Lexer{
-		input: strings.TrimSpace(input),
+		input: normalizeInput(input),
 	}
 }
 
-// Tokenize breaks the input into tokens
+// inputReplacer rewrites characters that editors and word processors put
+// into copy-pasted programs to the plain characters Logo expects
+var inputReplacer = strings.NewReplacer(
+	"\r\n", "\n",
+	"\r", "\n",
+	"\u00a0", " ", // non-breaking space
+	"\u202f", " ", // narrow non-breaking space
+	"\u201c", `"`, // curly double quotes
+	"\u201d", `"`,
+	"\u201e", `"`,
+	"\u2018", "'", // curly single quotes
+	"\u2019", "'",
+)
+
+// normalizeInput replaces curly quotes with straight quotes, non-breaking
+// spaces with spaces and CRLF or CR line endings with LF
+func normalizeInput(input string) string {
+	return inputReplacer.Replace(input)
+}
+
+// Tokenize breaks the whole input into tokens, which GetTokens returns
 func (l *Lexer) Tokenize() error {
 	tokens := []Token{}
-	// Use a more flexible tokenization method
-	input := l.input
-	input = strings.ReplaceAll(input, "[", " [ ")
-	input = strings.ReplaceAll(input, "]", " ] ")
-	words := strings.Fields(input)
+	for {
+		token, err := l.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		tokens = append(tokens, token)
+	}
+	l.tokens = tokens
+	return nil
+}
 
-	for i := 0; i < len(words); i++ {
-		word := strings.ToLower(words[i])
+// Next returns the next token of the input, or io.EOF when there are no
+// more. Tokens are read from the input as they are asked for, so a large
+// program can be processed without holding all of its tokens in memory.
+// Next and Tokenize read from the same place in the input, so a lexer
+// should only be used one way.
+func (l *Lexer) Next() (Token, error) {
+	if l.words == nil {
+		l.words = newWordScanner(l.input)
+	}
+
+	for {
+		w, ok := l.words.next()
+		if !ok {
+			return Token{}, io.EOF
+		}
+		word := strings.ToLower(w.text)
 
-		// Handle comments
+		// A comment runs to the end of its line
 		if strings.HasPrefix(word, ";") {
-			// Skip rest of line
-			for i < len(words) {
-				i++
-			}
+			l.words.skipLine()
 			continue
 		}
 
-		switch word {
-		// Movement commands
-		case "forward", "fd":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "forward"})
-		case "backward", "bk":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "backward"})
-		case "left", "lt":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "left"})
-		case "right", "rt":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "right"})
-		case "setx":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "setx"})
-		case "sety":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "sety"})
-		case "setheading", "seth":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "setheading"})
-		case "home":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "home"})
-
-		// Pen commands
-		case "penup", "pu":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "penup"})
-		case "pendown", "pd":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "pendown"})
-		case "setpencolor", "setpc":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "setpencolor"})
-		case "setpensize", "setps":
-			tokens = append(tokens, Token{Type: CommandToken, Value: "setpensize"})
-
-		// Control structures
-		case "repeat":
-			tokens = append(tokens, Token{Type: RepeatToken, Value: "repeat"})
-		case "to":
-			tokens = append(tokens, Token{Type: ToToken, Value: "to"})
-		case "end":
-			tokens = append(tokens, Token{Type: EndToken, Value: "end"})
-		case "if":
-			tokens = append(tokens, Token{Type: IfToken, Value: "if"})
-		case "make":
-			tokens = append(tokens, Token{Type: MakeToken, Value: "make"})
-
-		// Brackets and operators
-		case "[":
-			tokens = append(tokens, Token{Type: OpenBracket, Value: "["})
-		case "]":
-			tokens = append(tokens, Token{Type: CloseBracket, Value: "]"})
-		case "+", "-", "*", "/", "<", ">", "=":
-			tokens = append(tokens, Token{Type: OperatorToken, Value: word})
+		token := classify(word, w.pos)
+		// A quoted word keeps its case, so file names and printed words
+		// come out as they were written
+		if token.Type == StringToken {
+			token.Value = w.text[1:]
+		}
+		if debugEnabled() {
+			log.Debug().Msgf("phase=lex token: %s:%s", token.Type, token.Value)
+		}
+		return token, nil
+	}
+}
 
-		default:
-			// Check if it's a number
-			if num, err := strconv.ParseFloat(word, 64); err == nil {
-				tokens = append(tokens, Token{Type: NumberToken, Value: fmt.Sprintf("%f", num)})
-				continue
-			}
+// classify returns the token for a lower-cased word
+func classify(word string, pos Position) Token {
+	switch word {
+	// Movement commands
+	case "forward", "fd":
+		return Token{Type: CommandToken, Value: "forward", Pos: pos}
+	case "backward", "bk":
+		return Token{Type: CommandToken, Value: "backward", Pos: pos}
+	case "left", "lt":
+		return Token{Type: CommandToken, Value: "left", Pos: pos}
+	case "right", "rt":
+		return Token{Type: CommandToken, Value: "right", Pos: pos}
+	case "setx":
+		return Token{Type: CommandToken, Value: "setx", Pos: pos}
+	case "sety":
+		return Token{Type: CommandToken, Value: "sety", Pos: pos}
+	case "setxy", "setpos":
+		return Token{Type: CommandToken, Value: "setxy", Pos: pos}
+	case "setheading", "seth":
+		return Token{Type: CommandToken, Value: "setheading", Pos: pos}
+	case "setheadings":
+		return Token{Type: CommandToken, Value: "setheadings", Pos: pos}
+	case "home":
+		return Token{Type: CommandToken, Value: "home", Pos: pos}
+	case "reset":
+		return Token{Type: CommandToken, Value: "reset", Pos: pos}
+	case "arcr":
+		return Token{Type: CommandToken, Value: "arcr", Pos: pos}
+	case "arcl":
+		return Token{Type: CommandToken, Value: "arcl", Pos: pos}
+	case "slide":
+		return Token{Type: CommandToken, Value: "slide", Pos: pos}
+	case "spiral":
+		return Token{Type: CommandToken, Value: "spiral", Pos: pos}
+	case "polygon":
+		return Token{Type: CommandToken, Value: "polygon", Pos: pos}
+	case "ellipse":
+		return Token{Type: CommandToken, Value: "ellipse", Pos: pos}
+	case "rect":
+		return Token{Type: CommandToken, Value: "rect", Pos: pos}
+	case "disc":
+		return Token{Type: CommandToken, Value: "disc", Pos: pos}
 
-			// Check if it's a variable (starts with ":")
-			if strings.HasPrefix(word, ":") {
-				tokens = append(tokens, Token{Type: VariableToken, Value: word[1:]})
-				continue
-			}
+	// Pen commands
+	case "penup", "pu":
+		return Token{Type: CommandToken, Value: "penup", Pos: pos}
+	case "pendown", "pd":
+		return Token{Type: CommandToken, Value: "pendown", Pos: pos}
+	case "setpencolor", "setpc":
+		return Token{Type: CommandToken, Value: "setpencolor", Pos: pos}
+	case "setpenhsv":
+		return Token{Type: CommandToken, Value: "setpenhsv", Pos: pos}
+	case "setpengradient", "setpg":
+		return Token{Type: CommandToken, Value: "setpengradient", Pos: pos}
+	case "setpensize", "setps":
+		return Token{Type: CommandToken, Value: "setpensize", Pos: pos}
+	case "rampensize":
+		return Token{Type: CommandToken, Value: "rampensize", Pos: pos}
+	case "setpenpattern":
+		return Token{Type: CommandToken, Value: "setpenpattern", Pos: pos}
 
-			// Check if it's a string (starts with ")
-			if strings.HasPrefix(word, "\"") {
-				tokens = append(tokens, Token{Type: StringToken, Value: word[1:]})
-				continue
-			}
+	case "setshape":
+		return Token{Type: CommandToken, Value: "setshape", Pos: pos}
+
+	// Multiple turtles
+	case "newturtle":
+		return Token{Type: CommandToken, Value: "newturtle", Pos: pos}
+	case "tell":
+		return Token{Type: CommandToken, Value: "tell", Pos: pos}
+	case "ask":
+		return Token{Type: CommandToken, Value: "ask", Pos: pos}
 
-			// Assume it's a procedure name
-			tokens = append(tokens, Token{Type: ProcedureToken, Value: word})
+	// Output
+	case "print", "pr":
+		return Token{Type: CommandToken, Value: "print", Pos: pos}
+	case "mirror":
+		return Token{Type: CommandToken, Value: "mirror", Pos: pos}
+	case "compass":
+		return Token{Type: CommandToken, Value: "compass", Pos: pos}
+	case "writevector":
+		return Token{Type: CommandToken, Value: "writevector", Pos: pos}
+	case "savepict":
+		return Token{Type: CommandToken, Value: "savepict", Pos: pos}
+	case "loadpict":
+		return Token{Type: CommandToken, Value: "loadpict", Pos: pos}
+
+	// Timing
+	case "wait":
+		return Token{Type: CommandToken, Value: "wait", Pos: pos}
+
+	// Control structures
+	case "repeat":
+		return Token{Type: RepeatToken, Value: "repeat", Pos: pos}
+	case "to":
+		return Token{Type: ToToken, Value: "to", Pos: pos}
+	case "end":
+		return Token{Type: EndToken, Value: "end", Pos: pos}
+	case "if":
+		return Token{Type: IfToken, Value: "if", Pos: pos}
+	case "dotimes":
+		return Token{Type: CommandToken, Value: "dotimes", Pos: pos}
+	case "foreach":
+		return Token{Type: CommandToken, Value: "foreach", Pos: pos}
+	case "?":
+		// The current element of a FOREACH list
+		return Token{Type: VariableToken, Value: "?", Pos: pos}
+	case "repcount":
+		// The number of the current REPEAT pass
+		return Token{Type: VariableToken, Value: "repcount", Pos: pos}
+	case "while":
+		return Token{Type: WhileToken, Value: "while", Pos: pos}
+	case "test":
+		return Token{Type: CommandToken, Value: "test", Pos: pos}
+	case "iftrue", "ift":
+		return Token{Type: CommandToken, Value: "iftrue", Pos: pos}
+	case "iffalse", "iff":
+		return Token{Type: CommandToken, Value: "iffalse", Pos: pos}
+	case "make":
+		return Token{Type: MakeToken, Value: "make", Pos: pos}
+	case "erase", "er":
+		return Token{Type: CommandToken, Value: "erase", Pos: pos}
+
+	// Arithmetic and logical functions
+	case "sum", "difference", "product", "quotient", "modulo", "power", "minus", "and", "or", "not":
+		return Token{Type: FunctionToken, Value: word, Pos: pos}
+
+	// Math functions
+	case "abs", "sin", "cos", "tan", "sqrt", "int", "round":
+		return Token{Type: FunctionToken, Value: word, Pos: pos}
+
+	// Turtle state readers
+	case "xcor", "ycor", "heading", "pos":
+		return Token{Type: FunctionToken, Value: word, Pos: pos}
+
+	// Brackets and operators
+	case "[":
+		return Token{Type: OpenBracket, Value: "[", Pos: pos}
+	case "]":
+		return Token{Type: CloseBracket, Value: "]", Pos: pos}
+	case "+", "-", "*", "/", "%", "^", "<", ">", "=":
+		return Token{Type: OperatorToken, Value: word, Pos: pos}
+
+	default:
+		// Check if it's a number, written in its shortest form so 1e3 is
+		// 1000 and 0.10 is 0.1
+		if num, err := strconv.ParseFloat(word, 64); err == nil {
+			return Token{Type: NumberToken, Value: strconv.FormatFloat(num, 'f', -1, 64), Pos: pos}
 		}
-	}
 
-	l.tokens = tokens
+		// Check if it's a variable (starts with ":")
+		if strings.HasPrefix(word, ":") {
+			return Token{Type: VariableToken, Value: word[1:], Pos: pos}
+		}
+
+		// Check if it's a string (starts with ")
+		if strings.HasPrefix(word, "\"") {
+			return Token{Type: StringToken, Value: word[1:], Pos: pos}
+		}
 
-	// Log the parsed tokens
-	for _, token := range tokens {
-		log.Debug().Msgf("phase=lex token: %s:%s", token.Type, token.Value)
+		// Assume it's a procedure name
+		return Token{Type: ProcedureToken, Value: word, Pos: pos}
 	}
+}
 
+// Validate checks that the brackets in the tokenized input are balanced,
+// reporting the position of the first offending bracket
+func (l *Lexer) Validate() error {
+	open := []Token{}
+	for _, token := range l.tokens {
+		switch token.Type {
+		case OpenBracket:
+			open = append(open, token)
+		case CloseBracket:
+			if len(open) == 0 {
+				return syntaxError(token.Pos, "unbalanced bracket: unexpected ]")
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return syntaxError(open[len(open)-1].Pos, "unbalanced bracket: unclosed [")
+	}
 	return nil
 }
 
@@ -155,3 +325,106 @@ func (l *Lexer) Tokenize() error {
 func (l *Lexer) GetTokens() []Token {
 	return l.tokens
 }
+
+// word is a piece of the input together with its position
+type word struct {
+	text string
+	pos  Position
+}
+
+// wordScanner splits the input into words on whitespace, treating each
+// bracket as a word of its own. A word starting with " that has a matching
+// closing quote on the same line is a single word even if it contains
+// spaces, so "hello world" is one word; the closing quote is dropped.
+type wordScanner struct {
+	runes  []rune
+	index  int // Index of the next rune to read
+	line   int
+	column int // Column of the last rune read
+}
+
+// newWordScanner creates a scanner positioned at the start of input
+func newWordScanner(input string) *wordScanner {
+	return &wordScanner{runes: []rune(input), line: 1}
+}
+
+// next returns the next word, or false at the end of the input
+func (s *wordScanner) next() (word, bool) {
+	var current strings.Builder
+	var currentPos Position
+	for ; s.index < len(s.runes); s.index++ {
+		r := s.runes[s.index]
+		s.column++
+		switch {
+		case unicode.IsSpace(r):
+			if r == '\n' {
+				s.line++
+				s.column = 0
+			}
+			if current.Len() > 0 {
+				s.index++
+				return word{text: current.String(), pos: currentPos}, true
+			}
+		case r == '[' || r == ']':
+			if current.Len() > 0 {
+				// Leave the bracket to be read as the next word
+				s.column--
+				return word{text: current.String(), pos: currentPos}, true
+			}
+			s.index++
+			return word{text: string(r), pos: Position{Line: s.line, Column: s.column}}, true
+		case r == '"' && current.Len() == 0:
+			currentPos = Position{Line: s.line, Column: s.column}
+			if end := closingQuote(s.runes, s.index); end > s.index {
+				text := string(s.runes[s.index:end])
+				s.column += end - s.index
+				s.index = end + 1
+				return word{text: text, pos: currentPos}, true
+			}
+			current.WriteRune(r)
+		default:
+			if current.Len() == 0 {
+				currentPos = Position{Line: s.line, Column: s.column}
+			}
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		return word{text: current.String(), pos: currentPos}, true
+	}
+	return word{}, false
+}
+
+// skipLine moves the scanner to the end of the current line, leaving the
+// newline to be read next
+func (s *wordScanner) skipLine() {
+	if s.index > 0 && s.runes[s.index-1] == '\n' {
+		// The last word read ended the line
+		return
+	}
+	for s.index < len(s.runes) && s.runes[s.index] != '\n' {
+		s.index++
+		s.column++
+	}
+}
+
+// closingQuote returns the index of the quote that closes the quoted word
+// starting at start, or -1 if there is none. The closing quote must end a
+// word, and the search gives up at the end of the line or at another word
+// starting with a quote, so "red and "blue stay separate words.
+func closingQuote(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\n':
+			return -1
+		case runes[i] == '"':
+			if unicode.IsSpace(runes[i-1]) {
+				return -1
+			}
+			if i+1 == len(runes) || unicode.IsSpace(runes[i+1]) || runes[i+1] == '[' || runes[i+1] == ']' {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{`label "hello world"`, []Token{
+			{Type: ProcedureToken, Value: "label", Pos: Position{Line: 1, Column: 1}},
+			{Type: StringToken, Value: "hello world", Pos: Position{Line: 1, Column: 7}},
+		}},
+		{`print "hi" fd 10`, []Token{
+			{Type: CommandToken, Value: "print", Pos: Position{Line: 1, Column: 1}},
+			{Type: StringToken, Value: "hi", Pos: Position{Line: 1, Column: 7}},
+			{Type: CommandToken, Value: "forward", Pos: Position{Line: 1, Column: 12}},
+			{Type: NumberToken, Value: "10", Pos: Position{Line: 1, Column: 15}},
+		}},
+		// Without a closing quote a string ends at the next space
+		{`setshape "arrow tell "bob`, []Token{
+			{Type: CommandToken, Value: "setshape", Pos: Position{Line: 1, Column: 1}},
+			{Type: StringToken, Value: "arrow", Pos: Position{Line: 1, Column: 10}},
+			{Type: CommandToken, Value: "tell", Pos: Position{Line: 1, Column: 17}},
+			{Type: StringToken, Value: "bob", Pos: Position{Line: 1, Column: 22}},
+		}},
+		// Quoted words keep their case
+		{`PRINT "Hello SavePict "MyPic.png`, []Token{
+			{Type: CommandToken, Value: "print", Pos: Position{Line: 1, Column: 1}},
+			{Type: StringToken, Value: "Hello", Pos: Position{Line: 1, Column: 7}},
+			{Type: CommandToken, Value: "savepict", Pos: Position{Line: 1, Column: 14}},
+			{Type: StringToken, Value: "MyPic.png", Pos: Position{Line: 1, Column: 23}},
+		}},
+		// Quoted strings don't run onto the next line
+		{"print \"a b\nc\"", []Token{
+			{Type: CommandToken, Value: "print", Pos: Position{Line: 1, Column: 1}},
+			{Type: StringToken, Value: "a", Pos: Position{Line: 1, Column: 7}},
+			{Type: ProcedureToken, Value: "b", Pos: Position{Line: 1, Column: 10}},
+			{Type: ProcedureToken, Value: "c\"", Pos: Position{Line: 2, Column: 1}},
+		}},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.input)
+		assert.NoError(t, lexer.Tokenize(), tt.input)
+		assert.Equal(t, tt.expected, lexer.GetTokens(), tt.input)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		value string
+		fd    float32
+	}{
+		{"1e3", "1000", 1000},
+		{"0.1", "0.1", 0.1},
+		{"100", "100", 100},
+		{"2.50", "2.5", 2.5},
+		{"-1.5E-1", "-0.15", -0.15},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.input)
+		assert.NoError(t, lexer.Tokenize(), tt.input)
+		assert.Equal(t, []Token{{Type: NumberToken, Value: tt.value, Pos: Position{Line: 1, Column: 1}}},
+			lexer.GetTokens(), tt.input)
+
+		program, err := ParseProgram("fd " + tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, []ast.Command{ast.NewForwardCommand(tt.fd)}, program.Commands, tt.input)
+	}
+}
+
+func TestNext(t *testing.T) {
+	input := "repeat 4 [fd 10\nrt 90] print \"done now\" ; the end"
+
+	batch := NewLexer(input)
+	assert.NoError(t, batch.Tokenize())
+
+	streamed := []Token{}
+	lexer := NewLexer(input)
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		streamed = append(streamed, token)
+	}
+	assert.Equal(t, batch.GetTokens(), streamed)
+	assert.Len(t, streamed, 10)
+	assert.Equal(t, Position{Line: 2, Column: 6}, streamed[7].Pos)
+
+	// The end of the input stays the end
+	_, err := lexer.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestComments(t *testing.T) {
+	program, err := ParseProgram("fd 10 ; comment\nrt 90 ;another [ ]\n;\n; fd 20\nbk 5 ; the end")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{
+		ast.NewForwardCommand(10),
+		ast.NewRightCommand(90),
+		ast.NewBackwardCommand(5),
+	}, program.Commands)
+
+	// Lines after a comment keep their numbers
+	lexer := NewLexer("; header\nfd 10 ;x\n  rt 90")
+	assert.NoError(t, lexer.Tokenize())
+	assert.Equal(t, []Token{
+		{Type: CommandToken, Value: "forward", Pos: Position{Line: 2, Column: 1}},
+		{Type: NumberToken, Value: "10", Pos: Position{Line: 2, Column: 4}},
+		{Type: CommandToken, Value: "right", Pos: Position{Line: 3, Column: 3}},
+		{Type: NumberToken, Value: "90", Pos: Position{Line: 3, Column: 6}},
+	}, lexer.GetTokens())
+}
+
+func TestNormalizedInput(t *testing.T) {
+	pasted := "to square\r\n  repeat 4 [fd 50 rt\u00a090]\r\nend\r\nsetpc \u201cred\u201d\rprint \u201chello world\u201d\rsquare"
+	normalized := "to square\n  repeat 4 [fd 50 rt 90]\nend\nsetpc \"red\"\nprint \"hello world\"\nsquare"
+
+	expected, err := ParseProgram(normalized)
+	assert.NoError(t, err)
+	program, err := ParseProgram(pasted)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, program)
+
+	lexer := NewLexer(pasted)
+	assert.NoError(t, lexer.Tokenize())
+	tokens := lexer.GetTokens()
+	assert.Equal(t, Token{Type: StringToken, Value: "red", Pos: Position{Line: 4, Column: 7}}, tokens[12])
+	assert.Equal(t, Token{Type: StringToken, Value: "hello world", Pos: Position{Line: 5, Column: 7}}, tokens[14])
+	assert.Equal(t, Position{Line: 6, Column: 1}, tokens[15].Pos)
+}
+
+// largeProgram returns a program of about 100,000 tokens
+func largeProgram() string {
+	return strings.Repeat("repeat 4 [ fd 10 rt 90 ] setpencolor 255 0 0\n", 100000/13)
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	input := largeProgram()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewLexer(input).Tokenize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNext(b *testing.B) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	input := largeProgram()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lexer := NewLexer(input)
+		for {
+			if _, err := lexer.Next(); err == io.EOF {
+				break
+			}
+		}
+	}
+}
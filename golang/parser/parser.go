@@ -2,74 +2,199 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/turtle"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// debugEnabled reports whether debug messages are logged. Hot loops check it
+// first so they don't build messages that would only be thrown away.
+func debugEnabled() bool {
+	return zerolog.GlobalLevel() <= zerolog.DebugLevel && log.Logger.GetLevel() <= zerolog.DebugLevel
+}
+
 // CommandDefinition describes how to parse and create a command
 type CommandDefinition struct {
-	Aliases       []string
-	RequiresValue bool
-	CreateCommand func(float32) ast.Command
+	Aliases  []string
+	ArgCount int
+	// ValidateArgs, if set, rejects argument values the command can't use
+	ValidateArgs func(args []float32) error
+	// ListArgs allows the arguments to be given as a bracketed list too, as
+	// in setpos [100 100]
+	ListArgs      bool
+	CreateCommand func(args []float32) ast.Command
 }
 
 // Command definitions mapping
 var commandDefinitions = map[string]CommandDefinition{
 	"forward": {
 		Aliases:       []string{"fd"},
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewForwardCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewForwardCommand(args[0]) },
 	},
 	"backward": {
 		Aliases:       []string{"bk"},
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewBackwardCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewBackwardCommand(args[0]) },
 	},
 	"left": {
 		Aliases:       []string{"lt"},
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewLeftCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewLeftCommand(args[0]) },
 	},
 	"right": {
 		Aliases:       []string{"rt"},
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewRightCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewRightCommand(args[0]) },
 	},
 	"setx": {
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewSetXCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewSetXCommand(args[0]) },
 	},
 	"sety": {
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewSetYCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewSetYCommand(args[0]) },
+	},
+	"setxy": {
+		Aliases:       []string{"setpos"},
+		ArgCount:      2,
+		ListArgs:      true,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewSetPositionCommand(args[0], args[1]) },
 	},
 	"setheading": {
 		Aliases:       []string{"seth"},
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewSetHeadingCommand(val) },
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewSetHeadingCommand(args[0]) },
 	},
 	"setpensize": {
-		Aliases:       []string{"setps"},
-		RequiresValue: true,
-		CreateCommand: func(val float32) ast.Command { return ast.NewSetPenSizeCommand(val) },
+		Aliases:  []string{"setps"},
+		ArgCount: 1,
+		ValidateArgs: func(args []float32) error {
+			if args[0] <= 0 {
+				return fmt.Errorf("pen size must be greater than 0, got %s", formatNumber(args[0]))
+			}
+			return nil
+		},
+		CreateCommand: func(args []float32) ast.Command { return ast.NewSetPenSizeCommand(args[0]) },
+	},
+	"setpengradient": {
+		Aliases:      []string{"setpg"},
+		ArgCount:     7,
+		ValidateArgs: validatePenGradient,
+		CreateCommand: func(args []float32) ast.Command {
+			return ast.NewSetPenGradientCommand(
+				[3]uint8{uint8(args[0]), uint8(args[1]), uint8(args[2])},
+				[3]uint8{uint8(args[3]), uint8(args[4]), uint8(args[5])},
+				int(args[6]))
+		},
+	},
+	"rampensize": {
+		ArgCount: 3,
+		ValidateArgs: func(args []float32) error {
+			if args[0] <= 0 || args[1] <= 0 {
+				return fmt.Errorf("pen sizes must be greater than 0")
+			}
+			steps := args[2]
+			if steps != float32(math.Trunc(float64(steps))) || steps < 2 {
+				return fmt.Errorf("steps must be a whole number of at least 2, got %s", formatNumber(steps))
+			}
+			return nil
+		},
+		CreateCommand: func(args []float32) ast.Command {
+			return ast.NewRampPenSizeCommand(args[0], args[1], int(args[2]))
+		},
 	},
 	"penup": {
 		Aliases:       []string{"pu"},
-		CreateCommand: func(_ float32) ast.Command { return ast.NewPenUpCommand() },
+		CreateCommand: func(_ []float32) ast.Command { return ast.NewPenUpCommand() },
 	},
 	"pendown": {
 		Aliases:       []string{"pd"},
-		CreateCommand: func(_ float32) ast.Command { return ast.NewPenDownCommand() },
+		CreateCommand: func(_ []float32) ast.Command { return ast.NewPenDownCommand() },
 	},
 	"home": {
-		CreateCommand: func(_ float32) ast.Command { return ast.NewHomeCommand() },
+		CreateCommand: func(_ []float32) ast.Command { return ast.NewHomeCommand() },
+	},
+	"reset": {
+		CreateCommand: func(_ []float32) ast.Command { return ast.NewResetCommand() },
+	},
+	"mirror": {
+		CreateCommand: func(_ []float32) ast.Command { return ast.NewMirrorCommand() },
+	},
+	"compass": {
+		CreateCommand: func(_ []float32) ast.Command { return ast.NewCompassCommand() },
+	},
+	"wait": {
+		ArgCount:      1,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewWaitCommand(args[0]) },
+	},
+	"arcr": {
+		ArgCount:      2,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewArcRightCommand(args[0], args[1]) },
+	},
+	"arcl": {
+		ArgCount:      2,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewArcLeftCommand(args[0], args[1]) },
+	},
+	"spiral": {
+		ArgCount: 4,
+		ValidateArgs: func(args []float32) error {
+			steps := args[3]
+			if steps != float32(math.Trunc(float64(steps))) || steps < 0 {
+				return fmt.Errorf("steps must be a whole number of at least 0, got %s", formatNumber(steps))
+			}
+			return nil
+		},
+		CreateCommand: func(args []float32) ast.Command {
+			return ast.NewSpiralCommand(args[0], args[1], args[2], int(args[3]))
+		},
+	},
+	"polygon": {
+		ArgCount: 2,
+		ValidateArgs: func(args []float32) error {
+			sides := args[0]
+			if sides != float32(math.Trunc(float64(sides))) || sides < 3 {
+				return fmt.Errorf("sides must be a whole number of at least 3, got %s", formatNumber(sides))
+			}
+			return nil
+		},
+		CreateCommand: func(args []float32) ast.Command { return ast.NewPolygonCommand(int(args[0]), args[1]) },
+	},
+	"ellipse": {
+		ArgCount:      2,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewEllipseCommand(args[0], args[1]) },
+	},
+	"rect": {
+		ArgCount:      2,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewRectCommand(args[0], args[1]) },
+	},
+	"disc": {
+		ArgCount: 1,
+		ValidateArgs: func(args []float32) error {
+			if args[0] < 0 {
+				return fmt.Errorf("radius must be at least 0, got %s", formatNumber(args[0]))
+			}
+			return nil
+		},
+		CreateCommand: func(args []float32) ast.Command { return ast.NewDiscCommand(args[0]) },
+	},
+	"slide": {
+		ArgCount:      2,
+		CreateCommand: func(args []float32) ast.Command { return ast.NewSlideCommand(args[0], args[1]) },
 	},
 }
 
 // findCommandDefinition finds a command definition by its name or alias
 func findCommandDefinition(name string) (CommandDefinition, bool) {
+	commandsMutex.RLock()
+	defer commandsMutex.RUnlock()
+
 	// Check direct match
 	if def, exists := commandDefinitions[name]; exists {
 		return def, true
@@ -89,25 +214,148 @@ func findCommandDefinition(name string) (CommandDefinition, bool) {
 
 // ParseProgram converts a string of Logo commands into an AST
 func ParseProgram(input string) (*ast.Program, error) {
+	return ParseProgramWithProcedures(input, nil)
+}
+
+// ParseProgramWithProcedures parses a program that may call procedures
+// defined outside it, such as by earlier programs run in the same
+// interpreter. procedures maps each procedure's name to its number of
+// inputs; procedures defined by the program itself are found automatically.
+func ParseProgramWithProcedures(input string, procedures map[string]int) (*ast.Program, error) {
 	// Tokenize the input
 	lexer := NewLexer(input)
 	if err := lexer.Tokenize(); err != nil {
 		return nil, err
 	}
+	if err := lexer.Validate(); err != nil {
+		return nil, err
+	}
 	tokens := lexer.GetTokens()
 
 	// Convert tokens to AST
-	return buildProgram(tokens)
+	return buildProgram(tokens, procedures)
+}
+
+// Validate checks that a program lexes and parses, resolving command names
+// and argument counts without executing anything. It returns the first
+// error found, or nil if the program is well-formed.
+func Validate(input string) error {
+	_, err := ParseProgram(input)
+	return err
+}
+
+// PositionedError is a parse error along with where it occurred
+type PositionedError struct {
+	Pos Position
+	Err error
+}
+
+func (e *PositionedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *PositionedError) Unwrap() error {
+	return e.Err
+}
+
+// ParseProgramCollectErrors parses a program without stopping at the first
+// error. After an error parsing resumes at the next command after the one
+// that failed, so the returned program holds every command that could be
+// parsed along with the errors for those that could not.
+func ParseProgramCollectErrors(input string) (*ast.Program, []*PositionedError) {
+	program := ast.NewProgram([]ast.Command{})
+	errs := []*PositionedError{}
+
+	lexer := NewLexer(input)
+	if err := lexer.Tokenize(); err != nil {
+		return program, append(errs, &PositionedError{Err: err})
+	}
+	tokens := lexer.GetTokens()
+	procedures := procedureArities(tokens, nil)
+
+	for i := 0; i < len(tokens); {
+		cmd, next, err := parseCommand(tokens, i, procedures)
+		if err != nil {
+			log.Debug().Msgf("phase=parse parsing error: %v", err)
+			errs = append(errs, &PositionedError{Pos: tokens[i].Pos, Err: err})
+			i = synchronize(tokens, i, procedures)
+			continue
+		}
+		if cmd != nil {
+			program.Commands = append(program.Commands, cmd)
+		}
+		i = next
+	}
+
+	return program, errs
+}
+
+// synchronize returns the index of the first token after the failed
+// command at start that can begin another command. Tokens inside brackets
+// opened after start are part of the failed command, as is everything up to
+// the END of a failed TO, so errors inside a block aren't reported again.
+// DOTIMES, FOREACH, TEST, IFTRUE, IFFALSE and ASK are command tokens, and
+// a procedure token begins a command only when it names a known procedure.
+func synchronize(tokens []Token, start int, procedures map[string]int) int {
+	if tokens[start].Type == ToToken {
+		for i := start + 1; i < len(tokens); i++ {
+			if tokens[i].Type == EndToken {
+				return i + 1
+			}
+		}
+		return len(tokens)
+	}
+
+	depth := 0
+	for i := start + 1; i < len(tokens); i++ {
+		switch tokens[i].Type {
+		case OpenBracket:
+			depth++
+		case CloseBracket:
+			depth = max(depth-1, 0)
+		case CommandToken, RepeatToken, ToToken, MakeToken, IfToken, WhileToken:
+			if depth == 0 {
+				return i
+			}
+		case ProcedureToken:
+			if _, known := procedures[tokens[i].Value]; known && depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// procedureArities returns the known procedures together with those defined
+// by TO in the tokens, so procedures can be called before their definition
+func procedureArities(tokens []Token, known map[string]int) map[string]int {
+	procedures := map[string]int{}
+	for name, arity := range known {
+		procedures[name] = arity
+	}
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].Type != ToToken || tokens[i+1].Type != ProcedureToken {
+			continue
+		}
+		arity := 0
+		for j := i + 2; j < len(tokens) && tokens[j].Type == VariableToken; j++ {
+			arity++
+		}
+		procedures[tokens[i+1].Value] = arity
+	}
+	return procedures
 }
 
 // buildProgram builds the entire program's AST
-func buildProgram(tokens []Token) (*ast.Program, error) {
+func buildProgram(tokens []Token, procedures map[string]int) (*ast.Program, error) {
 	program := &ast.Program{
 		Commands: []ast.Command{},
 	}
+	procedures = procedureArities(tokens, procedures)
 
-	for i := 0; i < len(tokens); i++ {
-		cmd, consumed, err := parseCommand(tokens, i)
+	for i := 0; i < len(tokens); {
+		cmd, next, err := parseCommand(tokens, i, procedures)
 		if err != nil {
 			log.Debug().Msgf("phase=parse parsing error: %v", err)
 			return nil, err
@@ -115,87 +363,689 @@ func buildProgram(tokens []Token) (*ast.Program, error) {
 		if cmd != nil {
 			program.Commands = append(program.Commands, cmd)
 		}
-		// Skip consumed tokens
-		i += consumed
+		i = next
 	}
 
-	log.Debug().Msgf("phase=parse parsed %d commands", len(program.Commands))
+	if debugEnabled() {
+		log.Debug().Msgf("phase=parse parsed %d commands", len(program.Commands))
 
-	// Log each parsed command
-	for _, cmd := range program.Commands {
-		log.Debug().Msgf("phase=parse command: %s", cmd.String())
+		// Log each parsed command
+		for _, cmd := range program.Commands {
+			log.Debug().Msgf("phase=parse command: %s", cmd.String())
+		}
 	}
 
 	return program, nil
 }
 
-// parseCommand converts a token (or sequence of tokens) into a Command
-func parseCommand(tokens []Token, start int) (ast.Command, int, error) {
+// parseCommand converts a token (or sequence of tokens) into a Command,
+// returning the index of the token following the command
+func parseCommand(tokens []Token, start int, procedures map[string]int) (ast.Command, int, error) {
 	if start >= len(tokens) {
-		return nil, 0, nil
+		return nil, start, nil
 	}
 
 	switch tokens[start].Type {
 	case CommandToken:
+		switch tokens[start].Value {
+		case "setpencolor":
+			return parseSetPenColor(tokens, start)
+		case "setpenhsv":
+			return parseSetPenHSV(tokens, start)
+		case "setshape":
+			shape, ok := nameArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "setshape command requires a shape name")
+			}
+			if !slices.Contains(turtle.Shapes, shape) {
+				return nil, 0, argumentError(tokens, start, "unknown shape %q, valid shapes are: %s",
+					shape, strings.Join(turtle.Shapes, ", "))
+			}
+			return ast.NewSetShapeCommand(shape), start + 2, nil
+		case "setpenpattern":
+			pattern, ok := nameArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "setpenpattern command requires a pattern name")
+			}
+			if !slices.Contains(turtle.PenPatterns, pattern) {
+				return nil, 0, argumentError(tokens, start, "unknown pen pattern %q, valid patterns are: %s",
+					pattern, strings.Join(turtle.PenPatterns, ", "))
+			}
+			return ast.NewSetPenPatternCommand(pattern), start + 2, nil
+		case "setheadings":
+			convention, ok := nameArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "setheadings command requires a heading convention")
+			}
+			if !slices.Contains(turtle.HeadingConventions, convention) {
+				return nil, 0, argumentError(tokens, start, "unknown heading convention %q, valid conventions are: %s",
+					convention, strings.Join(turtle.HeadingConventions, ", "))
+			}
+			return ast.NewSetHeadingConventionCommand(convention), start + 2, nil
+		case "newturtle", "tell":
+			name, ok := nameArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "%s command requires a turtle name", tokens[start].Value)
+			}
+			if tokens[start].Value == "newturtle" {
+				return ast.NewNewTurtleCommand(name), start + 2, nil
+			}
+			return ast.NewTellCommand(name), start + 2, nil
+		case "writevector":
+			text, ok := stringArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "writevector command requires a word to write")
+			}
+			if err := ast.CheckVectorText(text); err != nil {
+				return nil, 0, argumentError(tokens, start, "%v", err)
+			}
+			return ast.NewWriteVectorCommand(text), start + 2, nil
+		case "savepict", "loadpict":
+			name, ok := stringArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "%s command requires a file name", tokens[start].Value)
+			}
+			if tokens[start].Value == "savepict" {
+				return ast.NewSavePictCommand(name), start + 2, nil
+			}
+			return ast.NewLoadPictCommand(name), start + 2, nil
+		case "erase":
+			name, ok := nameArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "erase command requires a procedure name")
+			}
+			if IsBuiltin(name) {
+				return nil, 0, argumentError(tokens, start, "cannot erase %s: it is a builtin command", name)
+			}
+			return ast.NewEraseCommand(name), start + 2, nil
+		case "print":
+			if text, ok := stringArgument(tokens, start); ok {
+				return ast.NewPrintCommand(text), start + 2, nil
+			}
+			if start+1 < len(tokens) && tokens[start+1].Type == FunctionToken && tokens[start+1].Value == "pos" {
+				return ast.NewPrintPositionCommand(), start + 2, nil
+			}
+			if start+1 >= len(tokens) || !isExpressionStart(tokens[start+1]) {
+				return nil, 0, argumentError(tokens, start, "print command requires a word or a number")
+			}
+			value, next, err := parseExpression(tokens, start+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			return ast.NewPrintValueCommand(value), next, nil
+		case "test":
+			if err := expressionArgument(tokens, start, start+1, "test command requires a condition"); err != nil {
+				return nil, 0, err
+			}
+			condition, next, err := parseExpression(tokens, start+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			return ast.NewTestCommand(condition), next, nil
+		case "iftrue", "iffalse":
+			if start+1 >= len(tokens) || tokens[start+1].Type != OpenBracket {
+				return nil, 0, argumentError(tokens, start, "%s command requires a block", tokens[start].Value)
+			}
+			commands, next, err := parseBlock(tokens, start+1, procedures)
+			if err != nil {
+				return nil, 0, err
+			}
+			if tokens[start].Value == "iftrue" {
+				return ast.NewIfTrueCommand(commands), next, nil
+			}
+			return ast.NewIfFalseCommand(commands), next, nil
+		case "dotimes":
+			return parseDoTimes(tokens, start, procedures)
+		case "foreach":
+			return parseForEach(tokens, start, procedures)
+		case "ask":
+			name, ok := nameArgument(tokens, start)
+			if !ok {
+				return nil, 0, argumentError(tokens, start, "ask command requires a turtle name")
+			}
+			if start+2 >= len(tokens) || tokens[start+2].Type != OpenBracket {
+				return nil, 0, argumentError(tokens, start, "ask command requires a block")
+			}
+			commands, next, err := parseBlock(tokens, start+2, procedures)
+			if err != nil {
+				return nil, 0, err
+			}
+			return ast.NewAskCommand(name, commands), next, nil
+		}
+
 		// Find the command definition
 		def, exists := findCommandDefinition(tokens[start].Value)
 		if !exists {
-			return nil, 0, fmt.Errorf("unknown command: %s", tokens[start].Value)
+			return nil, 0, &UnknownCommandError{Name: tokens[start].Value, Pos: tokens[start].Pos}
 		}
+		return parseDefinedCommand(def, tokens, start)
 
-		// Handle commands that require a value
-		if def.RequiresValue {
-			if start+1 >= len(tokens) || tokens[start+1].Type != NumberToken {
-				return nil, 0, fmt.Errorf("%s command requires a number argument", tokens[start].Value)
-			}
-			value, _ := strconv.ParseFloat(tokens[start+1].Value, 64)
-			return def.CreateCommand(float32(value)), 1, nil
+	case MakeToken:
+		name, ok := nameArgument(tokens, start)
+		if !ok {
+			return nil, 0, argumentError(tokens, start, "make command requires a variable name")
+		}
+		if err := expressionArgument(tokens, start, start+2, "make command requires a number value"); err != nil {
+			return nil, 0, err
+		}
+		value, next, err := parseExpression(tokens, start+2)
+		if err != nil {
+			return nil, 0, err
 		}
+		return ast.NewMakeCommand(name, value), next, nil
 
-		// Handle commands without a value
-		return def.CreateCommand(0), 0, nil
+	case IfToken, WhileToken:
+		if err := expressionArgument(tokens, start, start+1, "%s command requires a condition", tokens[start].Value); err != nil {
+			return nil, 0, err
+		}
+		condition, next, err := parseExpression(tokens, start+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if next >= len(tokens) || tokens[next].Type != OpenBracket {
+			return nil, 0, argumentError(tokens, start, "%s command requires a block", tokens[start].Value)
+		}
+		commands, after, err := parseBlock(tokens, next, procedures)
+		if err != nil {
+			return nil, 0, err
+		}
+		if tokens[start].Type == IfToken {
+			return ast.NewIfCommand(condition, commands), after, nil
+		}
+		return ast.NewWhileCommand(condition, commands), after, nil
 
 	case RepeatToken:
 		// Expect a number argument and a block
 		if start+1 >= len(tokens) || tokens[start+1].Type != NumberToken {
-			return nil, 0, fmt.Errorf("repeat command requires a number argument")
+			return nil, 0, argumentError(tokens, start, "repeat command requires a number argument")
 		}
-		times, err := strconv.Atoi(tokens[start+1].Value)
+		count, err := strconv.ParseFloat(tokens[start+1].Value, 64)
 		if err != nil {
-			// If Atoi fails, try ParseFloat and convert
-			timesFloat, err := strconv.ParseFloat(tokens[start+1].Value, 64)
-			if err != nil {
-				return nil, 0, fmt.Errorf("invalid repeat count: %s", tokens[start+1].Value)
-			}
-			times = int(timesFloat)
+			return nil, 0, argumentError(tokens, start, "invalid repeat count: %s", tokens[start+1].Value)
+		}
+		// A count of zero runs the block no times, but a negative or
+		// fractional count is most likely a mistake
+		if count < 0 || count != math.Trunc(count) {
+			return nil, 0, argumentError(tokens, start, "repeat count must be a whole number of at least 0, got %s",
+				formatNumber(float32(count)))
+		}
+		times := int(count)
+		if debugEnabled() {
+			log.Debug().Msgf("phase=parse repeat times: %s (parsed as %d)", tokens[start+1].Value, times)
 		}
-		log.Debug().Msgf("phase=parse repeat times: %s (parsed as %d)", tokens[start+1].Value, times)
 
 		// Find the block
 		if start+2 >= len(tokens) || tokens[start+2].Type != OpenBracket {
-			return nil, 0, fmt.Errorf("repeat command requires a block")
+			return nil, 0, argumentError(tokens, start, "repeat command requires a block")
 		}
 
-		// Parse the block
-		blockCommands := []ast.Command{}
-		i := start + 3
-		for i < len(tokens) && tokens[i].Type != CloseBracket {
-			cmd, consumed, err := parseCommand(tokens, i)
+		blockCommands, next, err := parseBlock(tokens, start+2, procedures)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return ast.NewRepeatCommand(times, blockCommands), next, nil
+
+	case ToToken:
+		return parseProcedureDefinition(tokens, start, procedures)
+
+	case EndToken:
+		return nil, 0, syntaxError(tokens[start].Pos, "end without matching to")
+
+	case CloseBracket:
+		return nil, 0, syntaxError(tokens[start].Pos, "unmatched bracket")
+
+	case ProcedureToken:
+		// Commands registered from Go lex as procedure names
+		if def, exists := findCommandDefinition(tokens[start].Value); exists {
+			return parseDefinedCommand(def, tokens, start)
+		}
+		if arity, exists := procedures[tokens[start].Value]; exists {
+			return parseProcedureCall(tokens, start, arity)
+		}
+		return nil, 0, &UnknownCommandError{Name: tokens[start].Value, Pos: tokens[start].Pos}
+	}
+
+	return nil, 0, syntaxError(tokens[start].Pos, "unexpected %s %q", tokens[start].Type, tokens[start].Value)
+}
+
+// parseDefinedCommand parses the arguments of a command from the command
+// table and creates it, returning the index of the token following it.
+// Constant arguments are evaluated now; if any argument refers to a
+// variable the command is created when it runs instead.
+func parseDefinedCommand(def CommandDefinition, tokens []Token, start int) (ast.Command, int, error) {
+	name := tokens[start].Value
+	exprs := make([]ast.Expression, 0, def.ArgCount)
+	constant := true
+	next := start + 1
+	if def.ListArgs && next < len(tokens) && tokens[next].Type == OpenBracket {
+		var err error
+		exprs, next, err = parseArgumentList(tokens, start, def.ArgCount)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, expr := range exprs {
+			constant = constant && ast.IsConstant(expr)
+		}
+	}
+	for len(exprs) < def.ArgCount {
+		missing := fmt.Sprintf("%s command requires %d number arguments", name, def.ArgCount)
+		if def.ArgCount == 1 {
+			missing = fmt.Sprintf("%s command requires a number argument", name)
+		}
+		if err := expressionArgument(tokens, start, next, "%s", missing); err != nil {
+			return nil, 0, err
+		}
+		expr, after, err := parseExpression(tokens, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		exprs = append(exprs, expr)
+		constant = constant && ast.IsConstant(expr)
+		next = after
+	}
+
+	create := createDefined(def, name, tokens[start].Pos)
+	if !constant {
+		return ast.NewDeferredCommand(name, exprs, create), next, nil
+	}
+
+	args := make([]float32, len(exprs))
+	for i, expr := range exprs {
+		value, err := expr.Evaluate(nil)
+		if err != nil {
+			return nil, 0, argumentError(tokens, start, "%s command: %v", name, err)
+		}
+		args[i] = value
+	}
+	cmd, err := create(args)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cmd, next, nil
+}
+
+// createDefined returns a function that validates evaluated arguments and
+// creates the command from them, reporting invalid arguments at pos
+func createDefined(def CommandDefinition, name string, pos Position) func(args []float32) (ast.Command, error) {
+	return func(args []float32) (ast.Command, error) {
+		if def.ValidateArgs != nil {
+			if err := def.ValidateArgs(args); err != nil {
+				return nil, &ArgumentError{Command: name, Pos: pos, Reason: fmt.Sprintf("%s command: %v", name, err)}
+			}
+		}
+		return def.CreateCommand(args), nil
+	}
+}
+
+// parseArgumentList parses a bracketed list of exactly count expressions
+// given as the arguments of the command at start, returning them and the
+// index of the token following the closing bracket
+func parseArgumentList(tokens []Token, start, count int) ([]ast.Expression, int, error) {
+	name := tokens[start].Value
+	exprs := []ast.Expression{}
+	next := start + 2
+	for next < len(tokens) && tokens[next].Type != CloseBracket {
+		if !isExpressionStart(tokens[next]) {
+			return nil, 0, argumentError(tokens, start, "%s list can only contain numbers, got %s", name, tokens[next].Value)
+		}
+		expr, after, err := parseExpression(tokens, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		exprs = append(exprs, expr)
+		next = after
+	}
+	if next >= len(tokens) {
+		return nil, 0, syntaxError(tokens[start+1].Pos, "unmatched bracket")
+	}
+	if len(exprs) != count {
+		return nil, 0, argumentError(tokens, start, "%s list must have %d numbers, got %d", name, count, len(exprs))
+	}
+	return exprs, next + 1, nil
+}
+
+// parseDoTimes parses `dotimes [name count] [ ... ]`
+func parseDoTimes(tokens []Token, start int, procedures map[string]int) (ast.Command, int, error) {
+	if start+2 >= len(tokens) || tokens[start+1].Type != OpenBracket || tokens[start+2].Type != ProcedureToken {
+		return nil, 0, argumentError(tokens, start, "dotimes command requires a [name count] header")
+	}
+	variable := tokens[start+2].Value
+	if err := expressionArgument(tokens, start, start+3, "dotimes command requires a count"); err != nil {
+		return nil, 0, err
+	}
+	count, next, err := parseExpression(tokens, start+3)
+	if err != nil {
+		return nil, 0, err
+	}
+	if next >= len(tokens) || tokens[next].Type != CloseBracket {
+		return nil, 0, argumentError(tokens, start, "dotimes command requires a [name count] header")
+	}
+	next++
+
+	if next >= len(tokens) || tokens[next].Type != OpenBracket {
+		return nil, 0, argumentError(tokens, start, "dotimes command requires a block")
+	}
+	commands, after, err := parseBlock(tokens, next, procedures)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ast.NewDoTimesCommand(variable, count, commands), after, nil
+}
+
+// parseForEach parses `foreach [numbers] [ ... ]`, where the block refers
+// to the current number as ?, or `foreach "name [numbers] [ ... ]` to give
+// the variable a name
+func parseForEach(tokens []Token, start int, procedures map[string]int) (ast.Command, int, error) {
+	variable := "?"
+	next := start + 1
+	if name, ok := nameArgument(tokens, start); ok {
+		variable = name
+		next++
+	}
+
+	if next >= len(tokens) || tokens[next].Type != OpenBracket {
+		return nil, 0, argumentError(tokens, start, "foreach command requires a list of numbers")
+	}
+	values := []float32{}
+	for next++; next < len(tokens) && tokens[next].Type != CloseBracket; next++ {
+		if tokens[next].Type != NumberToken {
+			return nil, 0, argumentError(tokens, start, "foreach list can only contain numbers, got %s", tokens[next].Value)
+		}
+		value, err := strconv.ParseFloat(tokens[next].Value, 32)
+		if err != nil {
+			return nil, 0, err
+		}
+		values = append(values, float32(value))
+	}
+	if next >= len(tokens) {
+		return nil, 0, syntaxError(tokens[start].Pos, "unmatched bracket")
+	}
+	next++
+
+	if next >= len(tokens) || tokens[next].Type != OpenBracket {
+		return nil, 0, argumentError(tokens, start, "foreach command requires a block")
+	}
+	commands, after, err := parseBlock(tokens, next, procedures)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ast.NewForEachCommand(variable, values, commands), after, nil
+}
+
+// parseProcedureCall parses a call to a user-defined procedure with the
+// given number of inputs
+func parseProcedureCall(tokens []Token, start, arity int) (ast.Command, int, error) {
+	args := make([]ast.Expression, 0, arity)
+	next := start + 1
+	for len(args) < arity {
+		if err := expressionArgument(tokens, start, next, "%s expects %d inputs", tokens[start].Value, arity); err != nil {
+			return nil, 0, err
+		}
+		arg, after, err := parseExpression(tokens, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		args = append(args, arg)
+		next = after
+	}
+	return ast.NewProcedureCallCommand(tokens[start].Value, args), next, nil
+}
+
+// stringArgument returns the quoted word following the command at start
+func stringArgument(tokens []Token, start int) (string, bool) {
+	if start+1 >= len(tokens) || tokens[start+1].Type != StringToken {
+		return "", false
+	}
+	return tokens[start+1].Value, true
+}
+
+// nameArgument returns the quoted word following the command at start in
+// lower case, for names that are matched regardless of case
+func nameArgument(tokens []Token, start int) (string, bool) {
+	name, ok := stringArgument(tokens, start)
+	return strings.ToLower(name), ok
+}
+
+// parseBlock parses a bracketed list of commands starting at the opening
+// bracket, recursing through parseCommand for nested blocks. It returns the
+// commands and the index of the token following the closing bracket.
+func parseBlock(tokens []Token, start int, procedures map[string]int) ([]ast.Command, int, error) {
+	if start >= len(tokens) || tokens[start].Type != OpenBracket {
+		return nil, 0, syntaxError(tokens[min(start, len(tokens)-1)].Pos, "expected [ to start a block")
+	}
+
+	commands := []ast.Command{}
+	i := start + 1
+	for i < len(tokens) && tokens[i].Type != CloseBracket {
+		cmd, next, err := parseCommand(tokens, i, procedures)
+		if err != nil {
+			return nil, 0, err
+		}
+		if cmd != nil {
+			commands = append(commands, cmd)
+		}
+		i = next
+	}
+
+	if i >= len(tokens) {
+		return nil, 0, syntaxError(tokens[start].Pos, "unmatched bracket")
+	}
+
+	return commands, i + 1, nil
+}
+
+// parseProcedureDefinition parses `to name :param ... end`, returning the
+// definition and the index of the token following END
+func parseProcedureDefinition(tokens []Token, start int, procedures map[string]int) (ast.Command, int, error) {
+	if start+1 >= len(tokens) {
+		return nil, 0, syntaxError(tokens[start].Pos, "to requires a procedure name")
+	}
+	nameToken := tokens[start+1]
+	if nameToken.Type != ProcedureToken {
+		return nil, 0, syntaxError(nameToken.Pos, "invalid procedure name %q", nameToken.Value)
+	}
+	if _, exists := findCommandDefinition(nameToken.Value); exists {
+		return nil, 0, syntaxError(nameToken.Pos, "%s is a command and cannot be redefined", nameToken.Value)
+	}
+
+	params := []string{}
+	i := start + 2
+	for i < len(tokens) && tokens[i].Type == VariableToken {
+		params = append(params, tokens[i].Value)
+		i++
+	}
+
+	body := []ast.Command{}
+	for i < len(tokens) && tokens[i].Type != EndToken {
+		if tokens[i].Type == ToToken {
+			return nil, 0, syntaxError(tokens[i].Pos, "procedure definitions cannot be nested")
+		}
+		cmd, next, err := parseCommand(tokens, i, procedures)
+		if err != nil {
+			return nil, 0, err
+		}
+		if cmd != nil {
+			body = append(body, cmd)
+		}
+		i = next
+	}
+
+	if i >= len(tokens) {
+		return nil, 0, syntaxError(tokens[start].Pos, "procedure %s is missing end", nameToken.Value)
+	}
+
+	return ast.NewProcedureDefinition(nameToken.Value, params, body), i + 1, nil
+}
+
+// isExpressionStart reports whether a token can begin an expression
+func isExpressionStart(token Token) bool {
+	return token.Type == NumberToken || token.Type == FunctionToken || token.Type == VariableToken
+}
+
+// expressionArgument checks that the token at next can begin an argument to
+// the command or function at start. An argument is missing at the end of
+// the input or a block, or where the next command starts, and is reported
+// with the given message. Any other token, such as a word or an operator,
+// is the wrong kind of argument and is named in the error.
+func expressionArgument(tokens []Token, start, next int, missing string, args ...any) error {
+	if next < len(tokens) && isExpressionStart(tokens[next]) {
+		return nil
+	}
+	if next >= len(tokens) {
+		return argumentError(tokens, start, missing, args...)
+	}
+	switch tokens[next].Type {
+	case StringToken, OperatorToken, OpenBracket:
+		return argumentError(tokens, start, "%s expects a number or expression but got %s",
+			tokens[start].Value, describeToken(tokens[next]))
+	}
+	return argumentError(tokens, start, missing, args...)
+}
+
+// describeToken names a token for an error message, such as STRING "abc"
+func describeToken(token Token) string {
+	return fmt.Sprintf("%s %q", token.Type, token.Value)
+}
+
+// parseExpression parses an expression, which may compare two sums with <,
+// > or =, returning the expression and the index of the token following it
+func parseExpression(tokens []Token, start int) (ast.Expression, int, error) {
+	left, next, err := parseSum(tokens, start)
+	if err != nil {
+		return nil, 0, err
+	}
+	if next < len(tokens) && tokens[next].Type == OperatorToken &&
+		(tokens[next].Value == "<" || tokens[next].Value == ">" || tokens[next].Value == "=") {
+		operator := tokens[next].Value
+		right, after, err := parseSum(tokens, next+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ast.NewBinaryExpression(operator, left, right), after, nil
+	}
+	return left, next, nil
+}
+
+// parseSum parses a sum with infix + and - operators
+func parseSum(tokens []Token, start int) (ast.Expression, int, error) {
+	left, next, err := parseTerm(tokens, start)
+	if err != nil {
+		return nil, 0, err
+	}
+	for next < len(tokens) && tokens[next].Type == OperatorToken &&
+		(tokens[next].Value == "+" || tokens[next].Value == "-") {
+		operator := tokens[next].Value
+		right, after, err := parseTerm(tokens, next+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		left = ast.NewBinaryExpression(operator, left, right)
+		next = after
+	}
+	return left, next, nil
+}
+
+// parseTerm parses a term with infix *, / and % operators
+func parseTerm(tokens []Token, start int) (ast.Expression, int, error) {
+	left, next, err := parsePower(tokens, start)
+	if err != nil {
+		return nil, 0, err
+	}
+	for next < len(tokens) && tokens[next].Type == OperatorToken &&
+		(tokens[next].Value == "*" || tokens[next].Value == "/" || tokens[next].Value == "%") {
+		operator := tokens[next].Value
+		right, after, err := parsePower(tokens, next+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		left = ast.NewBinaryExpression(operator, left, right)
+		next = after
+	}
+	return left, next, nil
+}
+
+// parsePower parses a power with the infix ^ operator, which binds tighter
+// than * and groups from the right, so 2 ^ 3 ^ 2 is 2 ^ 9
+func parsePower(tokens []Token, start int) (ast.Expression, int, error) {
+	base, next, err := parseOperand(tokens, start)
+	if err != nil {
+		return nil, 0, err
+	}
+	if next < len(tokens) && tokens[next].Type == OperatorToken && tokens[next].Value == "^" {
+		exp, after, err := parsePower(tokens, next+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ast.NewBinaryExpression("^", base, exp), after, nil
+	}
+	return base, next, nil
+}
+
+// parseFunctionArgument parses the argument at next of the function at start
+func parseFunctionArgument(tokens []Token, start, next, arity int) (ast.Expression, int, error) {
+	if err := expressionArgument(tokens, start, next, "%s expects %d arguments", tokens[start].Value, arity); err != nil {
+		return nil, 0, err
+	}
+	return parseExpression(tokens, next)
+}
+
+// parseOperand parses a number, a variable or a prefix function call. Each
+// argument of a prefix function is a full expression, so `sum 1 2 * 3` is sum
+// of 1 and 6.
+func parseOperand(tokens []Token, start int) (ast.Expression, int, error) {
+	if start >= len(tokens) {
+		return nil, 0, syntaxError(tokens[len(tokens)-1].Pos, "unexpected end of input in expression")
+	}
+
+	token := tokens[start]
+	switch token.Type {
+	case NumberToken:
+		value, err := strconv.ParseFloat(token.Value, 64)
+		if err != nil {
+			return nil, 0, syntaxError(token.Pos, "invalid number: %s", token.Value)
+		}
+		return ast.NewNumberExpression(float32(value)), start + 1, nil
+
+	case VariableToken:
+		return ast.NewVariableExpression(token.Value), start + 1, nil
+
+	case FunctionToken:
+		if token.Value == "and" || token.Value == "or" {
+			left, next, err := parseFunctionArgument(tokens, start, start+1, 2)
 			if err != nil {
 				return nil, 0, err
 			}
-			if cmd != nil {
-				blockCommands = append(blockCommands, cmd)
+			right, after, err := parseFunctionArgument(tokens, start, next, 2)
+			if err != nil {
+				return nil, 0, err
 			}
-			i += consumed + 1
+			return ast.NewLogicalExpression(token.Value, left, right), after, nil
 		}
 
-		if i >= len(tokens) || tokens[i].Type != CloseBracket {
-			return nil, 0, fmt.Errorf("repeat block not closed")
+		if ast.IsTurtleReader(token.Value) {
+			return ast.NewTurtleExpression(token.Value), start + 1, nil
+		}
+		if token.Value == "pos" {
+			return nil, 0, syntaxError(token.Pos, "pos is a list, not a number")
 		}
 
-		return ast.NewRepeatCommand(times, blockCommands), i - start, nil
+		def, exists := ast.LookupFunction(token.Value)
+		if !exists {
+			return nil, 0, &UnknownCommandError{Name: token.Value, Pos: token.Pos}
+		}
+		args := make([]ast.Expression, 0, def.Arity)
+		next := start + 1
+		for len(args) < def.Arity {
+			arg, after, err := parseFunctionArgument(tokens, start, next, def.Arity)
+			if err != nil {
+				return nil, 0, err
+			}
+			args = append(args, arg)
+			next = after
+		}
+		return ast.NewFunctionExpression(token.Value, args), next, nil
 	}
 
-	return nil, 0, fmt.Errorf("unknown token type: %v", tokens[start].Type)
+	return nil, 0, syntaxError(token.Pos, "expected a number but got %s", token.Value)
 }
@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixFunctions(t *testing.T) {
+	tests := []struct {
+		program  string
+		expected float32
+	}{
+		{"fd sum 50 50", 100},
+		{"fd difference 50 20", 30},
+		{"fd product 4 5", 20},
+		{"fd quotient 30 4", 7.5},
+		{"fd minus 5", -5},
+		{"fd sum 10 product 2 5", 20},
+		{"fd 2 * 3 + sum 1 1", 8},
+		{"fd product 2 3 + 4", 14},
+		{"fd sum 1 2 * 3 - 1", 6},
+		{"fd modulo 10 3", 1},
+		{"fd modulo -7 3", 2},
+		{"fd modulo 7.9 -3", -2},
+		{"fd 10 % 3", 1},
+		{"fd 1 + 10 % 4 * 2", 5},
+		{"fd power 2 10", 1024},
+		{"fd power 9 0.5", 3},
+		{"fd 2 ^ 10", 1024},
+		{"fd 2 * 3 ^ 2", 18},
+		{"fd 2 ^ 3 ^ 2", 512},
+	}
+
+	for _, tt := range tests {
+		program, err := ParseProgram(tt.program)
+		assert.NoError(t, err, tt.program)
+		if assert.Len(t, program.Commands, 1, tt.program) {
+			forward, ok := program.Commands[0].(*ast.ForwardCommand)
+			assert.True(t, ok, tt.program)
+			assert.InDelta(t, tt.expected, forward.Distance, 0.001, tt.program)
+		}
+	}
+}
+
+func TestPrefixFunctionErrors(t *testing.T) {
+	// Quotient by zero
+	_, err := ParseProgram("fd quotient 10 0")
+	assert.Error(t, err)
+
+	// Missing operand
+	_, err = ParseProgram("fd sum 10")
+	assert.Error(t, err)
+
+	// Modulo by zero, including a divisor that truncates to zero
+	_, err = ParseProgram("fd modulo 10 0")
+	assert.ErrorContains(t, err, "modulo by zero")
+	_, err = ParseProgram("fd 10 % 0.5")
+	assert.ErrorContains(t, err, "modulo by zero")
+
+	_, err = ParseProgram("fd power -8 0.5")
+	assert.ErrorContains(t, err, "power: -8 to the power 0.5 is not a real number")
+
+	// Dangling infix operator
+	_, err = ParseProgram("fd 10 +")
+	assert.Error(t, err)
+}
+
+// countForwards returns how many times ForwardCommands run when the given
+// commands execute, multiplying through nested repeats
+func countForwards(commands []ast.Command) int {
+	count := 0
+	for _, cmd := range commands {
+		switch c := cmd.(type) {
+		case *ast.ForwardCommand:
+			count++
+		case *ast.RepeatCommand:
+			count += c.Times * countForwards(c.Commands)
+		}
+	}
+	return count
+}
+
+func TestNestedRepeat(t *testing.T) {
+	program, err := ParseProgram("repeat 2 [ repeat 3 [ fd 10 rt 30 ] rt 90 ]")
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 1)
+
+	outer, ok := program.Commands[0].(*ast.RepeatCommand)
+	assert.True(t, ok)
+	assert.Equal(t, 2, outer.Times)
+	assert.Len(t, outer.Commands, 2)
+
+	inner, ok := outer.Commands[0].(*ast.RepeatCommand)
+	assert.True(t, ok)
+	assert.Equal(t, 3, inner.Times)
+	assert.Len(t, inner.Commands, 2)
+	assert.IsType(t, &ast.RightCommand{}, outer.Commands[1])
+
+	assert.Equal(t, 6, countForwards(program.Commands))
+}
+
+func TestMultiLineRepeat(t *testing.T) {
+	program, err := ParseProgram(`repeat 2 [
+  fd 10
+  repeat 2 [
+    rt 90
+    fd 5
+  ]
+]
+fd 1`)
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 2)
+	assert.Equal(t, 7, countForwards(program.Commands))
+}
+
+func TestUnmatchedBrackets(t *testing.T) {
+	// Without the lexer's validation pass the block parser reports the
+	// unclosed bracket itself
+	lexer := NewLexer("repeat 2 [ repeat 3 [ fd 10 ]")
+	assert.NoError(t, lexer.Tokenize())
+	_, err := buildProgram(lexer.GetTokens(), nil)
+	assert.ErrorContains(t, err, "unmatched bracket at line 1, column 10")
+
+	lexer = NewLexer("fd 10 ]")
+	assert.NoError(t, lexer.Tokenize())
+	_, err = buildProgram(lexer.GetTokens(), nil)
+	assert.ErrorContains(t, err, "unmatched bracket at line 1, column 7")
+}
+
+func TestBracketValidation(t *testing.T) {
+	// Unclosed bracket
+	_, err := ParseProgram("repeat 4 [ fd 10\nrepeat 2 [ rt 90 ]")
+	assert.ErrorContains(t, err, "unclosed [ at line 1, column 10")
+
+	// Stray closing bracket
+	_, err = ParseProgram("fd 10\nrt 90 ] fd 5")
+	assert.ErrorContains(t, err, "unexpected ] at line 2, column 7")
+
+	// Balanced brackets pass validation
+	lexer := NewLexer("repeat 2 [ repeat 3 [ fd 10 ] ]")
+	assert.NoError(t, lexer.Tokenize())
+	assert.NoError(t, lexer.Validate())
+}
+
+func TestProcedureDefinitionErrors(t *testing.T) {
+	_, err := ParseProgram("to square fd 10")
+	assert.ErrorContains(t, err, "procedure square is missing end")
+
+	_, err = ParseProgram("to outer to inner end end")
+	assert.ErrorContains(t, err, "cannot be nested")
+
+	_, err = ParseProgram("fd 10 end")
+	assert.ErrorContains(t, err, "end without matching to")
+
+	_, err = ParseProgram("to forward fd 10 end")
+	assert.ErrorContains(t, err, `invalid procedure name "forward"`)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("repeat 4 [ fd 100 rt 90 ]\npu home pd"))
+	assert.NoError(t, Validate(""))
+
+	tests := []struct {
+		program string
+		message string
+	}{
+		{"dance 100", "unknown command: dance at line 1, column 1"},
+		{"fd 10\nforward", "forward command requires a number argument"},
+		{"repeat 4 fd 10", "repeat command requires a block"},
+		{"repeat [ fd 10 ]", "repeat command requires a number argument"},
+		{"repeat 4 [ fd 10", "unclosed [ at line 1, column 10"},
+		{"fd quotient 10 0", "division by zero"},
+		{"fd 10 20", "unexpected NUMBER"},
+	}
+
+	for _, tt := range tests {
+		assert.ErrorContains(t, Validate(tt.program), tt.message, tt.program)
+	}
+}
+
+func TestParseProgramCollectErrors(t *testing.T) {
+	program, errs := ParseProgramCollectErrors(`fd 10
+dance 5
+rt 90
+forward
+pu`)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, Position{Line: 2, Column: 1}, errs[0].Pos)
+		assert.ErrorContains(t, errs[0], "unknown command: dance")
+		assert.Equal(t, Position{Line: 4, Column: 1}, errs[1].Pos)
+		assert.ErrorContains(t, errs[1], "forward command requires a number argument")
+	}
+
+	// The commands around the errors are still parsed
+	assert.Equal(t, []ast.Command{
+		ast.NewForwardCommand(10),
+		ast.NewRightCommand(90),
+		ast.NewPenUpCommand(),
+	}, program.Commands)
+}
+
+func TestParseProgramCollectErrorsInBlocks(t *testing.T) {
+	// An error inside a block is reported once, for the command holding it
+	program, errs := ParseProgramCollectErrors("repeat 2 [ fd ]\ndotimes [i 2] [ dance ] pu")
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, Position{Line: 1, Column: 1}, errs[0].Pos)
+		assert.ErrorContains(t, errs[0], "forward command requires a number argument at line 1, column 12")
+		assert.Equal(t, Position{Line: 2, Column: 1}, errs[1].Pos)
+		assert.ErrorContains(t, errs[1], "unknown command: dance")
+	}
+	assert.Equal(t, []ast.Command{ast.NewPenUpCommand()}, program.Commands)
+
+	// A failed TO is skipped to its END, and calls to known procedures
+	// begin commands
+	program, errs = ParseProgramCollectErrors(`to bad
+  fd
+end
+to good
+  fd 10
+end
+setpencolor 300 0 0
+good`)
+	assert.Len(t, errs, 2)
+	if assert.Len(t, program.Commands, 2) {
+		assert.IsType(t, &ast.ProcedureDefinition{}, program.Commands[0])
+		assert.Equal(t, "good", program.Commands[1].(*ast.ProcedureCallCommand).Name)
+	}
+}
+
+func TestParseProgramCollectErrorsValid(t *testing.T) {
+	program, errs := ParseProgramCollectErrors("repeat 4 [ fd 10 rt 90 ]")
+	assert.Empty(t, errs)
+	assert.Len(t, program.Commands, 1)
+}
+
+func TestSetPenSizeValidation(t *testing.T) {
+	assert.ErrorContains(t, Validate("setpensize 0"), "pen size must be greater than 0, got 0")
+	assert.ErrorContains(t, Validate("setpensize -5"), "pen size must be greater than 0, got -5")
+	assert.NoError(t, Validate("setpensize 0.5"))
+}
+
+func TestProcedureCalls(t *testing.T) {
+	// Calls may come before the definition
+	program, err := ParseProgram("tri 10 + 5\nto tri :size\nrepeat 3 [ fd :size rt 120 ]\nend")
+	assert.NoError(t, err)
+	if assert.Len(t, program.Commands, 2) {
+		call, ok := program.Commands[0].(*ast.ProcedureCallCommand)
+		if assert.True(t, ok) {
+			assert.Equal(t, "tri", call.Name)
+			assert.Len(t, call.Args, 1)
+		}
+	}
+
+	_, err = ParseProgram("tri\nto tri :size\nfd :size\nend")
+	assert.ErrorContains(t, err, "tri expects 1 inputs")
+
+	program, err = ParseProgramWithProcedures("hop", map[string]int{"hop": 0})
+	assert.NoError(t, err)
+	assert.Len(t, program.Commands, 1)
+}
+
+func TestRampPenSize(t *testing.T) {
+	program, err := ParseProgram("rampensize 1 5 4")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{ast.NewRampPenSizeCommand(1, 5, 4)}, program.Commands)
+
+	assert.ErrorContains(t, Validate("rampensize 0 5 4"), "pen sizes must be greater than 0")
+	assert.ErrorContains(t, Validate("rampensize 1 5 1.5"), "steps must be a whole number of at least 2, got 1.5")
+}
+
+func TestRepeatCount(t *testing.T) {
+	program, err := ParseProgram("repeat 0 [ fd 10 ] rt 90")
+	assert.NoError(t, err)
+	if assert.Len(t, program.Commands, 2) {
+		repeat, ok := program.Commands[0].(*ast.RepeatCommand)
+		if assert.True(t, ok) {
+			assert.Equal(t, 0, repeat.Times)
+		}
+	}
+	assert.Equal(t, 0, countForwards(program.Commands))
+
+	assert.ErrorContains(t, Validate("repeat -2 [ fd 10 ]"), "repeat count must be a whole number of at least 0, got -2")
+	assert.ErrorContains(t, Validate("repeat 2.5 [ fd 10 ]"), "repeat count must be a whole number of at least 0, got 2.5")
+	assert.NoError(t, Validate("repeat 3.0 [ fd 10 ]"))
+}
+
+func TestNamesIgnoreCase(t *testing.T) {
+	program, err := ParseProgram(`setshape "Arrow newturtle "Bob tell "BOB make "Size 10 print "Hello`)
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Command{
+		ast.NewSetShapeCommand("arrow"),
+		ast.NewNewTurtleCommand("bob"),
+		ast.NewTellCommand("bob"),
+		ast.NewMakeCommand("size", ast.NewNumberExpression(10)),
+		ast.NewPrintCommand("Hello"),
+	}, program.Commands)
+}
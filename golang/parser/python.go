@@ -0,0 +1,510 @@
+package parser
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/honeylogo/logo/ast"
+)
+
+// pythonIndent is the indentation used for each level of nesting in Python
+const pythonIndent = "    "
+
+// pythonHeader sets Python's turtle up to match a Logo turtle: facing up
+// with headings measured clockwise, and colors given as 0 to 255
+const pythonHeader = `from turtle import *
+
+mode("logo")
+colormode(255)
+`
+
+// ToPython parses a program and writes the equivalent program for Python's
+// turtle module. Procedures become functions, defined before the rest of
+// the program so they can be called from anywhere, and loops become for
+// and while loops. Commands with no counterpart in Python's turtle, such as
+// those for multiple turtles, are reported as errors.
+func ToPython(input string) (string, error) {
+	program, err := ParseProgram(input)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if pythonNeedsMath(program) {
+		b.WriteString("import math\n")
+	}
+	b.WriteString(pythonHeader)
+	for _, cmd := range program.Commands {
+		if pd, ok := cmd.(*ast.ProcedureDefinition); ok {
+			b.WriteString("\n")
+			if err := pythonFunction(&b, pd); err != nil {
+				return "", err
+			}
+		}
+	}
+	b.WriteString("\n")
+	for _, cmd := range program.Commands {
+		if _, ok := cmd.(*ast.ProcedureDefinition); ok {
+			continue
+		}
+		if err := pythonCommand(&b, cmd, 0, false); err != nil {
+			return "", err
+		}
+	}
+	b.WriteString("done()\n")
+	return b.String(), nil
+}
+
+// pythonFunction writes a procedure as a Python function. Logo variables
+// are global, so variables the procedure makes, other than its inputs, are
+// declared global.
+func pythonFunction(b *strings.Builder, pd *ast.ProcedureDefinition) error {
+	fmt.Fprintf(b, "def %s(%s):\n", pd.Name, strings.Join(pd.Params, ", "))
+
+	globals := []string{}
+	for _, cmd := range pd.Body {
+		err := ast.Walk(cmd, func(c ast.Command) error {
+			if mc, ok := c.(*ast.MakeCommand); ok {
+				if !slices.Contains(pd.Params, mc.Name) && !slices.Contains(globals, mc.Name) {
+					globals = append(globals, mc.Name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(globals) > 0 {
+		fmt.Fprintf(b, "%sglobal %s\n", pythonIndent, strings.Join(globals, ", "))
+	}
+	if pythonReadsRepCount(pd.Body) {
+		return fmt.Errorf("cannot convert %s to Python: it reads %s outside a REPEAT", pd.Name, ast.RepCount)
+	}
+	return pythonBody(b, pd.Body, 1, false)
+}
+
+// pythonBody writes the commands of a block, or pass if it is empty
+func pythonBody(b *strings.Builder, commands []ast.Command, depth int, counted bool) error {
+	if len(commands) == 0 {
+		fmt.Fprintf(b, "%spass\n", strings.Repeat(pythonIndent, depth))
+		return nil
+	}
+	for _, cmd := range commands {
+		if err := pythonCommand(b, cmd, depth, counted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pythonCommand writes the Python equivalent of cmd at the given depth.
+// counted is whether an enclosing loop has repcount as its loop variable.
+func pythonCommand(b *strings.Builder, cmd ast.Command, depth int, counted bool) error {
+	prefix := strings.Repeat(pythonIndent, depth)
+
+	switch c := cmd.(type) {
+	case *ast.SetColorCommand:
+		fmt.Fprintf(b, "%spencolor(%d, %d, %d)\n", prefix, c.R, c.G, c.B)
+	case *ast.SetPaletteColorCommand:
+		rgba := color.RGBAModel.Convert(ast.StandardColors[c.Index].Color).(color.RGBA)
+		fmt.Fprintf(b, "%spencolor(%d, %d, %d)\n", prefix, rgba.R, rgba.G, rgba.B)
+	case *ast.SetShapeCommand:
+		if c.Shape == "blank" {
+			fmt.Fprintf(b, "%shideturtle()\n", prefix)
+		} else {
+			fmt.Fprintf(b, "%sshape(%q)\n", prefix, c.Shape)
+		}
+	case *ast.MakeCommand:
+		fmt.Fprintf(b, "%s%s = %s\n", prefix, c.Name, pythonExpression(c.Value))
+	case *ast.PrintCommand:
+		if c.Position {
+			fmt.Fprintf(b, "%sprint(pos())\n", prefix)
+		} else if c.Value != nil {
+			fmt.Fprintf(b, "%sprint(%s)\n", prefix, pythonExpression(c.Value))
+		} else {
+			fmt.Fprintf(b, "%sprint(%q)\n", prefix, c.Text)
+		}
+	case *ast.ProcedureCallCommand:
+		args := make([]string, len(c.Args))
+		for i, arg := range c.Args {
+			args[i] = pythonExpression(arg)
+		}
+		fmt.Fprintf(b, "%s%s(%s)\n", prefix, c.Name, strings.Join(args, ", "))
+
+	case *ast.RepeatCommand:
+		if !pythonReadsRepCount(c.Commands) {
+			fmt.Fprintf(b, "%sfor _ in range(%d):\n", prefix, c.Times)
+			return pythonBody(b, c.Commands, depth+1, counted)
+		}
+		// repcount counts from 1, and the count of an enclosing loop is put
+		// back once this one is done with it
+		saved := fmt.Sprintf("%s_%d", ast.RepCount, depth)
+		if counted {
+			fmt.Fprintf(b, "%s%s = %s\n", prefix, saved, ast.RepCount)
+		}
+		fmt.Fprintf(b, "%sfor %s in range(1, %d):\n", prefix, ast.RepCount, c.Times+1)
+		if err := pythonBody(b, c.Commands, depth+1, true); err != nil {
+			return err
+		}
+		if counted {
+			fmt.Fprintf(b, "%s%s = %s\n", prefix, ast.RepCount, saved)
+		}
+	case *ast.IfCommand:
+		fmt.Fprintf(b, "%sif %s:\n", prefix, pythonExpression(c.Condition))
+		return pythonBody(b, c.Commands, depth+1, counted)
+	case *ast.WhileCommand:
+		fmt.Fprintf(b, "%swhile %s:\n", prefix, pythonExpression(c.Condition))
+		return pythonBody(b, c.Commands, depth+1, counted)
+	case *ast.DoTimesCommand:
+		count := pythonExpression(c.Count)
+		if _, ok := c.Count.(*ast.NumberExpression); !ok {
+			count = fmt.Sprintf("int(%s)", count)
+		}
+		fmt.Fprintf(b, "%sfor %s in range(%s):\n", prefix, c.Variable, count)
+		return pythonBody(b, c.Commands, depth+1, counted)
+	case *ast.ForEachCommand:
+		values := make([]string, len(c.Values))
+		for i, value := range c.Values {
+			values[i] = formatNumber(value)
+		}
+		fmt.Fprintf(b, "%sfor %s in [%s]:\n", prefix, pythonVariable(c.Variable), strings.Join(values, ", "))
+		return pythonBody(b, c.Commands, depth+1, counted)
+
+	default:
+		name, args, ok := pythonCallArgs(cmd)
+		if !ok {
+			return fmt.Errorf("cannot convert %s to Python", cmd.String())
+		}
+		call, ok := pythonCalls[name]
+		if !ok {
+			return fmt.Errorf("cannot convert %s to Python", cmd.String())
+		}
+		fmt.Fprintf(b, "%s%s\n", prefix, call(args))
+	}
+
+	return nil
+}
+
+// pythonReadsRepCount reports whether any of the commands read repcount,
+// other than inside a nested REPEAT, which has a count of its own
+func pythonReadsRepCount(commands []ast.Command) bool {
+	for _, cmd := range commands {
+		if _, ok := cmd.(*ast.RepeatCommand); ok {
+			continue
+		}
+		for _, expr := range commandExpressions(cmd) {
+			if readsVariable(expr, ast.RepCount) {
+				return true
+			}
+		}
+		if pythonReadsRepCount(ast.Children(cmd)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandExpressions returns the expressions a command evaluates, other
+// than those of the commands nested inside it
+func commandExpressions(cmd ast.Command) []ast.Expression {
+	switch c := cmd.(type) {
+	case *ast.DeferredCommand:
+		return c.Args
+	case *ast.ProcedureCallCommand:
+		return c.Args
+	case *ast.MakeCommand:
+		return []ast.Expression{c.Value}
+	case *ast.PrintCommand:
+		if c.Value != nil {
+			return []ast.Expression{c.Value}
+		}
+	case *ast.IfCommand:
+		return []ast.Expression{c.Condition}
+	case *ast.WhileCommand:
+		return []ast.Expression{c.Condition}
+	case *ast.TestCommand:
+		return []ast.Expression{c.Condition}
+	case *ast.DoTimesCommand:
+		return []ast.Expression{c.Count}
+	}
+	return nil
+}
+
+// readsVariable reports whether an expression reads the named variable
+func readsVariable(expr ast.Expression, name string) bool {
+	return containsExpression(expr, func(e ast.Expression) bool {
+		ve, ok := e.(*ast.VariableExpression)
+		return ok && ve.Name == name
+	})
+}
+
+// containsExpression reports whether match holds for an expression or any
+// expression inside it
+func containsExpression(expr ast.Expression, match func(ast.Expression) bool) bool {
+	if match(expr) {
+		return true
+	}
+	switch e := expr.(type) {
+	case *ast.BinaryExpression:
+		return containsExpression(e.Left, match) || containsExpression(e.Right, match)
+	case *ast.LogicalExpression:
+		return containsExpression(e.Left, match) || containsExpression(e.Right, match)
+	case *ast.FunctionExpression:
+		for _, arg := range e.Args {
+			if containsExpression(arg, match) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pythonMathFunctions are the Logo functions written with Python's math
+// module
+var pythonMathFunctions = []string{"sin", "cos", "tan", "sqrt", "round"}
+
+// pythonNeedsMath reports whether a program uses a function written with
+// Python's math module
+func pythonNeedsMath(program *ast.Program) bool {
+	needed := false
+	ast.Walk(program, func(c ast.Command) error {
+		for _, expr := range commandExpressions(c) {
+			if containsExpression(expr, func(e ast.Expression) bool {
+				fe, ok := e.(*ast.FunctionExpression)
+				return ok && slices.Contains(pythonMathFunctions, fe.Name)
+			}) {
+				needed = true
+			}
+		}
+		return nil
+	})
+	return needed
+}
+
+// pythonCallArgs returns the command name and arguments of a command that
+// maps onto a single Python turtle call, whether its arguments are constant
+// or deferred until they can be evaluated
+func pythonCallArgs(cmd ast.Command) (string, []ast.Expression, bool) {
+	number := func(values ...float32) []ast.Expression {
+		exprs := make([]ast.Expression, len(values))
+		for i, value := range values {
+			exprs[i] = ast.NewNumberExpression(value)
+		}
+		return exprs
+	}
+
+	switch c := cmd.(type) {
+	case *ast.DeferredCommand:
+		return c.Name, c.Args, true
+	case *ast.ForwardCommand:
+		return "forward", number(c.Distance), true
+	case *ast.BackwardCommand:
+		return "backward", number(c.Distance), true
+	case *ast.LeftCommand:
+		return "left", number(c.Angle), true
+	case *ast.RightCommand:
+		return "right", number(c.Angle), true
+	case *ast.PenUpCommand:
+		return "penup", nil, true
+	case *ast.PenDownCommand:
+		return "pendown", nil, true
+	case *ast.SetPenSizeCommand:
+		return "setpensize", number(c.Size), true
+	case *ast.SetXCommand:
+		return "setx", number(c.X), true
+	case *ast.SetYCommand:
+		return "sety", number(c.Y), true
+	case *ast.SetPositionCommand:
+		return "setxy", number(c.X, c.Y), true
+	case *ast.SetHeadingCommand:
+		return "setheading", number(c.Angle), true
+	case *ast.HomeCommand:
+		return "home", nil, true
+	case *ast.ArcRightCommand:
+		return "arcr", number(c.Radius, c.Angle), true
+	case *ast.ArcLeftCommand:
+		return "arcl", number(c.Radius, c.Angle), true
+	case *ast.DiscCommand:
+		return "disc", number(c.Radius), true
+	}
+	return "", nil, false
+}
+
+// pythonCalls writes the Python turtle call for each command that has one,
+// given the command's arguments
+var pythonCalls = map[string]func(args []ast.Expression) string{
+	"forward":    pythonCall("forward"),
+	"backward":   pythonCall("backward"),
+	"left":       pythonCall("left"),
+	"right":      pythonCall("right"),
+	"penup":      pythonCall("penup"),
+	"pendown":    pythonCall("pendown"),
+	"setpensize": pythonCall("pensize"),
+	"setx":       pythonCall("setx"),
+	"sety":       pythonCall("sety"),
+	"setxy":      pythonCall("goto"),
+	"home":       pythonCall("home"),
+	"setheading": func(args []ast.Expression) string {
+		// Logo mode in Python measures headings from up, a quarter turn on
+		// from where SETHEADING measures them
+		if n, ok := args[0].(*ast.NumberExpression); ok {
+			heading := math.Mod(float64(n.Value)+90, 360)
+			if heading < 0 {
+				heading += 360
+			}
+			return fmt.Sprintf("setheading(%s)", formatNumber(float32(heading)))
+		}
+		return fmt.Sprintf("setheading(%s + 90)", pythonExpression(args[0]))
+	},
+	"arcr": func(args []ast.Expression) string {
+		// A negative radius makes Python's circle curve to the right
+		return fmt.Sprintf("circle(%s, %s)", pythonNegate(args[0]), pythonExpression(args[1]))
+	},
+	"arcl": pythonCall("circle"),
+	"disc": func(args []ast.Expression) string {
+		// dot takes a diameter
+		return fmt.Sprintf("dot(%s)", pythonInfix("*", ast.NewNumberExpression(2), args[0]))
+	},
+}
+
+// pythonCall returns a function writing a call to the named Python function
+// with the arguments in order
+func pythonCall(name string) func(args []ast.Expression) string {
+	return func(args []ast.Expression) string {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = pythonExpression(arg)
+		}
+		return fmt.Sprintf("%s(%s)", name, strings.Join(parts, ", "))
+	}
+}
+
+// pythonNegate writes the negation of an expression
+func pythonNegate(expr ast.Expression) string {
+	if n, ok := expr.(*ast.NumberExpression); ok {
+		return formatNumber(-n.Value)
+	}
+	return fmt.Sprintf("-(%s)", pythonExpression(expr))
+}
+
+// pythonExpression writes an expression in Python. Prefix functions are
+// written as parenthesised infix operations, so they keep their operands
+// together wherever they appear.
+func pythonExpression(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.NumberExpression:
+		return formatNumber(e.Value)
+	case *ast.VariableExpression:
+		return pythonVariable(e.Name)
+	case *ast.TurtleExpression:
+		if e.Name == "heading" {
+			return "(heading() - 90) % 360"
+		}
+		return e.Name + "()"
+	case *ast.BinaryExpression:
+		return pythonInfix(e.Operator, e.Left, e.Right)
+	case *ast.LogicalExpression:
+		return fmt.Sprintf("(%s %s %s)", pythonExpression(e.Left), e.Operator, pythonExpression(e.Right))
+	case *ast.FunctionExpression:
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = pythonExpression(arg)
+		}
+		switch e.Name {
+		case "sum":
+			return "(" + pythonInfix("+", e.Args[0], e.Args[1]) + ")"
+		case "difference":
+			return "(" + pythonInfix("-", e.Args[0], e.Args[1]) + ")"
+		case "product":
+			return "(" + pythonInfix("*", e.Args[0], e.Args[1]) + ")"
+		case "quotient":
+			return "(" + pythonInfix("/", e.Args[0], e.Args[1]) + ")"
+		case "modulo":
+			return "(" + pythonInfix("%", e.Args[0], e.Args[1]) + ")"
+		case "power":
+			return "(" + pythonInfix("^", e.Args[0], e.Args[1]) + ")"
+		case "minus":
+			return fmt.Sprintf("(-%s)", pythonOperand(e.Args[0], "*", true))
+		case "not":
+			return fmt.Sprintf("(not %s)", args[0])
+		case "sin", "cos", "tan":
+			// Python measures angles in radians
+			return fmt.Sprintf("math.%s(math.radians(%s))", e.Name, args[0])
+		case "sqrt":
+			return fmt.Sprintf("math.sqrt(%s)", args[0])
+		case "round":
+			// Python's round takes halves to the even number, where Logo
+			// takes them away from zero
+			return fmt.Sprintf("math.copysign(math.floor(abs(%s) + 0.5), %s)", args[0], args[0])
+		}
+		return fmt.Sprintf("%s(%s)", e.Name, strings.Join(args, ", "))
+	}
+	return expr.String()
+}
+
+// pythonInfix writes a binary operation, with Logo's = written as == and ^
+// as **. Logo's % truncates its operands to whole numbers first, after
+// which Python's % gives the same remainder.
+func pythonInfix(operator string, left, right ast.Expression) string {
+	written := operator
+	switch operator {
+	case "=":
+		written = "=="
+	case "^":
+		written = "**"
+	case "%":
+		return fmt.Sprintf("%s %% %s", pythonWhole(left), pythonWhole(right))
+	}
+	return fmt.Sprintf("%s %s %s", pythonOperand(left, operator, false), written, pythonOperand(right, operator, true))
+}
+
+// pythonWhole writes an expression truncated to a whole number
+func pythonWhole(expr ast.Expression) string {
+	if n, ok := expr.(*ast.NumberExpression); ok {
+		return formatNumber(float32(math.Trunc(float64(n.Value))))
+	}
+	return fmt.Sprintf("int(%s)", pythonExpression(expr))
+}
+
+// pythonOperand writes an operand of a binary operator, parenthesising a
+// nested operation that would otherwise bind differently. Operators group
+// from the left, apart from ^. Python's ** binds tighter than a minus sign
+// on its left, so a negative number there is parenthesised too.
+func pythonOperand(expr ast.Expression, operator string, right bool) string {
+	text := pythonExpression(expr)
+	if n, ok := expr.(*ast.NumberExpression); ok && n.Value < 0 && operator == "^" && !right {
+		return "(" + text + ")"
+	}
+	if inner, ok := expr.(*ast.BinaryExpression); ok {
+		same := precedence(inner.Operator) == precedence(operator)
+		if precedence(inner.Operator) < precedence(operator) || (same && right != (operator == "^")) {
+			return "(" + text + ")"
+		}
+	}
+	return text
+}
+
+// precedence ranks binary operators from loosest to tightest binding
+func precedence(operator string) int {
+	switch operator {
+	case "<", ">", "=":
+		return 1
+	case "+", "-":
+		return 2
+	case "^":
+		return 4
+	}
+	return 3
+}
+
+// pythonVariable writes a variable name, naming FOREACH's ? item
+func pythonVariable(name string) string {
+	if name == "?" {
+		return "item"
+	}
+	return name
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToPythonRepeat(t *testing.T) {
+	python, err := ToPython("repeat 4 [ fd 50 rt 90 ]")
+	assert.NoError(t, err)
+	assert.Equal(t, `from turtle import *
+
+mode("logo")
+colormode(255)
+
+for _ in range(4):
+    forward(50)
+    right(90)
+done()
+`, python)
+}
+
+func TestToPythonRepCount(t *testing.T) {
+	python, err := ToPython("repeat 3 [ fd repcount * 10 ]")
+	assert.NoError(t, err)
+	assert.Contains(t, python, `
+for repcount in range(1, 4):
+    forward(repcount * 10)
+done()
+`)
+
+	// An inner count doesn't hide the outer one once it finishes
+	python, err = ToPython("repeat 2 [ repeat 3 [ rt :repcount ] fd repcount ]")
+	assert.NoError(t, err)
+	assert.Contains(t, python, `
+for repcount in range(1, 3):
+    repcount_1 = repcount
+    for repcount in range(1, 4):
+        right(repcount)
+    repcount = repcount_1
+    forward(repcount)
+done()
+`)
+
+	// Procedures can't see the count of the loop they are called from
+	_, err = ToPython(`to side
+  fd repcount
+end
+repeat 4 [ side ]`)
+	assert.ErrorContains(t, err, "cannot convert side to Python: it reads repcount outside a REPEAT")
+}
+
+func TestToPythonProcedures(t *testing.T) {
+	python, err := ToPython(`make "count 0
+square 20 + 5
+to square :size
+  repeat 4 [ fd :size rt 90 ]
+  make "count :count + 1
+end`)
+	assert.NoError(t, err)
+	assert.Contains(t, python, `
+def square(size):
+    global count
+    for _ in range(4):
+        forward(size)
+        right(90)
+    count = count + 1
+
+count = 0
+square(20 + 5)
+done()
+`)
+}
+
+func TestToPythonCommands(t *testing.T) {
+	tests := []struct {
+		logo   string
+		python string
+	}{
+		{"setheading 0", "setheading(90)"},
+		{"seth 270", "setheading(0)"},
+		{`make "h 10 seth :h`, "setheading(h + 90)"},
+		{"arcr 50 90", "circle(-50, 90)"},
+		{"arcl 50 90", "circle(50, 90)"},
+		{"setxy 10 -20", "goto(10, -20)"},
+		{`setpc "red`, "pencolor(255, 0, 0)"},
+		{"setps 3 pu home pd", "pensize(3)\npenup()\nhome()\npendown()"},
+		{"print sum 1 2 * 3", "print((1 + 2 * 3))"},
+		{`if and xcor > 0 not :x = 1 [ ]`, "if (xcor() > 0 and (not x == 1)):\n    pass"},
+		{"dotimes [i 3] [ fd :i ]", "for i in range(3):\n    forward(i)"},
+		{"foreach [1 2] [ rt ? ]", "for item in [1, 2]:\n    right(item)"},
+		{"fd difference :a 2 - 1", "forward((a - (2 - 1)))"},
+		{"fd :a - 2 - 1", "forward(a - 2 - 1)"},
+		{"fd minus :a + 1", "forward((-(a + 1)))"},
+		{"fd :a % 3 + power :b 2", "forward(int(a) % 3 + (b ** 2))"},
+		{"fd modulo :a + 1 2.5 * :b", "forward((int(a + 1) % int(2.5 * b)))"},
+		{"fd 2 ^ 3 ^ :c", "forward(2 ** 3 ** c)"},
+		{`make "x power -2 2`, "x = ((-2) ** 2)"},
+		{"fd -2 ^ :c + 2 ^ -1", "forward((-2) ** c + 2 ** -1)"},
+	}
+
+	for _, tt := range tests {
+		python, err := ToPython(tt.logo)
+		assert.NoError(t, err, tt.logo)
+		assert.Contains(t, python, "\n"+tt.python+"\n", tt.logo)
+	}
+}
+
+func TestToPythonMath(t *testing.T) {
+	python, err := ToPython(`make "x 16 fd sqrt :x rt sin :x`)
+	assert.NoError(t, err)
+	assert.Equal(t, `import math
+from turtle import *
+
+mode("logo")
+colormode(255)
+
+x = 16
+forward(math.sqrt(x))
+right(math.sin(math.radians(x)))
+done()
+`, python)
+
+	// Logo rounds halves away from zero, where Python's round goes to even
+	python, err = ToPython("repeat 2 [ setx round :a sety tan :b ]")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(python, "import math\n"))
+	assert.Contains(t, python, "setx(math.copysign(math.floor(abs(a) + 0.5), a))")
+	assert.Contains(t, python, "sety(math.tan(math.radians(b)))")
+
+	// Functions Python has without the math module don't import it
+	python, err = ToPython("fd abs :a + int :b")
+	assert.NoError(t, err)
+	assert.NotContains(t, python, "math")
+	assert.Contains(t, python, "forward(abs(a + int(b)))")
+}
+
+func TestToPythonUnsupported(t *testing.T) {
+	_, err := ToPython(`newturtle "bob`)
+	assert.ErrorContains(t, err, "cannot convert NEWTURTLE")
+
+	_, err = ToPython("repeat 2 [ slide 10 5 ]")
+	assert.ErrorContains(t, err, "cannot convert SLIDE 10 5 to Python")
+
+	_, err = ToPython("fd 10 ]")
+	assert.Error(t, err)
+}
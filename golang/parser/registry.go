@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/honeylogo/logo/ast"
+)
+
+// commandsMutex guards commandDefinitions against commands being registered
+// while programs are parsed
+var commandsMutex sync.RWMutex
+
+// RegisterCommand adds a primitive to the language. The factory receives the
+// command's argument values and returns the command to execute. Names and
+// aliases are case-insensitive and may not clash with builtin commands,
+// keywords or commands registered earlier.
+func RegisterCommand(name string, aliases []string, argCount int, factory func([]float64) ast.Command) error {
+	if argCount < 0 {
+		return fmt.Errorf("command %s cannot take %d arguments", name, argCount)
+	}
+	if factory == nil {
+		return fmt.Errorf("command %s needs a factory", name)
+	}
+
+	name = strings.ToLower(name)
+	lowered := make([]string, len(aliases))
+	for i, alias := range aliases {
+		lowered[i] = strings.ToLower(alias)
+	}
+
+	commandsMutex.Lock()
+	defer commandsMutex.Unlock()
+	for _, word := range append([]string{name}, lowered...) {
+		if err := checkCommandName(word); err != nil {
+			return err
+		}
+	}
+
+	commandDefinitions[name] = CommandDefinition{
+		Aliases:  lowered,
+		ArgCount: argCount,
+		CreateCommand: func(args []float32) ast.Command {
+			values := make([]float64, len(args))
+			for i, arg := range args {
+				values[i] = float64(arg)
+			}
+			return factory(values)
+		},
+	}
+	return nil
+}
+
+// IsBuiltin reports whether name is a builtin command, a keyword or a
+// registered command, none of which can be replaced by a procedure
+func IsBuiltin(name string) bool {
+	name = strings.ToLower(name)
+	lexer := NewLexer(name)
+	if err := lexer.Tokenize(); err != nil {
+		return false
+	}
+	tokens := lexer.GetTokens()
+	if len(tokens) != 1 || tokens[0].Type != ProcedureToken {
+		return true
+	}
+	_, exists := findCommandDefinition(name)
+	return exists
+}
+
+// checkCommandName reports whether word is free to name a new command. Only
+// words the lexer treats as procedure names can be used, which rules out
+// every builtin, and they must not already be in the command table.
+func checkCommandName(word string) error {
+	lexer := NewLexer(word)
+	if err := lexer.Tokenize(); err != nil {
+		return err
+	}
+	tokens := lexer.GetTokens()
+	if len(tokens) != 1 || tokens[0].Type != ProcedureToken {
+		return fmt.Errorf("cannot register %q: it is a builtin command or keyword", word)
+	}
+
+	for existing, def := range commandDefinitions {
+		if existing == word {
+			return fmt.Errorf("cannot register %q: it is already registered", word)
+		}
+		for _, alias := range def.Aliases {
+			if alias == word {
+				return fmt.Errorf("cannot register %q: it is already an alias of %s", word, existing)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterCommand(t *testing.T) {
+	err := RegisterCommand("Star", []string{"st5"}, 1, func(args []float64) ast.Command {
+		return ast.NewRepeatCommand(5, []ast.Command{
+			ast.NewForwardCommand(float32(args[0])),
+			ast.NewRightCommand(144),
+		})
+	})
+	assert.NoError(t, err)
+
+	program, err := ParseProgram("star 50 st5 sum 10 10")
+	assert.NoError(t, err)
+	if assert.Len(t, program.Commands, 2) {
+		first := program.Commands[0].(*ast.RepeatCommand)
+		assert.Equal(t, 5, first.Times)
+		assert.Equal(t, ast.NewForwardCommand(50), first.Commands[0])
+		second := program.Commands[1].(*ast.RepeatCommand)
+		assert.Equal(t, ast.NewForwardCommand(20), second.Commands[0])
+	}
+
+	assert.ErrorContains(t, Validate("star"), "star command requires a number argument")
+}
+
+func TestRegisterCommandCollisions(t *testing.T) {
+	factory := func(_ []float64) ast.Command { return ast.NewHomeCommand() }
+
+	assert.ErrorContains(t, RegisterCommand("forward", nil, 0, factory), "builtin")
+	assert.ErrorContains(t, RegisterCommand("FD", nil, 0, factory), "builtin")
+	assert.ErrorContains(t, RegisterCommand("jump", []string{"repeat"}, 0, factory), "builtin")
+
+	assert.NoError(t, RegisterCommand("jump", []string{"jmp"}, 0, factory))
+	assert.ErrorContains(t, RegisterCommand("jump", nil, 0, factory), "already registered")
+	assert.ErrorContains(t, RegisterCommand("hop", []string{"jmp"}, 0, factory), "already an alias of jump")
+
+	assert.Error(t, RegisterCommand("skip", nil, -1, factory))
+	assert.Error(t, RegisterCommand("skip", nil, 0, nil))
+}
@@ -0,0 +1,33 @@
+package rendering
+
+import (
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/honeylogo/logo/drawing"
+)
+
+// WritePNG encodes the last rendered image as a PNG
+func (r *DefaultRenderer) WritePNG(w io.Writer) error {
+	return png.Encode(w, r.img)
+}
+
+// SavePNG renders a drawing onto a canvas of the given size and writes it to
+// a PNG file, without needing a window. Like RenderDrawing it draws the
+// drawing's Segments, so a path broken by a pen-up jump is exported as
+// separate lines rather than one line across the gap.
+func SavePNG(d *drawing.Drawing, path string, width, height int) error {
+	r := NewRenderer(width, height)
+	r.RenderDrawing(d)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WritePNG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
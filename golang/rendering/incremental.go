@@ -0,0 +1,88 @@
+package rendering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/honeylogo/logo/drawing"
+)
+
+// spriteState remembers the turtle sprite drawn by RenderSegment and the
+// pixels it covers, so it can be erased before the next segment is added
+type spriteState struct {
+	background *image.RGBA // Pixels under the sprite, nil when none is drawn
+	angle      float64     // Heading of the sprite in radians
+}
+
+// RenderSegment adds one segment to the last rendered image without
+// redrawing the rest, for interactive use where the path grows a segment at
+// a time. The turtle sprite is erased from its previous position and redrawn
+// as a triangle at the end of the segment, pointing along it, unless
+// DrawTurtleSprite is off. If to has the pen up only the sprite moves. The
+// segment is placed using the coordinate system of the last drawing passed
+// to RenderDrawing.
+func (r *DefaultRenderer) RenderSegment(from, to drawing.Point) *image.RGBA {
+	r.restoreTurtleBackground()
+
+	if to.PenDown {
+		r.drawSegment(from.X, from.Y, to.X, to.Y, penColor(to), to.PenPattern)
+	}
+
+	if !r.DrawTurtleSprite {
+		return r.img
+	}
+	if to.X != from.X || to.Y != from.Y {
+		r.sprite.angle = math.Atan2(to.Y-from.Y, to.X-from.X)
+	}
+	r.saveTurtleBackground(to, r.sprite.angle)
+	r.drawTriangle(r.coordinates, to.X, to.Y, r.sprite.angle, r.turtleColor(to.PenColor))
+
+	return r.img
+}
+
+// spriteBounds returns the canvas rectangle covered by the sprite at p
+// pointing along angle: the bounding box of its corners as they are rounded
+// to pixels, grown by half the pen size so thick lines are covered too
+func (r *DefaultRenderer) spriteBounds(p drawing.Point, angle float64) image.Rectangle {
+	var bounds image.Rectangle
+	for i, corner := range triangleCorners(p.X, p.Y, angle) {
+		x, y := r.toCanvas(r.coordinates, corner)
+		pixel := image.Rect(x, y, x+1, y+1)
+		if i == 0 {
+			bounds = pixel
+		} else {
+			bounds = bounds.Union(pixel)
+		}
+	}
+	pad := int(math.Ceil(p.PenSize / 2))
+	return bounds.Inset(-pad).Intersect(r.img.Bounds())
+}
+
+// saveTurtleBackground copies the pixels the sprite at p is about to cover
+func (r *DefaultRenderer) saveTurtleBackground(p drawing.Point, angle float64) {
+	bounds := r.spriteBounds(p, angle)
+	background := image.NewRGBA(bounds)
+	draw.Draw(background, bounds, r.img, bounds.Min, draw.Src)
+	r.sprite.background = background
+}
+
+// restoreTurtleBackground erases the sprite by putting back the pixels that
+// were under it
+func (r *DefaultRenderer) restoreTurtleBackground() {
+	if r.sprite.background == nil {
+		return
+	}
+	bounds := r.sprite.background.Bounds()
+	draw.Draw(r.img, bounds, r.sprite.background, bounds.Min, draw.Src)
+	r.sprite.background = nil
+}
+
+// penColor returns the color a point was drawn with, defaulting to black
+func penColor(p drawing.Point) color.Color {
+	if p.PenColor == nil {
+		return color.Black
+	}
+	return p.PenColor
+}
@@ -0,0 +1,31 @@
+package rendering
+
+// dashes describes a pen pattern as a run of pixels drawn followed by a run
+// left alone, repeated along the line
+type dashes struct {
+	drawn, skipped int
+}
+
+// solid draws every pixel
+var solid = dashes{drawn: 1}
+
+// penPatterns holds the dashes for each of turtle.PenPatterns
+var penPatterns = map[string]dashes{
+	"solid":  solid,
+	"dashed": {drawn: 6, skipped: 4},
+	"dotted": {drawn: 1, skipped: 3},
+}
+
+// penDashes returns the dashes for a pen pattern, treating unknown or empty
+// patterns as solid
+func penDashes(pattern string) dashes {
+	if d, exists := penPatterns[pattern]; exists {
+		return d
+	}
+	return solid
+}
+
+// on reports whether the pixel step pixels along a line is drawn
+func (d dashes) on(step int) bool {
+	return step%(d.drawn+d.skipped) < d.drawn
+}
@@ -0,0 +1,498 @@
+// Package rendering draws recorded turtle drawings onto images
+package rendering
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/honeylogo/logo/drawing"
+	"github.com/honeylogo/logo/turtle"
+)
+
+// Grid colors
+var (
+	gridColor = color.RGBA{R: 220, G: 220, B: 220, A: 255}
+	axisColor = color.RGBA{R: 128, G: 128, B: 128, A: 255}
+)
+
+// TurtleAdapter reads the state of a turtle for display alongside a
+// rendered drawing
+type TurtleAdapter struct {
+	Turtle *turtle.Turtle
+}
+
+// NewTurtleAdapter creates an adapter for the given turtle
+func NewTurtleAdapter(t *turtle.Turtle) *TurtleAdapter {
+	return &TurtleAdapter{Turtle: t}
+}
+
+// GetTurtleStatus describes the turtle's position, heading and pen, with
+// the pen color written as #RRGGBB
+func (ta *TurtleAdapter) GetTurtleStatus() string {
+	return fmt.Sprintf("X:%.2f Y:%.2f Angle:%.2f PenDown:%t Color:%s",
+		ta.Turtle.GetX(), ta.Turtle.GetY(), ta.Turtle.GetAngle(), ta.Turtle.IsPenDown(), statusColor(ta.Turtle.GetColor()))
+}
+
+// statusColor writes a color as #RRGGBB, ignoring its alpha
+func statusColor(c color.Color) string {
+	if c == nil {
+		c = color.Black
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02X%02X%02X", n.R, n.G, n.B)
+}
+
+// DefaultRenderer renders drawings onto an RGBA image of a fixed size
+type DefaultRenderer struct {
+	Width      int
+	Height     int
+	Background color.Color
+	// ShowGrid draws grid lines every GridSpacing pixels, with axes through
+	// the origin, underneath the drawing
+	ShowGrid    bool
+	GridSpacing int
+	// TrailLength limits RenderDrawing to the most recent segments, or
+	// draws every segment when 0
+	TrailLength int
+	// FadeTrail dims older segments of the trail towards the background
+	FadeTrail bool
+	// TurtleColor draws the turtle in a fixed color, such as one that stands
+	// out from the background, or in the pen color when nil
+	TurtleColor color.Color
+	// DrawTurtleSprite shows the turtle in DrawTurtle and RenderSegment.
+	// Turning it off leaves only the drawing, for finished artwork, and
+	// saves the work of keeping the pixels under the sprite.
+	DrawTurtleSprite bool
+	Options          RendererOptions
+	img              *image.RGBA
+	// coordinates are those of the last rendered drawing, used to place
+	// segments rendered incrementally
+	coordinates drawing.CoordinateSystem
+	sprite      spriteState
+
+	// pauseMutex guards paused and drawn, and resumed wakes RenderDrawing
+	// when rendering is resumed
+	pauseMutex sync.Mutex
+	resumed    *sync.Cond
+	paused     bool
+	drawn      int // Segments drawn so far by the current RenderDrawing
+}
+
+// RendererOptions controls how a renderer draws
+type RendererOptions struct {
+	// Delay is the pause after each segment when Animate is set
+	Delay time.Duration
+	// Animate draws segments one at a time, pausing for Delay after each
+	Animate bool
+	// AntiAlias blends line edges into the background instead of drawing
+	// hard one pixel lines
+	AntiAlias bool
+}
+
+// NewRenderer creates a renderer for a canvas of the given size, drawing
+// without animation or anti-aliasing
+func NewRenderer(width, height int) *DefaultRenderer {
+	return NewRendererWithOptions(width, height, RendererOptions{})
+}
+
+// NewRendererWithOptions creates a renderer for a canvas of the given size
+// that draws according to options
+func NewRendererWithOptions(width, height int, options RendererOptions) *DefaultRenderer {
+	return &DefaultRenderer{
+		Width:            width,
+		Height:           height,
+		Background:       color.White,
+		GridSpacing:      50,
+		Options:          options,
+		DrawTurtleSprite: true,
+		img:              image.NewRGBA(image.Rect(0, 0, width, height)),
+		coordinates:      drawing.DefaultCoordinates,
+	}
+}
+
+// Image returns the image the renderer draws onto
+func (r *DefaultRenderer) Image() *image.RGBA {
+	return r.img
+}
+
+// RenderDrawing clears the canvas and draws every pen-down segment of the
+// drawing and then its discs, positioned according to the drawing's
+// coordinate system
+func (r *DefaultRenderer) RenderDrawing(d *drawing.Drawing) *image.RGBA {
+	draw.Draw(r.img, r.img.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
+	if background := d.Background(); background != nil {
+		draw.Draw(r.img, r.img.Bounds(), background, background.Bounds().Min, draw.Over)
+	}
+	r.coordinates = d.Coordinates()
+	r.sprite = spriteState{}
+	if r.ShowGrid {
+		r.drawGrid(d)
+	}
+
+	r.setDrawn(0)
+	segments := d.Segments()
+	if r.TrailLength > 0 && len(segments) > r.TrailLength {
+		segments = segments[len(segments)-r.TrailLength:]
+	}
+	for i, s := range segments {
+		c := s.Color
+		if r.FadeTrail {
+			c = fade(c, r.Background, float64(i+1)/float64(len(segments)))
+		}
+		r.waitWhilePaused()
+		r.drawSegment(s.StartX, s.StartY, s.EndX, s.EndY, c, s.Pattern)
+		r.setDrawn(i + 1)
+		if r.Options.Animate && r.Options.Delay > 0 {
+			time.Sleep(r.Options.Delay)
+		}
+	}
+	for _, disc := range d.Discs() {
+		c := disc.Color
+		if c == nil {
+			c = color.Black
+		}
+		x, y := r.coordinates.ToCanvas(disc.X, disc.Y, r.Width, r.Height)
+		fillCircle(r.img, x, y, disc.Radius, c, r.Options.AntiAlias)
+	}
+
+	return r.img
+}
+
+// Pause stops RenderDrawing before it draws its next segment, and keeps it
+// waiting until Resume is called. It is meant to freeze an animated render
+// running in another goroutine.
+func (r *DefaultRenderer) Pause() {
+	r.pauseMutex.Lock()
+	defer r.pauseMutex.Unlock()
+	r.paused = true
+}
+
+// Resume lets a paused RenderDrawing carry on
+func (r *DefaultRenderer) Resume() {
+	r.pauseMutex.Lock()
+	defer r.pauseMutex.Unlock()
+	r.paused = false
+	if r.resumed != nil {
+		r.resumed.Broadcast()
+	}
+}
+
+// Paused reports whether rendering is paused
+func (r *DefaultRenderer) Paused() bool {
+	r.pauseMutex.Lock()
+	defer r.pauseMutex.Unlock()
+	return r.paused
+}
+
+// waitWhilePaused blocks until rendering isn't paused
+func (r *DefaultRenderer) waitWhilePaused() {
+	r.pauseMutex.Lock()
+	defer r.pauseMutex.Unlock()
+	for r.paused {
+		if r.resumed == nil {
+			r.resumed = sync.NewCond(&r.pauseMutex)
+		}
+		r.resumed.Wait()
+	}
+}
+
+// setDrawn records how many segments have been drawn
+func (r *DefaultRenderer) setDrawn(n int) {
+	r.pauseMutex.Lock()
+	defer r.pauseMutex.Unlock()
+	r.drawn = n
+}
+
+// drawSegment draws a pen-down segment in the given pen pattern,
+// anti-aliased if the options ask
+func (r *DefaultRenderer) drawSegment(x0, y0, x1, y1 float64, c color.Color, pattern string) {
+	dashes := penDashes(pattern)
+	if r.Options.AntiAlias {
+		cx0, cy0 := r.coordinates.ToCanvas(x0, y0, r.Width, r.Height)
+		cx1, cy1 := r.coordinates.ToCanvas(x1, y1, r.Width, r.Height)
+		drawAntiAliasedLine(r.img, cx0, cy0, cx1, cy1, c, dashes)
+		return
+	}
+	cx0, cy0 := r.toCanvas(r.coordinates, drawing.Point{X: x0, Y: y0})
+	cx1, cy1 := r.toCanvas(r.coordinates, drawing.Point{X: x1, Y: y1})
+	drawLine(r.img, cx0, cy0, cx1, cy1, c, dashes)
+}
+
+// drawGrid draws light grid lines at every GridSpacing pixels from the
+// drawing's origin, then bold axes through the origin itself
+func (r *DefaultRenderer) drawGrid(d *drawing.Drawing) {
+	spacing := r.GridSpacing
+	if spacing <= 0 {
+		return
+	}
+	originX, originY := r.toCanvas(d.Coordinates(), drawing.Point{})
+
+	for x := originX % spacing; x < r.Width; x += spacing {
+		DrawLine(r.img, x, 0, x, r.Height-1, gridColor)
+	}
+	for y := originY % spacing; y < r.Height; y += spacing {
+		DrawLine(r.img, 0, y, r.Width-1, y, gridColor)
+	}
+
+	for offset := -1; offset <= 1; offset++ {
+		DrawLine(r.img, originX+offset, 0, originX+offset, r.Height-1, axisColor)
+		DrawLine(r.img, 0, originY+offset, r.Width-1, originY+offset, axisColor)
+	}
+}
+
+// toCanvas converts a drawing point to the nearest canvas pixel
+func (r *DefaultRenderer) toCanvas(cs drawing.CoordinateSystem, p drawing.Point) (int, int) {
+	x, y := cs.ToCanvas(p.X, p.Y, r.Width, r.Height)
+	return int(math.Round(x)), int(math.Round(y))
+}
+
+// DrawLine draws a one pixel wide line using Bresenham's algorithm
+func DrawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	drawLine(img, x0, y0, x1, y1, c, solid)
+}
+
+// drawLine draws a one pixel wide line with gaps according to dashes. The
+// line is first clipped to the image, so far off endpoints don't cost a walk
+// over pixels that can't be set.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color, d dashes) {
+	startX, startY := x0, y0
+	x0, y0, x1, y1, visible := clipLine(img.Bounds(), x0, y0, x1, y1)
+	if !visible {
+		return
+	}
+	// Keep dashes in step with where the unclipped line would have been
+	skipped := max(abs(x0-startX), abs(y0-startY))
+
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for step := skipped; ; step++ {
+		if d.on(step) {
+			img.Set(x0, y0, c)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// Outcodes used by clipLine to say which sides of the clip rectangle a point
+// lies beyond
+const (
+	outsideLeft = 1 << iota
+	outsideRight
+	outsideTop
+	outsideBottom
+)
+
+// clipLine clips a line to the pixels of bounds using the Cohen-Sutherland
+// algorithm, returning the clipped endpoints and whether any of the line is
+// inside. Lines already inside are returned unchanged.
+func clipLine(bounds image.Rectangle, x0, y0, x1, y1 int) (int, int, int, int, bool) {
+	fx0, fy0, fx1, fy1, visible := clipLineFloat(
+		float64(bounds.Min.X), float64(bounds.Min.Y), float64(bounds.Max.X-1), float64(bounds.Max.Y-1),
+		float64(x0), float64(y0), float64(x1), float64(y1))
+	if !visible {
+		return 0, 0, 0, 0, false
+	}
+	round := func(v float64) int { return int(math.Round(v)) }
+	return round(fx0), round(fy0), round(fx1), round(fy1), true
+}
+
+// clipLineFloat clips a line to the rectangle from (minX, minY) to (maxX,
+// maxY) inclusive using the Cohen-Sutherland algorithm, returning the
+// clipped endpoints, in the same order, and whether any of the line is
+// inside
+func clipLineFloat(minX, minY, maxX, maxY, x0, y0, x1, y1 float64) (float64, float64, float64, float64, bool) {
+	outcode := func(x, y float64) int {
+		code := 0
+		if x < minX {
+			code |= outsideLeft
+		} else if x > maxX {
+			code |= outsideRight
+		}
+		if y < minY {
+			code |= outsideTop
+		} else if y > maxY {
+			code |= outsideBottom
+		}
+		return code
+	}
+
+	code0, code1 := outcode(x0, y0), outcode(x1, y1)
+	for code0 != 0 || code1 != 0 {
+		if code0&code1 != 0 {
+			return 0, 0, 0, 0, false
+		}
+
+		// Move an outside endpoint onto the edge it lies beyond
+		code := code0
+		if code == 0 {
+			code = code1
+		}
+		var x, y float64
+		switch {
+		case code&outsideTop != 0:
+			x, y = x0+(x1-x0)*(minY-y0)/(y1-y0), minY
+		case code&outsideBottom != 0:
+			x, y = x0+(x1-x0)*(maxY-y0)/(y1-y0), maxY
+		case code&outsideLeft != 0:
+			x, y = minX, y0+(y1-y0)*(minX-x0)/(x1-x0)
+		default:
+			x, y = maxX, y0+(y1-y0)*(maxX-x0)/(x1-x0)
+		}
+		if code == code0 {
+			x0, y0 = x, y
+			code0 = outcode(x0, y0)
+		} else {
+			x1, y1 = x, y
+			code1 = outcode(x1, y1)
+		}
+	}
+	return x0, y0, x1, y1, true
+}
+
+// DrawAntiAliasedLine draws a line using Xiaolin Wu's algorithm, blending
+// each pixel into the image by how much of it the line covers
+func DrawAntiAliasedLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.Color) {
+	drawAntiAliasedLine(img, x0, y0, x1, y1, c, solid)
+}
+
+// drawAntiAliasedLine draws an anti-aliased line with gaps according to
+// dashes. As with drawLine, only the part of the line that can touch the
+// image is walked.
+func drawAntiAliasedLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.Color, d dashes) {
+	steep := math.Abs(y1-y0) > math.Abs(x1-x0)
+	if steep {
+		x0, y0 = y0, x0
+		x1, y1 = y1, x1
+	}
+	if x0 > x1 {
+		x0, x1 = x1, x0
+		y0, y1 = y1, y0
+	}
+
+	// Clip in the same swapped coordinates, with a pixel to spare around
+	// the image for the partly covered pixels beside the line
+	b := img.Bounds()
+	minX, minY := float64(b.Min.X)-1, float64(b.Min.Y)-1
+	maxX, maxY := float64(b.Max.X), float64(b.Max.Y)
+	if steep {
+		minX, minY = minY, minX
+		maxX, maxY = maxY, maxX
+	}
+	clippedX0, _, clippedX1, _, visible := clipLineFloat(minX, minY, maxX, maxY, x0, y0, x1, y1)
+	if !visible {
+		return
+	}
+
+	plot := func(x, y int, coverage float64) {
+		if steep {
+			x, y = y, x
+		}
+		blend(img, x, y, c, coverage)
+	}
+
+	gradient := 1.0
+	if dx := x1 - x0; dx != 0 {
+		gradient = (y1 - y0) / dx
+	}
+
+	// Dashes are kept in step with where the unclipped line starts
+	first := int(math.Round(x0))
+	start, end := int(math.Round(clippedX0)), int(math.Round(clippedX1))
+	y := y0 + gradient*(float64(start)-x0)
+	for x := start; x <= end; x++ {
+		if d.on(x - first) {
+			base := math.Floor(y)
+			fraction := y - base
+			plot(x, int(base), 1-fraction)
+			plot(x, int(base)+1, fraction)
+		}
+		y += gradient
+	}
+}
+
+// FillCircle fills the pixels whose centres lie within radius of (cx, cy)
+func FillCircle(img *image.RGBA, cx, cy, radius float64, c color.Color) {
+	fillCircle(img, cx, cy, radius, c, false)
+}
+
+// fillCircle fills a circle, shading the pixels its edge crosses by how
+// much of them it covers when antiAlias is set
+func fillCircle(img *image.RGBA, cx, cy, radius float64, c color.Color, antiAlias bool) {
+	bounds := image.Rect(
+		int(math.Floor(cx-radius-1)), int(math.Floor(cy-radius-1)),
+		int(math.Ceil(cx+radius+1))+1, int(math.Ceil(cy+radius+1))+1,
+	).Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			distance := math.Hypot(float64(x)-cx, float64(y)-cy)
+			if antiAlias {
+				blend(img, x, y, c, math.Min(1, radius+0.5-distance))
+			} else if distance <= radius {
+				blend(img, x, y, c, 1)
+			}
+		}
+	}
+}
+
+// fade mixes c with background, keeping the given fraction of c
+func fade(c, background color.Color, fraction float64) color.Color {
+	if c == nil {
+		c = color.Black
+	}
+	src := color.RGBAModel.Convert(c).(color.RGBA)
+	dst := color.RGBAModel.Convert(background).(color.RGBA)
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round(float64(s)*fraction + float64(d)*(1-fraction)))
+	}
+	return color.RGBA{R: mix(src.R, dst.R), G: mix(src.G, dst.G), B: mix(src.B, dst.B), A: mix(src.A, dst.A)}
+}
+
+// blend mixes c into the pixel at (x, y) in proportion to coverage
+func blend(img *image.RGBA, x, y int, c color.Color, coverage float64) {
+	if !(image.Point{X: x, Y: y}.In(img.Bounds())) || coverage <= 0 {
+		return
+	}
+	src := color.RGBAModel.Convert(c).(color.RGBA)
+	dst := img.RGBAAt(x, y)
+	mix := func(s, d uint8) uint8 {
+		return uint8(math.Round(float64(s)*coverage + float64(d)*(1-coverage)))
+	}
+	img.SetRGBA(x, y, color.RGBA{
+		R: mix(src.R, dst.R),
+		G: mix(src.G, dst.G),
+		B: mix(src.B, dst.B),
+		A: mix(src.A, dst.A),
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
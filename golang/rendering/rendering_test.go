@@ -0,0 +1,526 @@
+package rendering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/honeylogo/logo/drawing"
+	"github.com/honeylogo/logo/turtle"
+	"github.com/stretchr/testify/assert"
+)
+
+var black = color.RGBA{A: 255}
+var white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+func TestOriginRendersAtCenter(t *testing.T) {
+	d := drawing.NewDrawing()
+	d.Add(0, 10)
+
+	img := NewRenderer(100, 80).RenderDrawing(d)
+	assert.Equal(t, black, img.RGBAAt(50, 40))
+	// +Y points up, so the line runs towards the top of the canvas
+	assert.Equal(t, black, img.RGBAAt(50, 30))
+	assert.Equal(t, white, img.RGBAAt(50, 50))
+}
+
+func TestCustomCoordinates(t *testing.T) {
+	d := drawing.NewDrawing()
+	d.SetCoordinates(drawing.CoordinateSystem{Origin: drawing.OriginTopLeft})
+	d.Add(10, 10)
+
+	img := NewRenderer(100, 80).RenderDrawing(d)
+	assert.Equal(t, black, img.RGBAAt(0, 0))
+	assert.Equal(t, black, img.RGBAAt(10, 10))
+	assert.Equal(t, white, img.RGBAAt(50, 40))
+}
+
+func TestPenUpSegmentsNotDrawn(t *testing.T) {
+	d := drawing.NewDrawing()
+	d.SetPenDown(false)
+	d.Add(20, 0)
+
+	img := NewRenderer(100, 80).RenderDrawing(d)
+	assert.Equal(t, white, img.RGBAAt(60, 40))
+}
+
+func TestGrid(t *testing.T) {
+	r := NewRenderer(200, 100)
+	r.ShowGrid = true
+	r.GridSpacing = 20
+	img := r.RenderDrawing(drawing.NewDrawing())
+
+	// Grid lines every 20 pixels from the centre
+	for _, x := range []int{20, 40, 60, 80, 120, 140, 160, 180} {
+		assert.Equal(t, gridColor, img.RGBAAt(x, 5), "x=%d", x)
+	}
+	for _, y := range []int{10, 30, 70, 90} {
+		assert.Equal(t, gridColor, img.RGBAAt(5, y), "y=%d", y)
+	}
+	assert.Equal(t, white, img.RGBAAt(25, 5))
+	assert.Equal(t, white, img.RGBAAt(25, 35))
+
+	// Bold axes through the centre
+	for _, x := range []int{99, 100, 101} {
+		assert.Equal(t, axisColor, img.RGBAAt(x, 5))
+	}
+	for _, y := range []int{49, 50, 51} {
+		assert.Equal(t, axisColor, img.RGBAAt(5, y))
+	}
+}
+
+func TestRenderDisc(t *testing.T) {
+	d := drawing.NewDrawing()
+	red := color.RGBA{R: 255, A: 255}
+	d.AddDisc(drawing.Disc{X: 20, Y: 10, Radius: 5, Color: red})
+
+	img := NewRenderer(100, 80).RenderDrawing(d)
+	// The disc is centred at (70,30) on the canvas, and filled to its edge
+	for y := 25; y <= 35; y++ {
+		for x := 65; x <= 75; x++ {
+			inside := math.Hypot(float64(x-70), float64(y-30)) <= 4
+			outside := math.Hypot(float64(x-70), float64(y-30)) >= 6
+			if inside {
+				assert.Equal(t, red, img.RGBAAt(x, y), "pixel %d,%d", x, y)
+			} else if outside {
+				assert.Equal(t, white, img.RGBAAt(x, y), "pixel %d,%d", x, y)
+			}
+		}
+	}
+	assert.Equal(t, white, img.RGBAAt(50, 40))
+}
+
+func TestGridUnderDrawing(t *testing.T) {
+	d := drawing.NewDrawing()
+	d.Add(60, 0)
+
+	r := NewRenderer(200, 100)
+	r.ShowGrid = true
+	r.GridSpacing = 20
+	img := r.RenderDrawing(d)
+
+	// The path along the x axis and across a grid line is not overwritten
+	assert.Equal(t, black, img.RGBAAt(120, 50))
+	assert.Equal(t, black, img.RGBAAt(130, 50))
+	assert.Equal(t, axisColor, img.RGBAAt(130, 49))
+}
+
+func TestDrawTurtleShapes(t *testing.T) {
+	d := drawing.NewDrawing()
+	tur := turtle.NewHeadless()
+	r := NewRenderer(100, 80)
+
+	assert.NoError(t, tur.SetShape("triangle"))
+	r.RenderDrawing(d)
+	r.DrawTurtle(d, tur)
+	// The triangle's tip is ahead of the turtle and its centre is hollow
+	assert.Equal(t, black, r.Image().RGBAAt(50, 30))
+	assert.Equal(t, white, r.Image().RGBAAt(50, 40))
+
+	assert.NoError(t, tur.SetShape("arrow"))
+	r.RenderDrawing(d)
+	r.DrawTurtle(d, tur)
+	assert.Equal(t, black, r.Image().RGBAAt(50, 30))
+	assert.Equal(t, black, r.Image().RGBAAt(50, 40))
+
+	assert.NoError(t, tur.SetShape("blank"))
+	r.RenderDrawing(d)
+	r.DrawTurtle(d, tur)
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			assert.Equal(t, white, r.Image().RGBAAt(x, y))
+		}
+	}
+}
+
+func TestDrawTurtleColor(t *testing.T) {
+	d := drawing.NewDrawing()
+	tur := turtle.NewHeadless()
+	assert.NoError(t, tur.SetShape("triangle"))
+	red := color.RGBA{R: 255, A: 255}
+	tur.SetPenColor(red)
+
+	r := NewRenderer(100, 80)
+	r.RenderDrawing(d)
+	r.DrawTurtle(d, tur)
+	assert.Equal(t, red, r.Image().RGBAAt(50, 30))
+
+	// A fixed turtle color takes the place of the pen color, for the
+	// incremental sprite too
+	green := color.RGBA{G: 255, A: 255}
+	r.TurtleColor = green
+	r.RenderDrawing(d)
+	r.DrawTurtle(d, tur)
+	assert.Equal(t, green, r.Image().RGBAAt(50, 30))
+
+	r.RenderDrawing(d)
+	r.RenderSegment(drawing.Point{}, drawing.Point{Y: 10, PenColor: red, PenSize: 1})
+	assert.Equal(t, green, r.Image().RGBAAt(50, 20))
+}
+
+func TestNoTurtleSprite(t *testing.T) {
+	d := drawing.NewDrawing()
+	tur := turtle.NewHeadless()
+	assert.NoError(t, tur.SetShape("triangle"))
+
+	r := NewRenderer(100, 80)
+	r.DrawTurtleSprite = false
+	r.RenderDrawing(d)
+	r.DrawTurtle(d, tur)
+	r.RenderSegment(drawing.Point{}, drawing.Point{X: 20, PenDown: true, PenColor: color.Black, PenSize: 1})
+
+	// Only the segment is drawn
+	assert.Equal(t, black, r.Image().RGBAAt(60, 40))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			if y != 40 || x < 50 || x > 70 {
+				assert.Equal(t, white, r.Image().RGBAAt(x, y), "pixel %d,%d", x, y)
+			}
+		}
+	}
+}
+
+func TestTurtleAdapter(t *testing.T) {
+	tur := turtle.NewHeadless()
+	tur.Forward(20)
+	tur.Right(90)
+	tur.Forward(10)
+	tur.PenUp()
+
+	adapter := NewTurtleAdapter(tur)
+	assert.Equal(t, "X:10.00 Y:20.00 Angle:0.00 PenDown:false Color:#000000", adapter.GetTurtleStatus())
+
+	tur.SetPenColor(color.RGBA{R: 255, A: 255})
+	assert.Contains(t, adapter.GetTurtleStatus(), "Color:#FF0000")
+}
+
+// zigzag returns a drawing of n short segments
+func zigzag(n int) *drawing.Drawing {
+	d := drawing.NewDrawing()
+	for i := 1; i <= n; i++ {
+		d.Add(float64(i*2), float64(i%2*5))
+	}
+	return d
+}
+
+func TestRendererAnimation(t *testing.T) {
+	d := zigzag(10)
+	delay := 5 * time.Millisecond
+
+	still := NewRendererWithOptions(100, 80, RendererOptions{Delay: delay, Animate: false})
+	start := time.Now()
+	still.RenderDrawing(d)
+	assert.Less(t, time.Since(start), 10*delay)
+
+	animated := NewRendererWithOptions(100, 80, RendererOptions{Delay: delay, Animate: true})
+	start = time.Now()
+	animated.RenderDrawing(d)
+	assert.GreaterOrEqual(t, time.Since(start), 10*delay)
+
+	// Both draw the same picture
+	assert.Equal(t, still.Image().Pix, animated.Image().Pix)
+	assert.Equal(t, RendererOptions{}, NewRenderer(100, 80).Options)
+}
+
+func TestRendererAntiAlias(t *testing.T) {
+	d := drawing.NewDrawing()
+	d.Add(20, 7)
+
+	img := NewRendererWithOptions(100, 80, RendererOptions{AntiAlias: true}).RenderDrawing(d)
+	partial := 0
+	for x := 50; x <= 70; x++ {
+		for y := 25; y <= 45; y++ {
+			if c := img.RGBAAt(x, y); c != white && c != black {
+				partial++
+			}
+		}
+	}
+	assert.Greater(t, partial, 0)
+
+	img = NewRenderer(100, 80).RenderDrawing(d)
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 80; y++ {
+			if c := img.RGBAAt(x, y); c != white && c != black {
+				t.Fatalf("pixel %d,%d is %v without anti-aliasing", x, y, c)
+			}
+		}
+	}
+}
+
+// squarePath returns a drawing of a square spiral with the given number of
+// segments, lifting the pen for every fifth one
+func squarePath(segments int) *drawing.Drawing {
+	d := drawing.NewDrawing()
+	x, y := 0.0, 0.0
+	for i := 1; i <= segments; i++ {
+		length := float64(i%40 + 5)
+		switch i % 4 {
+		case 0:
+			x += length
+		case 1:
+			y += length
+		case 2:
+			x -= length
+		case 3:
+			y -= length
+		}
+		d.SetPenDown(i%5 != 0)
+		d.Add(x, y)
+	}
+	return d
+}
+
+func TestRenderSegment(t *testing.T) {
+	d := squarePath(12)
+	points := d.Points()
+
+	incremental := NewRenderer(200, 200)
+	incremental.RenderDrawing(drawing.NewDrawing())
+	for i := 1; i < len(points); i++ {
+		incremental.RenderSegment(points[i-1], points[i])
+	}
+
+	// The result matches a full redraw with the sprite at the last point,
+	// so every earlier sprite was erased
+	full := NewRenderer(200, 200)
+	full.RenderDrawing(d)
+	last, previous := points[len(points)-1], points[len(points)-2]
+	angle := math.Atan2(last.Y-previous.Y, last.X-previous.X)
+	full.drawTriangle(d.Coordinates(), last.X, last.Y, angle, black)
+
+	assert.Equal(t, full.Image().Pix, incremental.Image().Pix)
+}
+
+func BenchmarkFullRedraw(b *testing.B) {
+	d := squarePath(1000)
+	r := NewRenderer(400, 400)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.RenderDrawing(d)
+	}
+}
+
+func BenchmarkRenderSegment(b *testing.B) {
+	d := squarePath(1000)
+	points := d.Points()
+	r := NewRenderer(400, 400)
+	r.RenderDrawing(drawing.NewDrawing())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i%(len(points)-1) + 1
+		r.RenderSegment(points[j-1], points[j])
+	}
+}
+
+func TestRenderSegmentLeavesNoSpriteTrail(t *testing.T) {
+	r := NewRenderer(200, 200)
+	r.RenderDrawing(drawing.NewDrawing())
+
+	// With the pen up only the sprite is drawn, at awkward angles and
+	// fractional positions
+	points := []drawing.Point{{}}
+	x, y := 0.0, 0.0
+	for i := 1; i <= 20; i++ {
+		x += 7.3 * math.Cos(float64(i)*0.7)
+		y += 5.9 * math.Sin(float64(i)*1.3)
+		points = append(points, drawing.Point{X: x, Y: y, PenSize: 3})
+	}
+	for i := 1; i < len(points); i++ {
+		r.RenderSegment(points[i-1], points[i])
+	}
+
+	last := points[len(points)-1]
+	bounds := r.spriteBounds(last, r.sprite.angle)
+	img := r.Image()
+	for py := 0; py < r.Height; py++ {
+		for px := 0; px < r.Width; px++ {
+			if !(image.Point{X: px, Y: py}.In(bounds)) {
+				assert.Equal(t, white, img.RGBAAt(px, py), "stray pixel at %d, %d", px, py)
+			}
+		}
+	}
+	assert.Contains(t, img.Pix, uint8(0), "the final sprite is drawn")
+}
+
+func TestPenPatterns(t *testing.T) {
+	// gaps counts the background pixels along a horizontal line drawn in
+	// the given pattern, with and without anti-aliasing
+	gaps := func(pattern string, antiAlias bool) int {
+		d := drawing.NewDrawing()
+		d.AddPoint(drawing.Point{X: 40, PenDown: true, PenColor: color.Black, PenSize: 1, PenPattern: pattern})
+		img := NewRendererWithOptions(100, 80, RendererOptions{AntiAlias: antiAlias}).RenderDrawing(d)
+		count := 0
+		for x := 50; x <= 90; x++ {
+			if img.RGBAAt(x, 40) == white {
+				count++
+			}
+		}
+		return count
+	}
+
+	for _, antiAlias := range []bool{false, true} {
+		assert.Zero(t, gaps("solid", antiAlias))
+		assert.Zero(t, gaps("", antiAlias))
+		assert.Equal(t, 16, gaps("dashed", antiAlias))
+		assert.Equal(t, 30, gaps("dotted", antiAlias))
+	}
+}
+
+func TestBackground(t *testing.T) {
+	background := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	red := color.RGBA{R: 255, A: 255}
+	draw.Draw(background, background.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+
+	d := drawing.NewDrawing()
+	d.SetBackground(background)
+	d.Add(0, 10)
+
+	img := NewRenderer(100, 80).RenderDrawing(d)
+	assert.Equal(t, red, img.RGBAAt(5, 5))
+	assert.Equal(t, white, img.RGBAAt(20, 20))
+	assert.Equal(t, black, img.RGBAAt(50, 35))
+}
+
+func TestDrawLineClipsToImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+
+	start := time.Now()
+	DrawLine(img, 50, 40, 1_000_000_000, 40, black)
+	DrawLine(img, -1_000_000_000, -1_000_000_000, 1_000_000_000, 1_000_000_000, black)
+	DrawLine(img, -500, 500, -400, 600, black)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// The visible parts are drawn right up to the edges
+	assert.Equal(t, black, img.RGBAAt(50, 40))
+	assert.Equal(t, black, img.RGBAAt(99, 40))
+	assert.Equal(t, black, img.RGBAAt(0, 0))
+	assert.Equal(t, black, img.RGBAAt(79, 79))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(49, 40))
+
+	// A line wholly inside is unchanged by clipping
+	x0, y0, x1, y1, visible := clipLine(img.Bounds(), 3, 4, 90, 70)
+	assert.True(t, visible)
+	assert.Equal(t, []int{3, 4, 90, 70}, []int{x0, y0, x1, y1})
+
+	_, _, _, _, visible = clipLine(img.Bounds(), -10, -10, -5, 200)
+	assert.False(t, visible)
+
+	// Anti-aliased lines are clipped too, and draw the same pixels as a
+	// line ending just past the image
+	far := image.NewRGBA(img.Bounds())
+	near := image.NewRGBA(img.Bounds())
+	start = time.Now()
+	DrawAntiAliasedLine(far, 50, 40.3, 1_000_000_000, 40.3, black)
+	DrawAntiAliasedLine(far, -1_000_000_000, -250_000_000, 1_000_000_000, 250_000_000, black)
+	DrawAntiAliasedLine(far, -500, 500, -400, 600, black)
+	steep := image.NewRGBA(img.Bounds())
+	DrawAntiAliasedLine(steep, 30, 2_000_000_000, 30, -2_000_000_000, black)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	DrawAntiAliasedLine(near, 50, 40.3, 200, 40.3, black)
+	DrawAntiAliasedLine(near, -400, -100, 400, 100, black)
+	assert.Equal(t, near.Pix, far.Pix)
+	assert.NotEqual(t, color.RGBA{}, far.RGBAAt(99, 40))
+	assert.Equal(t, black, steep.RGBAAt(30, 0))
+	assert.Equal(t, black, steep.RGBAAt(30, 79))
+}
+
+func TestTrailLength(t *testing.T) {
+	// Ten horizontal segments, one above the other
+	d := drawing.NewDrawing()
+	for i := 0; i < 10; i++ {
+		d.SetPenDown(false)
+		d.Add(-20, float64(i*5))
+		d.SetPenDown(true)
+		d.Add(20, float64(i*5))
+	}
+
+	r := NewRenderer(100, 100)
+	r.TrailLength = 3
+	img := r.RenderDrawing(d)
+	for i := 0; i < 10; i++ {
+		expected := white
+		if i >= 7 {
+			expected = black
+		}
+		assert.Equal(t, expected, img.RGBAAt(50, 50-i*5), "segment %d", i)
+	}
+
+	// Fading dims the older segments of the trail, keeping the newest
+	r.FadeTrail = true
+	img = r.RenderDrawing(d)
+	assert.Equal(t, color.RGBA{R: 170, G: 170, B: 170, A: 255}, img.RGBAAt(50, 15))
+	assert.Equal(t, color.RGBA{R: 85, G: 85, B: 85, A: 255}, img.RGBAAt(50, 10))
+	assert.Equal(t, black, img.RGBAAt(50, 5))
+	assert.Equal(t, white, img.RGBAAt(50, 20))
+}
+
+func TestPauseResume(t *testing.T) {
+	d := drawing.NewDrawing()
+	for i := 1; i <= 40; i++ {
+		d.Add(float64(i), 0)
+	}
+	r := NewRendererWithOptions(100, 80, RendererOptions{Animate: true, Delay: time.Millisecond})
+	drawn := func() int {
+		r.pauseMutex.Lock()
+		defer r.pauseMutex.Unlock()
+		return r.drawn
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.RenderDrawing(d)
+	}()
+
+	assert.Eventually(t, func() bool { return drawn() > 0 }, time.Second, time.Millisecond)
+	r.Pause()
+	assert.True(t, r.Paused())
+	// Let a segment in progress finish, then check nothing more is drawn
+	time.Sleep(10 * time.Millisecond)
+	stopped := drawn()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, drawn())
+	assert.Less(t, stopped, 40)
+
+	r.Resume()
+	assert.False(t, r.Paused())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rendering didn't finish after resuming")
+	}
+	assert.Equal(t, 40, drawn())
+}
+
+func TestSavePNGSplitPath(t *testing.T) {
+	// A move that leaves the right edge and carries on from the left one,
+	// recorded as a pen-up jump between the two pieces
+	d := drawing.NewDrawing()
+	d.Reset(40, 0)
+	d.Add(50, 0)
+	d.SetPenDown(false)
+	d.Add(-50, 0)
+	d.SetPenDown(true)
+	d.Add(-40, 0)
+	assert.Len(t, d.Segments(), 2)
+
+	path := filepath.Join(t.TempDir(), "split.png")
+	assert.NoError(t, SavePNG(d, path, 100, 80))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	img, err := png.Decode(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, black, color.RGBAModel.Convert(img.At(95, 40)))
+	assert.Equal(t, black, color.RGBAModel.Convert(img.At(5, 40)))
+	// Nothing is drawn across the gap the jump leaves
+	assert.Equal(t, white, color.RGBAModel.Convert(img.At(50, 40)))
+}
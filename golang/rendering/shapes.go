@@ -0,0 +1,110 @@
+package rendering
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/honeylogo/logo/drawing"
+	"github.com/honeylogo/logo/turtle"
+)
+
+// turtleSize is the distance, in pixels, from the turtle's position to the
+// tip of its shape
+const turtleSize = 10
+
+// DrawTurtle draws the turtle in its current shape and position on top of
+// the last rendered drawing, in its pen color unless TurtleColor is set. It
+// draws nothing when DrawTurtleSprite is off.
+func (r *DefaultRenderer) DrawTurtle(d *drawing.Drawing, t *turtle.Turtle) {
+	if !r.DrawTurtleSprite {
+		return
+	}
+	x, y := t.GetPosition()
+	angle := float64(t.GetAngle()) * math.Pi / 180
+	c := r.turtleColor(t.GetColor())
+	cs := d.Coordinates()
+
+	switch t.Shape() {
+	case "triangle":
+		r.drawTriangle(cs, float64(x), float64(y), angle, c)
+	case "arrow":
+		r.drawArrow(cs, float64(x), float64(y), angle, c)
+	case "circle":
+		r.drawCircle(cs, float64(x), float64(y), turtleSize*0.6, c)
+	case "turtle":
+		r.drawTurtleShape(cs, float64(x), float64(y), angle, c)
+	}
+}
+
+// turtleColor returns the color to draw the turtle in when its pen is the
+// given color
+func (r *DefaultRenderer) turtleColor(pen color.Color) color.Color {
+	if r.TurtleColor != nil {
+		return r.TurtleColor
+	}
+	if pen == nil {
+		return color.Black
+	}
+	return pen
+}
+
+// drawTriangle draws a triangle pointing along the heading
+func (r *DefaultRenderer) drawTriangle(cs drawing.CoordinateSystem, x, y, angle float64, c color.Color) {
+	corners := triangleCorners(x, y, angle)
+	for i, corner := range corners {
+		next := corners[(i+1)%len(corners)]
+		r.line(cs, corner.X, corner.Y, next.X, next.Y, c)
+	}
+}
+
+// triangleCorners returns the tip and back corners of the triangle drawn at
+// (x, y) pointing along angle
+func triangleCorners(x, y, angle float64) [3]drawing.Point {
+	tipX, tipY := offset(x, y, angle, turtleSize)
+	leftX, leftY := offset(x, y, angle+3*math.Pi/4, turtleSize*0.6)
+	rightX, rightY := offset(x, y, angle-3*math.Pi/4, turtleSize*0.6)
+	return [3]drawing.Point{{X: tipX, Y: tipY}, {X: leftX, Y: leftY}, {X: rightX, Y: rightY}}
+}
+
+// drawArrow draws a shaft through the turtle's position with a head at the
+// leading end
+func (r *DefaultRenderer) drawArrow(cs drawing.CoordinateSystem, x, y, angle float64, c color.Color) {
+	tipX, tipY := offset(x, y, angle, turtleSize)
+	tailX, tailY := offset(x, y, angle+math.Pi, turtleSize)
+	r.line(cs, tailX, tailY, tipX, tipY, c)
+	for _, side := range []float64{-1, 1} {
+		barbX, barbY := offset(tipX, tipY, angle+side*5*math.Pi/6, turtleSize/2)
+		r.line(cs, tipX, tipY, barbX, barbY, c)
+	}
+}
+
+// drawTurtleShape draws a round body with a small head along the heading
+func (r *DefaultRenderer) drawTurtleShape(cs drawing.CoordinateSystem, x, y, angle float64, c color.Color) {
+	r.drawCircle(cs, x, y, turtleSize*0.6, c)
+	headX, headY := offset(x, y, angle, turtleSize*0.8)
+	r.drawCircle(cs, headX, headY, turtleSize*0.2, c)
+}
+
+// drawCircle approximates a circle with short lines
+func (r *DefaultRenderer) drawCircle(cs drawing.CoordinateSystem, x, y, radius float64, c color.Color) {
+	const steps = 36
+	prevX, prevY := offset(x, y, 0, radius)
+	for i := 1; i <= steps; i++ {
+		nextX, nextY := offset(x, y, 2*math.Pi*float64(i)/steps, radius)
+		r.line(cs, prevX, prevY, nextX, nextY, c)
+		prevX, prevY = nextX, nextY
+	}
+}
+
+// line draws a line between two points in the given coordinates
+func (r *DefaultRenderer) line(cs drawing.CoordinateSystem, x0, y0, x1, y1 float64, c color.Color) {
+	cx0, cy0 := r.toCanvas(cs, drawing.Point{X: x0, Y: y0})
+	cx1, cy1 := r.toCanvas(cs, drawing.Point{X: x1, Y: y1})
+	DrawLine(r.img, cx0, cy0, cx1, cy1, c)
+}
+
+// offset returns the point distance away from (x, y) in the direction of
+// angle, measured in radians counterclockwise from +X
+func offset(x, y, angle, distance float64) (float64, float64) {
+	return x + distance*math.Cos(angle), y + distance*math.Sin(angle)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/http"
+	"time"
+
+	"github.com/honeylogo/logo/drawing"
+)
+
+// Segment is a line drawn by a program, as SegmentsHandler encodes it.
+// Coordinates are those of the drawing, with y increasing upwards.
+type Segment struct {
+	X1      float64 `json:"x1"`
+	Y1      float64 `json:"y1"`
+	X2      float64 `json:"x2"`
+	Y2      float64 `json:"y2"`
+	Color   string  `json:"color"` // As #rrggbb
+	Width   float64 `json:"width"`
+	Pattern string  `json:"pattern,omitempty"`
+	Turtle  int     `json:"turtle"`
+}
+
+// SegmentsResponse is the JSON body SegmentsHandler responds with
+type SegmentsResponse struct {
+	Segments []Segment `json:"segments"`
+}
+
+// SegmentsHandler runs the Logo program posted in a request body like
+// LogoHandler, but responds with the lines it drew as JSON, so a browser can
+// draw them itself
+type SegmentsHandler struct {
+	Timeout time.Duration // How long a program may run
+}
+
+// NewSegmentsHandler creates a SegmentsHandler giving each program five
+// seconds to run
+func NewSegmentsHandler() *SegmentsHandler {
+	return &SegmentsHandler{Timeout: 5 * time.Second}
+}
+
+// ServeHTTP runs the posted program and writes its segments
+func (h *SegmentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d, ok := runPosted(w, r, h.Timeout)
+	if !ok {
+		return
+	}
+
+	response := SegmentsResponse{Segments: []Segment{}}
+	for _, s := range d.Segments() {
+		response.Segments = append(response.Segments, newSegment(s))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// newSegment converts a drawing segment to its JSON form
+func newSegment(s drawing.Segment) Segment {
+	return Segment{
+		X1:      s.StartX,
+		Y1:      s.StartY,
+		X2:      s.EndX,
+		Y2:      s.EndY,
+		Color:   hexColor(s.Color),
+		Width:   s.Width,
+		Pattern: s.Pattern,
+		Turtle:  s.Turtle,
+	}
+}
+
+// hexColor writes a color as #rrggbb, ignoring its transparency
+func hexColor(c color.Color) string {
+	if c == nil {
+		c = color.Black
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentsHandlerSquare(t *testing.T) {
+	rec := post(NewSegmentsHandler(), `setpc "red repeat 4 [ fd 40 rt 90 ]`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var response SegmentsResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Segments, 4)
+	corners := [][2]float64{{0, 0}, {0, 40}, {40, 40}, {40, 0}, {0, 0}}
+	for i, s := range response.Segments {
+		assert.InDelta(t, corners[i][0], s.X1, 1e-4)
+		assert.InDelta(t, corners[i][1], s.Y1, 1e-4)
+		assert.InDelta(t, corners[i+1][0], s.X2, 1e-4)
+		assert.InDelta(t, corners[i+1][1], s.Y2, 1e-4)
+		assert.Equal(t, "#ff0000", s.Color)
+		assert.Equal(t, 1.0, s.Width)
+	}
+}
+
+func TestSegmentsHandlerParseError(t *testing.T) {
+	rec := post(NewSegmentsHandler(), "fd")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,111 @@
+// Package server serves Logo drawings over HTTP
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/drawing"
+	"github.com/honeylogo/logo/parser"
+	"github.com/honeylogo/logo/rendering"
+	"github.com/honeylogo/logo/turtle"
+)
+
+// MaxProgramSize is the largest request body LogoHandler accepts, in bytes
+const MaxProgramSize = 1 << 20
+
+// LogoHandler runs the Logo program posted in a request body without a
+// window and responds with the drawing as a PNG image. Programs that don't
+// parse get a 400 response carrying the parse error, and programs that fail
+// or run longer than Timeout get a 422 response.
+type LogoHandler struct {
+	Width, Height int           // Size of the rendered image
+	Timeout       time.Duration // How long a program may run
+}
+
+// NewLogoHandler creates a LogoHandler rendering pictures the size SAVEPICT
+// saves, giving each program five seconds to run
+func NewLogoHandler() *LogoHandler {
+	return &LogoHandler{
+		Width:   ast.PictureWidth,
+		Height:  ast.PictureHeight,
+		Timeout: 5 * time.Second,
+	}
+}
+
+// ServeHTTP runs the posted program and writes the rendered PNG
+func (h *LogoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d, ok := runPosted(w, r, h.Timeout)
+	if !ok {
+		return
+	}
+
+	renderer := rendering.NewRenderer(h.Width, h.Height)
+	renderer.RenderDrawing(d)
+	var png bytes.Buffer
+	if err := renderer.WritePNG(&png); err != nil {
+		http.Error(w, fmt.Sprintf("encoding PNG: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png.Bytes())
+}
+
+// runPosted runs the program in a request body, stopping it after timeout,
+// and returns its drawing. If the program can't be run it writes the error
+// response and returns false.
+func runPosted(w http.ResponseWriter, r *http.Request, timeout time.Duration) (*drawing.Drawing, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "programs must be posted", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	source, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxProgramSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading program: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	program, err := parser.ParseProgram(string(source))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err := checkProgram(program); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	logo := ast.NewContext(turtle.NewHeadless())
+	logo.Output = io.Discard
+	logo.SkipWaits = true
+	logo.Done = ctx.Done()
+	if err := program.Execute(logo); err != nil {
+		if errors.Is(err, ast.ErrStopped) {
+			err = fmt.Errorf("program did not finish within %s", timeout)
+		}
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return nil, false
+	}
+	return logo.Turtles.Drawing(), true
+}
+
+// checkProgram rejects programs that would read or write files on the
+// server
+func checkProgram(program *ast.Program) error {
+	return ast.Walk(program, func(cmd ast.Command) error {
+		switch cmd.(type) {
+		case *ast.SavePictCommand, *ast.LoadPictCommand:
+			return fmt.Errorf("%s is not available on the server", cmd.String())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func post(h http.Handler, program string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(program)))
+	return rec
+}
+
+func TestLogoHandlerRendersPNG(t *testing.T) {
+	h := NewLogoHandler()
+	h.Width, h.Height = 200, 100
+
+	rec := post(h, "repeat 4 [ fd 40 rt 90 ]")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	img, err := png.Decode(rec.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, img.Bounds().Dx())
+	assert.Equal(t, 100, img.Bounds().Dy())
+}
+
+func TestLogoHandlerParseError(t *testing.T) {
+	rec := post(NewLogoHandler(), "repeat 4 [ fd 40")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unclosed [")
+}
+
+func TestLogoHandlerRejects(t *testing.T) {
+	rec := post(NewLogoHandler(), `savepict "out.png`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	NewLogoHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestLogoHandlerTimeout(t *testing.T) {
+	h := NewLogoHandler()
+	h.Timeout = 20 * time.Millisecond
+
+	rec := post(h, "while 1 [ fd 1 rt 1 ]")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "did not finish within 20ms")
+}
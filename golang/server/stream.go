@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/honeylogo/logo/ast"
+	"github.com/honeylogo/logo/drawing"
+	"github.com/honeylogo/logo/interpreter"
+	"github.com/honeylogo/logo/parser"
+)
+
+// TurtleState is the state of the turtle after a step, with its position
+// in drawing coordinates
+type TurtleState struct {
+	X       float32 `json:"x"`
+	Y       float32 `json:"y"`
+	Heading float32 `json:"heading"`
+	PenDown bool    `json:"penDown"`
+	Color   string  `json:"color"` // As #rrggbb
+	PenSize float32 `json:"penSize"`
+}
+
+// StreamMessage is a message StreamHandler sends. Each step of the program
+// sends the command that ran, the turtle's state after it and the segments
+// it drew; the last message reports that the program is done or why it
+// failed.
+type StreamMessage struct {
+	Command  string       `json:"command,omitempty"`
+	Turtle   *TurtleState `json:"turtle,omitempty"`
+	Segments []Segment    `json:"segments,omitempty"`
+	Done     bool         `json:"done,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// StreamHandler runs a Logo program sent over a WebSocket, streaming each
+// step back as it runs so a browser can animate the drawing. The client
+// sends the program as a single message, and the handler closes the
+// connection once the program finishes, fails or runs longer than Timeout.
+type StreamHandler struct {
+	Timeout time.Duration // How long a program may run
+}
+
+// NewStreamHandler creates a StreamHandler giving each program five seconds
+// to run
+func NewStreamHandler() *StreamHandler {
+	return &StreamHandler{Timeout: 5 * time.Second}
+}
+
+// ServeHTTP accepts the WebSocket connection and streams the program
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(h.stream).ServeHTTP(w, r)
+}
+
+// stream runs the program received on ws, sending a message after each
+// step. A failed send stops the program, as nobody is listening.
+func (h *StreamHandler) stream(ws *websocket.Conn) {
+	defer ws.Close()
+
+	var source string
+	if err := websocket.Message.Receive(ws, &source); err != nil {
+		return
+	}
+	program, err := parser.ParseProgram(source)
+	if err == nil {
+		err = checkProgram(program)
+	}
+	if err != nil {
+		websocket.JSON.Send(ws, StreamMessage{Error: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ws.Request().Context(), h.Timeout)
+	defer cancel()
+	interp := interpreter.New()
+	interp.SetOutput(io.Discard)
+	interp.SetSkipWaits(true)
+	tracker := drawing.NewSegmentTracker(interp.Drawing())
+	interp.OnStep(func(cmd ast.Command, s interpreter.Snapshot) {
+		segments := []Segment{}
+		for _, segment := range tracker.Next() {
+			segments = append(segments, newSegment(segment))
+		}
+
+		err := websocket.JSON.Send(ws, StreamMessage{
+			Command: cmd.String(),
+			Turtle: &TurtleState{
+				X:       s.X,
+				Y:       s.Y,
+				Heading: s.Heading,
+				PenDown: s.PenDown,
+				Color:   hexColor(s.PenColor),
+				PenSize: s.PenSize,
+			},
+			Segments: segments,
+		})
+		if err != nil {
+			cancel()
+		}
+	})
+
+	if _, err := interp.ExecuteContext(ctx, source); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("program did not finish within %s", h.Timeout)
+		}
+		websocket.JSON.Send(ws, StreamMessage{Error: err.Error()})
+		return
+	}
+	websocket.JSON.Send(ws, StreamMessage{Done: true})
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// streamProgram sends a program to a StreamHandler and returns the messages
+// it sends back
+func streamProgram(t *testing.T, h *StreamHandler, program string) []StreamMessage {
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer ws.Close()
+	assert.NoError(t, websocket.Message.Send(ws, program))
+
+	messages := []StreamMessage{}
+	for {
+		var message StreamMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			return messages
+		}
+		messages = append(messages, message)
+	}
+}
+
+func TestStreamHandlerSteps(t *testing.T) {
+	messages := streamProgram(t, NewStreamHandler(), "pu fd 10 pd repeat 2 [ fd 20 rt 90 ]")
+	if !assert.Len(t, messages, 9) {
+		return
+	}
+
+	commands := []string{}
+	for _, message := range messages[:8] {
+		commands = append(commands, message.Command)
+	}
+	assert.Equal(t, []string{"PEN UP", "FORWARD 10", "PEN DOWN", "FORWARD 20", "RIGHT 90", "FORWARD 20", "RIGHT 90", "REPEAT 2 {\nFORWARD 20\nRIGHT 90\n}"}, commands)
+	assert.Empty(t, messages[1].Segments)
+	if assert.Len(t, messages[3].Segments, 1) {
+		s := messages[3].Segments[0]
+		assert.InDelta(t, 10, s.Y1, 1e-4)
+		assert.InDelta(t, 30, s.Y2, 1e-4)
+		assert.Equal(t, "#000000", s.Color)
+	}
+	turtle := messages[5].Turtle
+	assert.InDelta(t, 20, turtle.X, 1e-4)
+	assert.InDelta(t, 30, turtle.Y, 1e-4)
+	assert.True(t, turtle.PenDown)
+	assert.Len(t, messages[5].Segments, 1)
+	assert.True(t, messages[8].Done)
+}
+
+func TestStreamHandlerError(t *testing.T) {
+	messages := streamProgram(t, NewStreamHandler(), "fd")
+	if assert.Len(t, messages, 1) {
+		assert.Contains(t, messages[0].Error, "forward command requires")
+	}
+}
+
+func TestStreamHandlerReset(t *testing.T) {
+	messages := streamProgram(t, NewStreamHandler(), "fd 10 reset fd 20")
+	if !assert.Len(t, messages, 4) {
+		return
+	}
+	assert.Len(t, messages[0].Segments, 1)
+	assert.Empty(t, messages[1].Segments)
+	if assert.Len(t, messages[2].Segments, 1) {
+		assert.InDelta(t, 0, messages[2].Segments[0].Y1, 1e-4)
+		assert.InDelta(t, 20, messages[2].Segments[0].Y2, 1e-4)
+	}
+	assert.True(t, messages[3].Done)
+}
@@ -0,0 +1,81 @@
+package turtle
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HeadingConventions lists the ways a turtle can measure headings:
+//   - screen: 0° points east and headings grow clockwise, as the turtle
+//     turns on screen
+//   - math: 0° points east and headings grow counterclockwise
+//   - compass: 0° points north and headings grow clockwise
+var HeadingConventions = []string{"screen", "math", "compass"}
+
+// compassPoints names the eight compass directions, clockwise from north
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// SetHeadingConvention sets how SetHeading and Heading measure headings,
+// which must be one of HeadingConventions. The turtle keeps pointing the
+// same way. Forward, Left and Right move and turn it the same way on screen
+// in every convention; only the number naming each direction changes.
+func (t *Turtle) SetHeadingConvention(name string) error {
+	if !slices.Contains(HeadingConventions, name) {
+		return fmt.Errorf("unknown heading convention %q, valid conventions are: %s", name, strings.Join(HeadingConventions, ", "))
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.headings = name
+	return nil
+}
+
+// HeadingConvention returns how the turtle measures headings
+func (t *Turtle) HeadingConvention() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.headings
+}
+
+// fromConvention converts a heading given in the turtle's convention to
+// degrees clockwise from screen +X
+func (t *Turtle) fromConvention(angle float64) float64 {
+	switch t.headings {
+	case "math":
+		return -angle
+	case "compass":
+		return angle - 90
+	}
+	return angle
+}
+
+// toConvention converts a heading in degrees clockwise from screen +X to
+// the turtle's convention
+func (t *Turtle) toConvention(heading float64) float64 {
+	switch t.headings {
+	case "math":
+		return -heading
+	case "compass":
+		return heading + 90
+	}
+	return heading
+}
+
+// Compass describes the turtle's heading as the nearest of the eight
+// compass points along with its bearing, such as "NE 40°", whatever the
+// heading convention
+func (t *Turtle) Compass() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return CompassDirection(headingFloat32(normalizeHeading(t.heading + 90)))
+}
+
+// CompassDirection describes a bearing in degrees clockwise from north as
+// the nearest compass point and the bearing rounded to four decimal places
+func CompassDirection(bearing float32) string {
+	b := normalizeHeading(math.Round(float64(bearing)*10000) / 10000)
+	point := compassPoints[int(math.Round(b/45))%len(compassPoints)]
+	return fmt.Sprintf("%s %s°", point, strconv.FormatFloat(b, 'f', -1, 64))
+}
@@ -0,0 +1,89 @@
+package turtle
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+
+	"github.com/honeylogo/logo/drawing"
+)
+
+// surface is where a turtle draws its lines and shows its sprite
+type surface interface {
+	drawLine(start, end position, c color.Color, width float32)
+	drawDisc(centre position, radius float32, c color.Color)
+	moveSprite(pos position)
+	turnSprite(heading float32)
+	size() (float32, float32)
+}
+
+// NewTurtle creates a new turtle with default settings and a provided Fyne canvas
+func NewTurtle(container *fyne.Container, width, height float32) *Turtle {
+	home := position{X: width / 2, Y: height / 2}
+	homeHeading := 270.0
+	return &Turtle{
+		pos:         home,
+		home:        home,
+		heading:     homeHeading,
+		homeHeading: homeHeading,
+		headings:    "screen",
+		penDown:     true,
+		penColor:    color.Black,
+		fillColor:   color.White,
+		penSize:     1,
+		penPattern:  "solid",
+		isVisible:   true,
+		shape:       "turtle",
+		speed:       3,
+		surface:     newFyneSurface(container, home, float32(homeHeading)),
+		path:        drawing.NewDrawing(),
+	}
+}
+
+// fyneSurface draws a turtle onto a Fyne container
+type fyneSurface struct {
+	container *fyne.Container
+	sprite    *TurtleSprite
+}
+
+// newFyneSurface adds a sprite at the given position and heading to the
+// container
+func newFyneSurface(container *fyne.Container, pos position, heading float32) *fyneSurface {
+	sprite := NewTurtleSprite()
+	sprite.Move(fyne.NewPos(pos.X, pos.Y))
+	sprite.SetAngle(heading)
+	container.Add(sprite.Image())
+	return &fyneSurface{
+		container: container,
+		sprite:    sprite,
+	}
+}
+
+func (fs *fyneSurface) drawLine(start, end position, c color.Color, width float32) {
+	line := canvas.NewLine(c)
+	line.StrokeWidth = width
+	line.Position1 = fyne.NewPos(start.X, start.Y)
+	line.Position2 = fyne.NewPos(end.X, end.Y)
+	fs.container.Add(line)
+}
+
+func (fs *fyneSurface) drawDisc(centre position, radius float32, c color.Color) {
+	circle := canvas.NewCircle(c)
+	circle.Move(fyne.NewPos(centre.X-radius, centre.Y-radius))
+	circle.Resize(fyne.NewSize(2*radius, 2*radius))
+	fs.container.Add(circle)
+}
+
+func (fs *fyneSurface) moveSprite(pos position) {
+	fs.sprite.Move(fyne.NewPos(pos.X, pos.Y))
+}
+
+func (fs *fyneSurface) turnSprite(heading float32) {
+	fs.sprite.SetAngle(heading)
+}
+
+func (fs *fyneSurface) size() (float32, float32) {
+	size := fs.container.Size()
+	return size.Width, size.Height
+}
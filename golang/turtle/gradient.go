@@ -0,0 +1,66 @@
+package turtle
+
+import (
+	"image/color"
+	"math"
+)
+
+// gradient interpolates between two colors over a number of steps
+type gradient struct {
+	from, to color.RGBA
+	steps    int
+	step     int
+}
+
+func newGradient(from, to color.Color, steps int) *gradient {
+	return &gradient{
+		from:  color.RGBAModel.Convert(from).(color.RGBA),
+		to:    color.RGBAModel.Convert(to).(color.RGBA),
+		steps: steps,
+	}
+}
+
+// next returns the color of the next step, staying on the final color once
+// every step has been used
+func (g *gradient) next() color.Color {
+	fraction := stepFraction(g.step, g.steps)
+	g.step++
+	return color.RGBA{
+		R: interpolate(g.from.R, g.to.R, fraction),
+		G: interpolate(g.from.G, g.to.G, fraction),
+		B: interpolate(g.from.B, g.to.B, fraction),
+		A: interpolate(g.from.A, g.to.A, fraction),
+	}
+}
+
+func interpolate(from, to uint8, fraction float64) uint8 {
+	return uint8(math.Round(float64(from) + (float64(to)-float64(from))*fraction))
+}
+
+// sizeRamp interpolates between two pen sizes over a number of steps
+type sizeRamp struct {
+	from, to float32
+	steps    int
+	step     int
+}
+
+func newSizeRamp(from, to float32, steps int) *sizeRamp {
+	return &sizeRamp{from: from, to: to, steps: steps}
+}
+
+// next returns the pen size of the next step, staying on the final size
+// once every step has been used
+func (r *sizeRamp) next() float32 {
+	fraction := stepFraction(r.step, r.steps)
+	r.step++
+	return r.from + (r.to-r.from)*float32(fraction)
+}
+
+// stepFraction returns how far along a transition of steps steps the given
+// step is, from 0 at the first step to 1 at the last and beyond
+func stepFraction(step, steps int) float64 {
+	if step < steps-1 {
+		return float64(step) / float64(steps-1)
+	}
+	return 1
+}
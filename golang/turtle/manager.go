@@ -0,0 +1,92 @@
+package turtle
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/honeylogo/logo/drawing"
+)
+
+// DefaultTurtle is the name a Manager gives to the turtle it is created with
+const DefaultTurtle = "default"
+
+// Manager holds a set of named turtles that all draw into the same drawing
+type Manager struct {
+	mutex   sync.Mutex
+	first   *Turtle
+	turtles map[string]*Turtle
+}
+
+// NewManager creates a manager whose only turtle is first, registered under
+// DefaultTurtle. Turtles added later share first's drawing and home.
+func NewManager(first *Turtle) *Manager {
+	return &Manager{
+		first:   first,
+		turtles: map[string]*Turtle{DefaultTurtle: first},
+	}
+}
+
+// Add creates a headless turtle with the given name, starting at home with
+// the pen down. It takes the first turtle's coordinate limit and grid snap,
+// so a program can't escape the limit by making a turtle of its own.
+func (m *Manager) Add(name string) (*Turtle, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, exists := m.turtles[name]; exists {
+		return nil, fmt.Errorf("turtle %q already exists", name)
+	}
+
+	t := NewHeadless()
+	t.id = len(m.turtles)
+	t.home = m.first.home
+	t.pos = m.first.home
+	t.path = m.first.path
+	t.limit = m.first.CoordinateLimit()
+	t.gridSnap = m.first.GridSnap()
+	t.record(t.pos)
+	m.turtles[name] = t
+	return t, nil
+}
+
+// Get returns the turtle with the given name
+func (m *Manager) Get(name string) (*Turtle, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	t, exists := m.turtles[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown turtle %q", name)
+	}
+	return t, nil
+}
+
+// Names returns the names of all turtles in alphabetical order
+func (m *Manager) Names() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	names := make([]string, 0, len(m.turtles))
+	for name := range m.turtles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reset removes every turtle but the default one, and resets that one and
+// the shared drawing
+func (m *Manager) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.turtles = map[string]*Turtle{DefaultTurtle: m.first}
+	m.first.Reset()
+}
+
+// Default returns the turtle the manager was created with
+func (m *Manager) Default() *Turtle {
+	return m.first
+}
+
+// Drawing returns the drawing shared by all the turtles
+func (m *Manager) Drawing() *drawing.Drawing {
+	return m.first.path
+}
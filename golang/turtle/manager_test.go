@@ -0,0 +1,38 @@
+package turtle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(NewHeadless())
+	second, err := m.Add("second")
+	assert.NoError(t, err)
+	_, err = m.Add("second")
+	assert.ErrorContains(t, err, `turtle "second" already exists`)
+
+	first, err := m.Get(DefaultTurtle)
+	assert.NoError(t, err)
+	_, err = m.Get("third")
+	assert.ErrorContains(t, err, `unknown turtle "third"`)
+	assert.Equal(t, []string{DefaultTurtle, "second"}, m.Names())
+
+	// Interleaved moves still join each turtle's own points
+	first.Forward(10)
+	second.Right(90)
+	second.Forward(20)
+	first.Forward(10)
+
+	segments := m.Drawing().Segments()
+	if assert.Len(t, segments, 3) {
+		assert.Equal(t, 0, segments[0].Turtle)
+		assert.Equal(t, 1, segments[1].Turtle)
+		assert.InDelta(t, 20.0, segments[1].EndX, 0.001)
+		assert.InDelta(t, 0.0, segments[1].EndY, 0.001)
+		assert.Equal(t, 0, segments[2].Turtle)
+		assert.InDelta(t, 10.0, segments[2].StartY, 0.001)
+		assert.InDelta(t, 20.0, segments[2].EndY, 0.001)
+	}
+}
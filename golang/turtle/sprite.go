@@ -1,6 +1,8 @@
 package turtle
 
 import (
+	"bytes"
+	_ "embed"
 	"image"
 	"image/color"
 
@@ -11,6 +13,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+//go:embed sprite.png
+var spritePNG []byte
+
 // TurtleSprite represents a turtle sprite with position and angle
 type TurtleSprite struct {
 	image     *canvas.Image // This will hold the image
@@ -29,9 +34,10 @@ func NewTurtleSprite() *TurtleSprite {
 		y:       100,
 		angle:   90,
 	}
-	png, err := imaging.Open("turtle/sprite.png")
+	png, err := imaging.Decode(bytes.NewReader(spritePNG))
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to open sprite.png")
+		log.Error().Err(err).Msg("Failed to decode sprite.png")
+		png = image.NewNRGBA(image.Rect(0, 0, 30, 30))
 	}
 	t.png = png
 	t.image = canvas.NewImageFromImage(png)
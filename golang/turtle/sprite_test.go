@@ -0,0 +1,25 @@
+package turtle
+
+import (
+	"os"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTurtleSpriteFromAnyDirectory(t *testing.T) {
+	app := test.NewApp()
+	defer app.Quit()
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	defer os.Chdir(wd)
+	assert.NoError(t, os.Chdir(t.TempDir()))
+
+	sprite := NewTurtleSprite()
+	if assert.NotNil(t, sprite.png) {
+		assert.Equal(t, 170, sprite.png.Bounds().Dx())
+		assert.Equal(t, 236, sprite.png.Bounds().Dy())
+	}
+}
@@ -2,104 +2,270 @@
 package turtle
 
 import (
+	"errors"
+	"fmt"
 	"image/color"
 	"math"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
+	"github.com/honeylogo/logo/drawing"
 )
 
+// ErrCoordinateLimit is wrapped by the error a move returns when it would
+// take the turtle past its coordinate limit
+var ErrCoordinateLimit = errors.New("coordinate limit exceeded")
+
+// position is a point in the turtle's screen coordinates, with +Y pointing
+// down
+type position struct {
+	X, Y float32
+}
+
+// Shapes lists the built-in shapes a turtle can be drawn as
+var Shapes = []string{"turtle", "triangle", "arrow", "circle", "blank"}
+
+// PenPatterns lists the styles of line a turtle can draw
+var PenPatterns = []string{"solid", "dashed", "dotted"}
+
 // Turtle represents a turtle graphics cursor
 type Turtle struct {
-	pos         fyne.Position
-	home        fyne.Position
-	heading     float32 // Current heading in degrees
-	homeHeading float32 // Heading when created
+	pos         position
+	home        position
+	heading     float64 // Current heading in degrees clockwise from screen +X, in [0, 360)
+	homeHeading float64 // Heading when created
+	headings    string  // Heading convention, one of HeadingConventions
 	penDown     bool    // Whether the pen is down
 	penColor    color.Color
+	gradient    *gradient // Colors successive segments when set
+	sizeRamp    *sizeRamp // Sizes successive segments when set
 	fillColor   color.Color
 	penSize     float32
+	penPattern  string
+	gridSnap    float64 // Grid step positions are rounded to, or 0 for none
+	limit       float64 // Largest coordinate magnitude moves may reach, or 0 for none
+	mirrored    bool    // Whether left and right turns are swapped
 	isVisible   bool
+	shape       string
 	speed       int
-	drawing     *fyne.Container
+	id          int // Identifies the turtle's points in a shared drawing
 	mutex       sync.Mutex
-	sprite      *TurtleSprite
+	surface     surface // nil for a headless turtle
+	path        *drawing.Drawing
 }
 
-// NewTurtle creates a new turtle with default settings and a provided Fyne canvas
-func NewTurtle(drawing *fyne.Container, width, height float32) *Turtle {
-	home := fyne.NewPos(width/2, height/2)
-	homeHeading := float32(-90)
-	sprite := NewTurtleSprite()
-	sprite.Move(home)
-	sprite.SetAngle(homeHeading)
-	drawing.Add(sprite.Image())
+// NewHeadless creates a turtle that is not attached to a Fyne canvas and has
+// no sprite. It starts at the origin and only records its path, so it can be
+// used without a display.
+func NewHeadless() *Turtle {
 	return &Turtle{
-		pos:         home,
-		home:        home,
-		heading:     homeHeading,
-		homeHeading: homeHeading,
+		heading:     270,
+		homeHeading: 270,
+		headings:    "screen",
 		penDown:     true,
 		penColor:    color.Black,
 		fillColor:   color.White,
 		penSize:     1,
+		penPattern:  "solid",
 		isVisible:   true,
-		speed:       3,
-		drawing:     drawing,
-		sprite:      sprite,
+		shape:       "turtle",
+		path:        drawing.NewDrawing(),
+	}
+}
+
+// Clone creates an independent headless turtle with the same position,
+// heading, home, pen and shape as t. The clone gets a drawing of its own
+// that starts where it stands, and no canvas, so it can be given one
+// separately.
+func (t *Turtle) Clone() *Turtle {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	c := &Turtle{
+		pos:         t.pos,
+		home:        t.home,
+		heading:     t.heading,
+		homeHeading: t.homeHeading,
+		headings:    t.headings,
+		penDown:     t.penDown,
+		penColor:    t.penColor,
+		fillColor:   t.fillColor,
+		penSize:     t.penSize,
+		penPattern:  t.penPattern,
+		gridSnap:    t.gridSnap,
+		limit:       t.limit,
+		mirrored:    t.mirrored,
+		isVisible:   t.isVisible,
+		shape:       t.shape,
+		speed:       t.speed,
+		path:        drawing.NewDrawing(),
 	}
+	if t.gradient != nil {
+		g := *t.gradient
+		c.gradient = &g
+	}
+	if t.sizeRamp != nil {
+		r := *t.sizeRamp
+		c.sizeRamp = &r
+	}
+	// Start the clone's drawing with its own pen, so a clone with the pen
+	// up doesn't begin with a pen-down point
+	c.path.SetPenDown(c.penDown)
+	c.path.SetPenColor(c.penColor)
+	c.path.SetPenSize(float64(c.penSize))
+	c.path.Reset(float64(c.pos.X-c.home.X), float64(c.home.Y-c.pos.Y))
+	return c
 }
 
+// Resize recentres the turtle on its canvas, resetting it to its home
 func (t *Turtle) Resize() {
-	size := t.drawing.Size()
-	t.home = fyne.NewPos(size.Width/2, size.Height/2)
+	if t.surface == nil {
+		return
+	}
+	width, height := t.surface.size()
+	t.home = position{X: width / 2, Y: height / 2}
 	t.pos = t.home
 	t.heading = t.homeHeading
-	t.sprite.Move(t.home)
+	t.moveSprite(t.home)
 }
 
-// Forward moves the turtle forward by the specified distance
-func (t *Turtle) Forward(distance float32) {
+// Forward moves the turtle forward by the specified distance. It returns an
+// error, leaving the turtle where it is, if the move would take it past its
+// coordinate limit.
+func (t *Turtle) Forward(distance float32) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	rad := float64(t.heading * math.Pi / 180)
+	rad := t.heading * math.Pi / 180
 	newX := t.pos.X + distance*float32(math.Cos(rad))
 	newY := t.pos.Y + distance*float32(math.Sin(rad))
-	newPos := fyne.NewPos(float32(newX), float32(newY))
+	newPos := t.snap(position{X: float32(newX), Y: float32(newY)})
+	if err := t.checkLimit(newPos); err != nil {
+		return err
+	}
 
 	if t.penDown {
 		t.drawLine(t.pos, newPos)
-		t.pos = fyne.NewPos(float32(newX), float32(newY))
 	}
+	t.pos = newPos
 
-	t.sprite.Move(newPos)
+	t.record(newPos)
+	t.moveSprite(newPos)
 	t.delay()
+	return nil
 }
 
-// Backward moves the turtle backward by the specified distance
-func (t *Turtle) Backward(distance float32) {
-	t.Forward(-distance)
+// Backward moves the turtle backward by the specified distance, failing
+// like Forward past the coordinate limit
+func (t *Turtle) Backward(distance float32) error {
+	return t.Forward(-distance)
 }
 
-// Right turns the turtle right by the specified angle in degrees
+// Right turns the turtle right by the specified angle in degrees, or left
+// when it's mirrored
 func (t *Turtle) Right(angle float32) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.heading = float32(math.Mod(float64(t.heading+angle), 360))
-	t.sprite.SetAngle(t.heading)
+	t.heading = normalizeHeading(t.heading + t.turn(angle))
+	t.turnSprite(t.heading)
 	t.delay()
 }
 
-// Left turns the turtle left by the specified angle in degrees
+// Left turns the turtle left by the specified angle in degrees, or right
+// when it's mirrored
 func (t *Turtle) Left(angle float32) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.heading = float32(math.Mod(float64(t.heading-angle), 360))
-	t.sprite.SetAngle(t.heading)
+	t.heading = normalizeHeading(t.heading - t.turn(angle))
+	t.turnSprite(t.heading)
+	t.delay()
+}
+
+// SetMirror sets whether the turtle swaps left and right, for turns and
+// arcs alike, so the same commands draw the mirror image of their usual
+// shape. Absolute headings such as SetHeading aren't affected.
+func (t *Turtle) SetMirror(mirrored bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.mirrored = mirrored
+}
+
+// Mirrored reports whether the turtle swaps left and right
+func (t *Turtle) Mirrored() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.mirrored
+}
+
+// turn returns the clockwise turn a right turn by angle makes, which is
+// anticlockwise when the turtle is mirrored. The caller must hold the lock.
+func (t *Turtle) turn(angle float32) float64 {
+	if t.mirrored {
+		return -float64(angle)
+	}
+	return float64(angle)
+}
+
+// ArcRight moves the turtle along an arc of the given radius, curving to the
+// right around a centre perpendicular to its heading, and turns it by angle.
+// Past the coordinate limit it stops at the last point within it and
+// returns an error.
+func (t *Turtle) ArcRight(radius, angle float32) error {
+	return t.arc(radius, angle)
+}
+
+// ArcLeft moves the turtle along an arc of the given radius, curving to the
+// left around a centre perpendicular to its heading, and turns it by angle.
+// Past the coordinate limit it stops at the last point within it and
+// returns an error.
+func (t *Turtle) ArcLeft(radius, angle float32) error {
+	return t.arc(radius, -angle)
+}
+
+// arcStep is the largest turn, in degrees, covered by one segment of an arc
+const arcStep = 5
+
+// arc approximates an arc with straight chords, turning half a step either
+// side of each chord so every vertex lies on the circle. Positive angles
+// curve to the right, or to the left when the turtle is mirrored.
+func (t *Turtle) arc(radius, angle float32) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	steps := int(math.Ceil(math.Abs(float64(angle)) / arcStep))
+	if steps == 0 {
+		return nil
+	}
+	step := float32(t.turn(angle)) / float32(steps)
+	chord := 2 * radius * float32(math.Sin(math.Abs(float64(step))*math.Pi/360))
+
+	var err error
+	for i := 0; i < steps; i++ {
+		t.heading += float64(step) / 2
+		rad := t.heading * math.Pi / 180
+		newPos := t.snap(position{
+			X: t.pos.X + chord*float32(math.Cos(rad)),
+			Y: t.pos.Y + chord*float32(math.Sin(rad)),
+		})
+		if err = t.checkLimit(newPos); err != nil {
+			// Face the way the arc runs at the last point reached
+			t.heading -= float64(step) / 2
+			break
+		}
+		if t.penDown {
+			t.drawLine(t.pos, newPos)
+		}
+		t.pos = newPos
+		t.record(newPos)
+		t.heading += float64(step) / 2
+	}
+	t.heading = normalizeHeading(t.heading)
+
+	t.moveSprite(t.pos)
+	t.turnSprite(t.heading)
 	t.delay()
+	return err
 }
 
 // PenUp lifts the pen up (no drawing)
@@ -116,11 +282,32 @@ func (t *Turtle) PenDown() {
 	t.penDown = true
 }
 
-// SetPenColor sets the color of the pen
+// SetPenColor sets the color of the pen, ending any gradient
 func (t *Turtle) SetPenColor(c color.Color) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	t.penColor = c
+	t.gradient = nil
+}
+
+// SetPenGradient colors the next steps drawn segments with a smooth
+// transition from one color to another. Later segments keep the final color.
+func (t *Turtle) SetPenGradient(from, to color.Color, steps int) error {
+	if steps < 2 {
+		return fmt.Errorf("a pen gradient needs at least 2 steps, got %d", steps)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.gradient = newGradient(from, to, steps)
+	t.penColor = from
+	return nil
+}
+
+// GetColor returns the color of the pen
+func (t *Turtle) GetColor() color.Color {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.penColor
 }
 
 // SetFillColor sets the fill color
@@ -130,11 +317,127 @@ func (t *Turtle) SetFillColor(c color.Color) {
 	t.fillColor = c
 }
 
-// SetPenSize sets the size of the pen
+// Pen size limits, in pixels
+const (
+	MinPenSize = 0.5
+	MaxPenSize = 100
+)
+
+// SetPenSize sets the size of the pen, clamped to between MinPenSize and
+// MaxPenSize, ending any size ramp
 func (t *Turtle) SetPenSize(size float32) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.penSize = size
+	t.penSize = clampPenSize(size)
+	t.sizeRamp = nil
+}
+
+// SetGridSnap makes the turtle round its position to the nearest multiple
+// of step, measured from home, after each move and before the position is
+// recorded. Arcs are snapped at every chord. A step of 0 or less turns
+// snapping off.
+func (t *Turtle) SetGridSnap(step float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.gridSnap = max(step, 0)
+}
+
+// GridSnap returns the grid step positions are rounded to, or 0 if they
+// aren't snapped
+func (t *Turtle) GridSnap() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.gridSnap
+}
+
+// snap rounds a position to the grid, if snapping is on
+func (t *Turtle) snap(pos position) position {
+	if t.gridSnap == 0 {
+		return pos
+	}
+	round := func(offset float32) float32 {
+		return float32(math.Round(float64(offset)/t.gridSnap) * t.gridSnap)
+	}
+	return position{X: t.home.X + round(pos.X-t.home.X), Y: t.home.Y + round(pos.Y-t.home.Y)}
+}
+
+// SetCoordinateLimit stops moves that would take the turtle more than limit
+// from its home on either axis, or to a position that isn't a number, so
+// runaway programs fail with an error wrapping ErrCoordinateLimit instead
+// of recording meaningless points. A limit of 0 or less removes it. Unlike
+// other settings the limit survives Reset, so a program can't lift it.
+func (t *Turtle) SetCoordinateLimit(limit float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.limit = max(limit, 0)
+}
+
+// CoordinateLimit returns the largest coordinate the turtle may move to, or
+// 0 if it may move anywhere
+func (t *Turtle) CoordinateLimit() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.limit
+}
+
+// checkLimit returns an error if pos is past the coordinate limit. The
+// caller must hold the lock.
+func (t *Turtle) checkLimit(pos position) error {
+	if t.limit == 0 {
+		return nil
+	}
+	x, y := float64(pos.X-t.home.X), float64(t.home.Y-pos.Y)
+	// Written so NaN fails the check too
+	if !(math.Abs(x) <= t.limit && math.Abs(y) <= t.limit) {
+		// Round away the noise trigonometry leaves, and adding 0 turns -0 to 0
+		round := func(v float64) float64 { return math.Round(v*100)/100 + 0 }
+		return fmt.Errorf("%w: moving to (%g, %g) goes past ±%g", ErrCoordinateLimit, round(x), round(y), t.limit)
+	}
+	return nil
+}
+
+// PenSize returns the size of the pen
+func (t *Turtle) PenSize() float32 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.penSize
+}
+
+// RampPenSize draws the next steps drawn segments with a pen size changing
+// evenly from one size to another. Later segments keep the final size.
+func (t *Turtle) RampPenSize(from, to float32, steps int) error {
+	if steps < 2 {
+		return fmt.Errorf("a pen size ramp needs at least 2 steps, got %d", steps)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sizeRamp = newSizeRamp(from, to, steps)
+	t.penSize = clampPenSize(from)
+	return nil
+}
+
+// clampPenSize limits a pen size to between MinPenSize and MaxPenSize
+func clampPenSize(size float32) float32 {
+	return min(max(size, MinPenSize), MaxPenSize)
+}
+
+// Disc stamps a disc of the given radius, filled with the pen color and
+// centred on the turtle, whether or not the pen is down. The turtle
+// doesn't move.
+func (t *Turtle) Disc(radius float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.path.AddDisc(drawing.Disc{
+		X:      float64(t.pos.X - t.home.X),
+		Y:      float64(t.home.Y - t.pos.Y),
+		Radius: float64(radius),
+		Color:  t.penColor,
+		Turtle: t.id,
+	})
+	if t.surface != nil {
+		t.surface.drawDisc(t.pos, radius, t.penColor)
+	}
+	t.delay()
 }
 
 // Home moves the turtle to the origin (0,0) and sets heading to 0
@@ -143,33 +446,68 @@ func (t *Turtle) Home() {
 	defer t.mutex.Unlock()
 	if t.penDown {
 		t.drawLine(t.pos, t.home)
-		t.pos = t.home
 	}
-	t.sprite.Move(t.home)
+	t.pos = t.home
+	t.record(t.home)
+	t.moveSprite(t.home)
 	t.heading = t.homeHeading
-	t.sprite.SetAngle(t.homeHeading)
+	t.turnSprite(t.homeHeading)
 	t.delay()
 }
 
-// Goto moves the turtle to the specified coordinates
-func (t *Turtle) Goto(x, y float32) {
+// Reset puts the turtle back as it was created: at home with its starting
+// heading and heading convention, not mirrored, shaped as a turtle, the pen
+// down in its default color, size and pattern, and its drawing cleared. A
+// turtle sharing its drawing clears it for every turtle.
+func (t *Turtle) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pos = t.home
+	t.heading = t.homeHeading
+	t.headings = "screen"
+	t.penDown = true
+	t.penColor = color.Black
+	t.fillColor = color.White
+	t.gradient = nil
+	t.penSize = 1
+	t.sizeRamp = nil
+	t.penPattern = "solid"
+	t.gridSnap = 0
+	t.mirrored = false
+	t.shape = "turtle"
+	t.path.SetBackground(nil)
+	t.path.Reset(0, 0)
+	t.moveSprite(t.home)
+	t.turnSprite(t.homeHeading)
+}
+
+// Goto moves the turtle to the specified coordinates, relative to its home
+// with +Y pointing up. It returns an error, leaving the turtle where it is,
+// if the coordinates are past its coordinate limit.
+func (t *Turtle) Goto(x, y float32) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	newPos := fyne.NewPos(t.home.X+x, t.home.Y+y)
+	newPos := t.snap(position{X: t.home.X + x, Y: t.home.Y - y})
+	if err := t.checkLimit(newPos); err != nil {
+		return err
+	}
 	if t.penDown {
 		t.drawLine(t.pos, newPos)
 	}
 	t.pos = newPos
-	t.sprite.Move(newPos)
+	t.record(newPos)
+	t.moveSprite(newPos)
 	t.delay()
+	return nil
 }
 
-// SetHeading sets the turtle's heading to the specified angle
+// SetHeading sets the turtle's heading to the specified angle, measured in
+// the turtle's heading convention
 func (t *Turtle) SetHeading(angle float32) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.heading = float32(math.Mod(float64(angle), 360))
-	t.sprite.SetAngle(t.heading)
+	t.heading = normalizeHeading(t.fromConvention(float64(angle)))
+	t.turnSprite(t.heading)
 	t.delay()
 }
 
@@ -180,11 +518,105 @@ func (t *Turtle) Position() (float32, float32) {
 	return t.pos.X, t.pos.Y
 }
 
-// Heading returns the current heading of the turtle
+// Heading returns the current heading of the turtle in degrees, measured in
+// the turtle's heading convention, in the range [0, 360). By default that
+// is clockwise from the screen's +X axis.
 func (t *Turtle) Heading() float32 {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	return t.heading
+	return headingFloat32(normalizeHeading(t.toConvention(t.heading)))
+}
+
+// GetPosition returns the position of the turtle relative to its home, with
+// +Y pointing up
+func (t *Turtle) GetPosition() (float32, float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.pos.X - t.home.X, t.home.Y - t.pos.Y
+}
+
+// PositionEquals reports whether the turtle is within epsilon of (x, y) on
+// both axes, with the position measured as GetPosition measures it. A
+// difference of exactly epsilon counts as equal.
+func (t *Turtle) PositionEquals(x, y, epsilon float64) bool {
+	tx, ty := t.GetPosition()
+	return math.Abs(float64(tx)-x) <= epsilon && math.Abs(float64(ty)-y) <= epsilon
+}
+
+// HeadingEquals reports whether the turtle's heading, measured in its
+// heading convention, is within epsilon degrees of heading. Headings are
+// compared around the circle, so 359.9 and 0.1 are 0.2 degrees apart. A
+// difference of exactly epsilon counts as equal.
+func (t *Turtle) HeadingEquals(heading, epsilon float64) bool {
+	t.mutex.Lock()
+	current := normalizeHeading(t.toConvention(t.heading))
+	t.mutex.Unlock()
+	difference := normalizeHeading(current - heading)
+	return min(difference, 360-difference) <= epsilon
+}
+
+// GetX returns the x-coordinate of the turtle relative to its home
+func (t *Turtle) GetX() float32 {
+	x, _ := t.GetPosition()
+	return x
+}
+
+// GetY returns the y-coordinate of the turtle relative to its home, with +Y
+// pointing up
+func (t *Turtle) GetY() float32 {
+	_, y := t.GetPosition()
+	return y
+}
+
+// GetAngle returns the heading of the turtle in degrees counterclockwise
+// from the +X axis, in the range [0, 360)
+func (t *Turtle) GetAngle() float32 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return headingFloat32(normalizeHeading(-t.heading))
+}
+
+// Drawing returns the path recorded by the turtle
+func (t *Turtle) Drawing() *drawing.Drawing {
+	return t.path
+}
+
+// SetShape sets the shape the turtle is drawn as, which must be one of
+// Shapes
+func (t *Turtle) SetShape(name string) error {
+	if !slices.Contains(Shapes, name) {
+		return fmt.Errorf("unknown shape %q, valid shapes are: %s", name, strings.Join(Shapes, ", "))
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.shape = name
+	return nil
+}
+
+// SetPenPattern sets the style of line the turtle draws, which must be one
+// of PenPatterns
+func (t *Turtle) SetPenPattern(name string) error {
+	if !slices.Contains(PenPatterns, name) {
+		return fmt.Errorf("unknown pen pattern %q, valid patterns are: %s", name, strings.Join(PenPatterns, ", "))
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.penPattern = name
+	return nil
+}
+
+// PenPattern returns the style of line the turtle draws
+func (t *Turtle) PenPattern() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.penPattern
+}
+
+// Shape returns the shape the turtle is drawn as
+func (t *Turtle) Shape() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.shape
 }
 
 // IsDown returns whether the pen is down
@@ -194,6 +626,11 @@ func (t *Turtle) IsDown() bool {
 	return t.penDown
 }
 
+// IsPenDown returns whether the pen is down, like IsDown
+func (t *Turtle) IsPenDown() bool {
+	return t.IsDown()
+}
+
 // Speed sets the turtle's speed (0=fastest, 1-10 for incrementing speeds)
 func (t *Turtle) Speed(speed int) {
 	t.mutex.Lock()
@@ -214,10 +651,69 @@ func (t *Turtle) delay() {
 	}
 }
 
-func (t *Turtle) drawLine(start, end fyne.Position) {
-	line := canvas.NewLine(t.penColor)
-	line.StrokeWidth = float32(t.penSize)
-	line.Position1 = start
-	line.Position2 = end
-	t.drawing.Add(line)
+// normalizeHeading wraps an angle in degrees into the range [0, 360).
+// Headings are kept as float64, so many small turns don't drift the way
+// adding float32 angles would.
+func normalizeHeading(angle float64) float64 {
+	normalized := math.Mod(angle, 360)
+	if normalized < 0 {
+		normalized += 360
+	}
+	if normalized >= 360 || normalized == 0 {
+		// Rounding can carry a tiny negative angle up to 360, and -0 should
+		// read as 0
+		normalized = 0
+	}
+	return normalized
+}
+
+// headingFloat32 converts a normalized heading for callers, keeping it
+// below 360 where rounding would otherwise carry it up
+func headingFloat32(heading float64) float32 {
+	if h := float32(heading); h < 360 {
+		return h
+	}
+	return 0
+}
+
+// record adds a position to the turtle's path
+func (t *Turtle) record(pos position) {
+	t.path.AddPoint(drawing.Point{
+		X:          float64(pos.X - t.home.X),
+		Y:          float64(t.home.Y - pos.Y),
+		PenDown:    t.penDown,
+		PenColor:   t.penColor,
+		PenSize:    float64(t.penSize),
+		PenPattern: t.penPattern,
+		Turtle:     t.id,
+	})
+}
+
+// moveSprite moves the sprite, if the turtle has one
+func (t *Turtle) moveSprite(pos position) {
+	if t.surface != nil {
+		t.surface.moveSprite(pos)
+	}
+}
+
+// turnSprite rotates the sprite, if the turtle has one
+func (t *Turtle) turnSprite(heading float64) {
+	if t.surface != nil {
+		t.surface.turnSprite(float32(heading))
+	}
+}
+
+// drawLine draws a line in the current pen, if the turtle has a canvas,
+// first moving the pen color along any gradient and the pen size along any
+// size ramp
+func (t *Turtle) drawLine(start, end position) {
+	if t.gradient != nil {
+		t.penColor = t.gradient.next()
+	}
+	if t.sizeRamp != nil {
+		t.penSize = clampPenSize(t.sizeRamp.next())
+	}
+	if t.surface != nil {
+		t.surface.drawLine(start, end, t.penColor, t.penSize)
+	}
 }
@@ -0,0 +1,412 @@
+package turtle
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/honeylogo/logo/drawing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardWithPenUp(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.PenUp()
+	turtle.Forward(50)
+	turtle.PenDown()
+	turtle.Forward(50)
+
+	x, y := turtle.GetPosition()
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.InDelta(t, 100.0, y, 0.001)
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 1) {
+		assert.InDelta(t, 0.0, segments[0].StartX, 0.001)
+		assert.InDelta(t, 50.0, segments[0].StartY, 0.001)
+		assert.InDelta(t, 0.0, segments[0].EndX, 0.001)
+		assert.InDelta(t, 100.0, segments[0].EndY, 0.001)
+	}
+}
+
+func TestBackwardWithPenUp(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.PenUp()
+	turtle.Backward(30)
+	turtle.PenDown()
+	turtle.Backward(20)
+
+	x, y := turtle.GetPosition()
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.InDelta(t, -50.0, y, 0.001)
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 1) {
+		assert.InDelta(t, -30.0, segments[0].StartY, 0.001)
+		assert.InDelta(t, -50.0, segments[0].EndY, 0.001)
+	}
+}
+
+func TestHomeWithPenUp(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.Forward(40)
+	turtle.PenUp()
+	turtle.Home()
+	turtle.PenDown()
+	turtle.Right(90)
+	turtle.Forward(10)
+
+	x, y := turtle.GetPosition()
+	assert.InDelta(t, 10.0, x, 0.001)
+	assert.InDelta(t, 0.0, y, 0.001)
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 2) {
+		assert.InDelta(t, 0.0, segments[1].StartX, 0.001)
+		assert.InDelta(t, 0.0, segments[1].StartY, 0.001)
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	turtle := NewHeadless()
+	assert.Nil(t, turtle.surface)
+
+	turtle.SetPenColor(color.RGBA{R: 255, A: 255})
+	turtle.SetPenSize(3)
+	turtle.Forward(20)
+	turtle.Left(90)
+	turtle.Forward(10)
+	turtle.ArcRight(10, 90)
+	turtle.Goto(-5, 5)
+	turtle.SetHeading(0)
+	turtle.Resize()
+	turtle.Home()
+
+	x, y := turtle.GetPosition()
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.InDelta(t, 0.0, y, 0.001)
+	assert.InDelta(t, 90.0, turtle.GetAngle(), 0.001)
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 22) {
+		assert.InDelta(t, 20.0, segments[0].EndY, 0.001)
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, segments[0].Color)
+		assert.Equal(t, 3.0, segments[0].Width)
+	}
+}
+
+func TestSetShape(t *testing.T) {
+	turtle := NewHeadless()
+	assert.Equal(t, "turtle", turtle.Shape())
+
+	assert.NoError(t, turtle.SetShape("arrow"))
+	assert.Equal(t, "arrow", turtle.Shape())
+
+	assert.ErrorContains(t, turtle.SetShape("dragon"), `unknown shape "dragon"`)
+	assert.Equal(t, "arrow", turtle.Shape())
+}
+
+func TestPenGradient(t *testing.T) {
+	turtle := NewHeadless()
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	assert.NoError(t, turtle.SetPenGradient(red, blue, 3))
+
+	turtle.Forward(10)
+	turtle.PenUp()
+	turtle.Forward(10)
+	turtle.PenDown()
+	for i := 0; i < 3; i++ {
+		turtle.Forward(10)
+	}
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 4) {
+		assert.Equal(t, red, segments[0].Color)
+		// The pen-up move doesn't use up a step
+		assert.Equal(t, color.RGBA{R: 128, B: 128, A: 255}, segments[1].Color)
+		assert.Equal(t, blue, segments[2].Color)
+		assert.Equal(t, blue, segments[3].Color)
+	}
+
+	turtle.SetPenColor(red)
+	turtle.Forward(10)
+	assert.Equal(t, red, turtle.Drawing().Segments()[4].Color)
+
+	assert.Error(t, turtle.SetPenGradient(red, blue, 1))
+}
+
+func TestAngleNormalization(t *testing.T) {
+	turtle := NewHeadless()
+
+	turtle.SetHeading(-90)
+	assert.InDelta(t, 270.0, turtle.Heading(), 0.001)
+
+	turtle.SetHeading(0)
+	turtle.Left(450)
+	assert.InDelta(t, 270.0, turtle.Heading(), 0.001)
+	assert.InDelta(t, 90.0, turtle.GetAngle(), 0.001)
+
+	turtle.Right(-720)
+	assert.InDelta(t, 270.0, turtle.Heading(), 0.001)
+
+	turtle.ArcLeft(10, 400)
+	assert.GreaterOrEqual(t, turtle.Heading(), float32(0))
+	assert.Less(t, turtle.Heading(), float32(360))
+}
+
+func TestFractionalTurnsDontDrift(t *testing.T) {
+	turtle := NewHeadless()
+	for i := 0; i < 3600; i++ {
+		turtle.Right(0.1)
+	}
+	assert.InDelta(t, 270.0, turtle.Heading(), 1e-4)
+
+	for i := 0; i < 3600; i++ {
+		turtle.Left(0.25)
+	}
+	assert.InDelta(t, 90.0, turtle.Heading(), 1e-4)
+}
+
+func TestPenSizeClamping(t *testing.T) {
+	turtle := NewHeadless()
+
+	turtle.SetPenSize(0)
+	turtle.Forward(10)
+	turtle.SetPenSize(-5)
+	turtle.Forward(10)
+	turtle.SetPenSize(500)
+	turtle.Forward(10)
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 3) {
+		assert.Equal(t, MinPenSize, segments[0].Width)
+		assert.Equal(t, MinPenSize, segments[1].Width)
+		assert.Equal(t, float64(MaxPenSize), segments[2].Width)
+	}
+}
+
+func TestRampPenSize(t *testing.T) {
+	turtle := NewHeadless()
+	assert.NoError(t, turtle.RampPenSize(2, 10, 5))
+
+	for i := 0; i < 6; i++ {
+		turtle.Forward(10)
+	}
+
+	segments := turtle.Drawing().Segments()
+	if assert.Len(t, segments, 6) {
+		assert.Equal(t, 2.0, segments[0].Width)
+		assert.Equal(t, 4.0, segments[1].Width)
+		// The middle segment is the average of the start and end sizes
+		assert.Equal(t, 6.0, segments[2].Width)
+		assert.Equal(t, 10.0, segments[4].Width)
+		assert.Equal(t, 10.0, segments[5].Width)
+	}
+
+	turtle.SetPenSize(3)
+	turtle.Forward(10)
+	assert.Equal(t, 3.0, turtle.Drawing().Segments()[6].Width)
+
+	assert.Error(t, turtle.RampPenSize(2, 10, 1))
+}
+
+func TestClone(t *testing.T) {
+	original := NewHeadless()
+	original.Forward(30)
+	original.Right(45)
+	original.SetPenColor(color.RGBA{R: 255, A: 255})
+	original.SetPenSize(4)
+	original.PenUp()
+	assert.NoError(t, original.SetShape("arrow"))
+
+	clone := original.Clone()
+	assert.Equal(t, original.Heading(), clone.Heading())
+	x, y := clone.GetPosition()
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.InDelta(t, 30.0, y, 0.001)
+	assert.Equal(t, original.GetColor(), clone.GetColor())
+	assert.Equal(t, float32(4), clone.PenSize())
+	assert.False(t, clone.IsDown())
+	assert.Equal(t, "arrow", clone.Shape())
+
+	// The clone's drawing starts where it stands with its pen, with
+	// nothing drawn
+	if assert.Len(t, clone.Drawing().Points(), 1) {
+		start := clone.Drawing().Points()[0]
+		assert.False(t, start.PenDown)
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, start.PenColor)
+		assert.Equal(t, 4.0, start.PenSize)
+	}
+	assert.Empty(t, clone.Drawing().Segments())
+
+	// Changing one turtle leaves the other alone
+	clone.PenDown()
+	clone.Forward(10)
+	clone.SetPenSize(2)
+	assert.False(t, original.IsDown())
+	assert.Equal(t, float32(4), original.PenSize())
+	assert.Equal(t, float32(315), original.Heading())
+	x, y = original.GetPosition()
+	assert.InDelta(t, 30.0, y, 0.001)
+	assert.InDelta(t, 0.0, x, 0.001)
+	assert.Len(t, clone.Drawing().Segments(), 1)
+	assert.Len(t, original.Drawing().Segments(), 1)
+}
+
+func TestHeadingConventions(t *testing.T) {
+	turtle := NewHeadless()
+	assert.Equal(t, "screen", turtle.HeadingConvention())
+
+	assert.NoError(t, turtle.SetHeadingConvention("compass"))
+	turtle.SetHeading(0)
+	turtle.Forward(10)
+	x, y := turtle.GetPosition()
+	assert.InDelta(t, 0, x, 1e-4)
+	assert.InDelta(t, 10, y, 1e-4)
+	turtle.Right(90)
+	assert.InDelta(t, 90, turtle.Heading(), 1e-4)
+	assert.Equal(t, "E 90°", turtle.Compass())
+
+	turtle.Home()
+	assert.NoError(t, turtle.SetHeadingConvention("math"))
+	turtle.SetHeading(0)
+	turtle.Forward(10)
+	x, y = turtle.GetPosition()
+	assert.InDelta(t, 10, x, 1e-4)
+	assert.InDelta(t, 0, y, 1e-4)
+	turtle.Left(90)
+	assert.InDelta(t, 90, turtle.Heading(), 1e-4)
+	assert.Equal(t, "N 0°", turtle.Compass())
+
+	assert.Error(t, turtle.SetHeadingConvention("polar"))
+}
+
+func TestCompassDirection(t *testing.T) {
+	assert.Equal(t, "N 0°", CompassDirection(0))
+	assert.Equal(t, "NE 40°", CompassDirection(40))
+	assert.Equal(t, "SW 202.5°", CompassDirection(202.5))
+	assert.Equal(t, "N 350°", CompassDirection(-10))
+}
+
+func TestGridSnap(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.SetGridSnap(5)
+	turtle.SetHeading(0)
+	turtle.Forward(10.3)
+
+	points := turtle.Drawing().Points()
+	assert.Equal(t, 10.0, points[len(points)-1].X)
+	assert.Equal(t, 0.0, points[len(points)-1].Y)
+
+	turtle.Goto(-3, 7.4)
+	x, y := turtle.GetPosition()
+	assert.Equal(t, float32(-5), x)
+	assert.Equal(t, float32(5), y)
+
+	turtle.SetGridSnap(0)
+	turtle.Goto(-3, 7.4)
+	x, y = turtle.GetPosition()
+	assert.Equal(t, float32(-3), x)
+	assert.InDelta(t, 7.4, y, 1e-4)
+}
+
+func TestMirror(t *testing.T) {
+	draw := func(turtle *Turtle) []drawing.Point {
+		turtle.Forward(20)
+		turtle.Right(90)
+		turtle.Forward(10)
+		turtle.Left(45)
+		turtle.ArcRight(10, 90)
+		return turtle.Drawing().Points()
+	}
+	plain := draw(NewHeadless())
+
+	mirrored := NewHeadless()
+	mirrored.SetMirror(true)
+	assert.True(t, mirrored.Mirrored())
+	reflected := draw(mirrored)
+
+	// Starting upwards, swapping left and right reflects the path in the
+	// vertical axis
+	assert.Len(t, reflected, len(plain))
+	for i := range plain {
+		assert.InDelta(t, -plain[i].X, reflected[i].X, 1e-4, "point %d", i)
+		assert.InDelta(t, plain[i].Y, reflected[i].Y, 1e-4, "point %d", i)
+	}
+
+	mirrored.Reset()
+	assert.False(t, mirrored.Mirrored())
+}
+
+func TestPositionEquals(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.Goto(3, -4)
+
+	assert.True(t, turtle.PositionEquals(3, -4, 0))
+	assert.True(t, turtle.PositionEquals(3.5, -4, 0.5))
+	assert.True(t, turtle.PositionEquals(3, -4.25, 0.25))
+	assert.False(t, turtle.PositionEquals(3.5, -4, 0.25))
+	assert.False(t, turtle.PositionEquals(3, -3.5, 0.25))
+
+	// Trigonometry leaves rounding a small epsilon absorbs
+	turtle.Home()
+	turtle.SetHeading(0)
+	turtle.Right(90)
+	turtle.Forward(10)
+	assert.False(t, turtle.PositionEquals(0, -10, 0))
+	assert.True(t, turtle.PositionEquals(0, -10, 1e-4))
+}
+
+func TestHeadingEquals(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.SetHeading(90)
+
+	assert.True(t, turtle.HeadingEquals(90, 0))
+	assert.True(t, turtle.HeadingEquals(90.5, 0.5))
+	assert.False(t, turtle.HeadingEquals(90.5, 0.25))
+	assert.True(t, turtle.HeadingEquals(450, 0))
+
+	// Headings either side of 0 are close
+	turtle.SetHeading(359.5)
+	assert.True(t, turtle.HeadingEquals(0.5, 1))
+	assert.True(t, turtle.HeadingEquals(-0.5, 0))
+	assert.False(t, turtle.HeadingEquals(0.5, 0.75))
+
+	// The heading is compared in the turtle's convention
+	assert.NoError(t, turtle.SetHeadingConvention("compass"))
+	turtle.SetHeading(0)
+	assert.True(t, turtle.HeadingEquals(0, 0))
+	assert.False(t, turtle.HeadingEquals(270, 1))
+}
+
+func TestCoordinateLimit(t *testing.T) {
+	turtle := NewHeadless()
+	turtle.SetCoordinateLimit(100)
+	assert.Equal(t, 100.0, turtle.CoordinateLimit())
+
+	// Moves up to the limit are fine, and past it leave the turtle in place
+	assert.NoError(t, turtle.Forward(100))
+	err := turtle.Forward(1)
+	assert.ErrorIs(t, err, ErrCoordinateLimit)
+	assert.ErrorContains(t, err, "moving to (0, 101) goes past ±100")
+	assert.True(t, turtle.PositionEquals(0, 100, 1e-4))
+	assert.Len(t, turtle.Drawing().Points(), 2)
+
+	assert.ErrorIs(t, turtle.Goto(-150, 0), ErrCoordinateLimit)
+	assert.ErrorIs(t, turtle.Goto(float32(math.NaN()), 0), ErrCoordinateLimit)
+	assert.NoError(t, turtle.Goto(-100, -100))
+
+	// An arc stops at its last point inside the limit
+	turtle.Reset()
+	assert.Equal(t, 100.0, turtle.CoordinateLimit())
+	turtle.SetHeading(0)
+	assert.ErrorIs(t, turtle.ArcLeft(60, 180), ErrCoordinateLimit)
+	x, y := turtle.GetPosition()
+	assert.LessOrEqual(t, math.Hypot(float64(x), float64(y-60)), 60.001)
+	assert.LessOrEqual(t, y, float32(100))
+	assert.Greater(t, y, float32(90))
+
+	turtle.SetCoordinateLimit(0)
+	assert.NoError(t, turtle.Goto(1e7, 1e7))
+}